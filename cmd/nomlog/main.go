@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/hashicorp/go-hclog"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/saylorsolutions/nomlog/pkg/iterator"
 	"github.com/saylorsolutions/nomlog/plugin"
 	"github.com/saylorsolutions/nomlog/plugin/file"
 	"github.com/saylorsolutions/nomlog/plugin/stdstream"
@@ -46,6 +48,10 @@ func main() {
 				exitError("Dry run failed: %v", err)
 			}
 			fmt.Println("Dry run ran successfully")
+		case "patterns":
+			if err := doPatterns(args[1:]...); err != nil {
+				exitError("Failed to mine patterns: %v", err)
+			}
 		case "plugins":
 			doPrintPlugins()
 		case "help":
@@ -77,12 +83,14 @@ func usage() {
   nomlog dsl
   nomlog exec FILE
   nomlog vet FILE
+  nomlog patterns FILE
 
 The 'help' subcommand will print this usage information.
 The 'plugins' subcommand will print information about plugins, and the documentation for all plugins loaded into the runtime for this program.
 The 'dsl' subcommand will print information about the scripting DSL.
 The 'exec' subcommand will execute FILE as a nomlog script. Any errors that occur during execution will be reported.
 The 'vet' subcommand will dry run FILE as a nomlog script. Errors will still be reported as if the script were really executed, but no action will be taken.
+The 'patterns' subcommand will cluster the messages in FILE into templates, and print each template with the number of messages that match it.
 `
 	fmt.Print(text)
 }
@@ -97,6 +105,11 @@ func plugins() []plugin.Plugin {
 
 func doPrintDslHelp() {
 	fmt.Print(dsl.GrammarDescription)
+	fmt.Println()
+	fmt.Println("[Expression Functions]")
+	for _, doc := range dsl.FunctionDocs() {
+		fmt.Println("  " + doc)
+	}
 }
 
 func doPrintPlugins() {
@@ -160,3 +173,31 @@ func doVet(log hclog.Logger, args ...string) (rerr error) {
 	}
 	return errors.New("not enough arguments for exec")
 }
+
+func doPatterns(args ...string) error {
+	if len(args) < 1 {
+		return errors.New("not enough arguments for patterns")
+	}
+	src, err := file.Source(args[0])
+	if err != nil {
+		return err
+	}
+	miner := iterator.NewTemplateMiner()
+	err = iterator.Templates(src, miner).Iterate(context.Background(), func(_ entries.LogEntry, _ int) error {
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	templates := miner.Templates()
+	var total int64
+	for _, t := range templates {
+		total += t.Count
+	}
+	fmt.Printf("%d templates found in %d messages\n", len(templates), total)
+	fmt.Printf("%10s  %6s  %s\n", "COUNT", "ID", "TEMPLATE")
+	for _, t := range templates {
+		fmt.Printf("%10d  %6d  %s\n", t.Count, t.ID, t.Template)
+	}
+	return nil
+}
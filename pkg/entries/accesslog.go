@@ -0,0 +1,199 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// CommonLogFormat is the Common Log Format used by Apache and others, expressed as an nginx log_format string.
+	CommonLogFormat = `$remote_addr $remote_logname $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// CombinedLogFormat is the Combined Log Format used by Apache and nginx by default, expressed as an nginx log_format string.
+	CombinedLogFormat = CommonLogFormat + ` "$http_referer" "$http_user_agent"`
+
+	accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+var (
+	ErrInvalidLogFormat = errors.New("invalid log format")
+
+	logFormatVarPattern = regexp.MustCompile(`\$(?:\{([A-Za-z0-9_]+)}|([A-Za-z0-9_]+))`)
+
+	accessLogIntVars = map[string]bool{
+		"status":              true,
+		"body_bytes_sent":     true,
+		"bytes_sent":          true,
+		"request_length":      true,
+		"connection":          true,
+		"connection_requests": true,
+		"remote_port":         true,
+		"server_port":         true,
+		"pid":                 true,
+	}
+	accessLogFloatVars = map[string]bool{
+		"request_time":           true,
+		"upstream_response_time": true,
+		"upstream_connect_time":  true,
+		"upstream_header_time":   true,
+		"msec":                   true,
+	}
+)
+
+func init() {
+	RegisterParser("clf", func(args ...string) (Parser, error) {
+		if err := parserArgs("clf", args, 0, 0); err != nil {
+			return nil, err
+		}
+		return NewAccessLogParser(CommonLogFormat)
+	})
+	RegisterParser("combined", func(args ...string) (Parser, error) {
+		if err := parserArgs("combined", args, 0, 0); err != nil {
+			return nil, err
+		}
+		return NewAccessLogParser(CombinedLogFormat)
+	})
+	RegisterParser("nginx", func(args ...string) (Parser, error) {
+		if err := parserArgs("nginx", args, 1, 1); err != nil {
+			return nil, err
+		}
+		p, err := NewAccessLogParser(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrParserArgs, err)
+		}
+		return p, nil
+	})
+}
+
+// AccessLogParser is a Parser for web server access logs described by an nginx log_format string.
+// Each variable in the format is set as a field of the same name, with some special handling.
+//   - $time_local and $time_iso8601 are set as the StandardTimestampField.
+//   - $request is also split into the request_method, request_uri, and server_protocol fields.
+//   - $status is used to set the StandardLevelField: LevelError for 5xx, LevelWarn for 4xx, and LevelInfo otherwise.
+//   - Well known numeric variables like $status, $body_bytes_sent, and $request_time are set as numbers.
+//   - Values of "-" are treated as empty, and will not be set.
+type AccessLogParser struct {
+	format string
+	re     *regexp.Regexp
+	vars   []string
+}
+
+// NewAccessLogParser creates an AccessLogParser from an nginx log_format string, like CombinedLogFormat.
+func NewAccessLogParser(format string) (*AccessLogParser, error) {
+	p := &AccessLogParser{format: format}
+	var (
+		buf  strings.Builder
+		last int
+	)
+	buf.WriteString("^")
+	matches := logFormatVarPattern.FindAllStringSubmatchIndex(format, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: no variables in format", ErrInvalidLogFormat)
+	}
+	for i, m := range matches {
+		buf.WriteString(regexp.QuoteMeta(format[last:m[0]]))
+		last = m[1]
+
+		var name string
+		if m[2] >= 0 {
+			name = format[m[2]:m[3]]
+		} else {
+			name = format[m[4]:m[5]]
+		}
+		if i > 0 && matches[i-1][1] == m[0] {
+			return nil, fmt.Errorf("%w: variables $%s and $%s must be separated", ErrInvalidLogFormat, p.vars[i-1], name)
+		}
+		p.vars = append(p.vars, name)
+		buf.WriteString("(" + accessLogVarPattern(format[last:]) + ")")
+	}
+	buf.WriteString(regexp.QuoteMeta(format[last:]) + "$")
+
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogFormat, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// accessLogVarPattern determines what a variable may match based on the text that follows it.
+func accessLogVarPattern(following string) string {
+	if len(following) == 0 {
+		return ".*"
+	}
+	switch next := following[0]; next {
+	case '"':
+		return `(?:[^"\\]|\\.)*`
+	default:
+		return "[^" + regexp.QuoteMeta(string(next)) + "]*"
+	}
+}
+
+func (p *AccessLogParser) Parse(s string) (LogEntry, error) {
+	match := p.re.FindStringSubmatch(s)
+	if match == nil {
+		return nil, ErrNoMatch
+	}
+	entry := LogEntry{}
+	for i, name := range p.vars {
+		val := match[i+1]
+		if val == "-" || len(val) == 0 {
+			continue
+		}
+		switch {
+		case name == "time_local":
+			t, err := time.Parse(accessLogTimeLayout, val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time_local '%s': %w", val, err)
+			}
+			entry[StandardTimestampField] = t.UTC().Format(time.RFC3339Nano)
+		case name == "time_iso8601":
+			t, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time_iso8601 '%s': %w", val, err)
+			}
+			entry[StandardTimestampField] = t.UTC().Format(time.RFC3339Nano)
+		case name == "request":
+			entry[name] = val
+			parts := strings.Fields(val)
+			if len(parts) == 3 {
+				entry["request_method"] = parts[0]
+				entry["request_uri"] = parts[1]
+				entry["server_protocol"] = parts[2]
+			}
+		case accessLogIntVars[name]:
+			if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+				entry[name] = i
+				continue
+			}
+			entry[name] = val
+		case accessLogFloatVars[name]:
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				entry[name] = f
+				continue
+			}
+			entry[name] = val
+		default:
+			entry[name] = val
+		}
+	}
+	if status, ok := entry["status"].(int64); ok {
+		switch {
+		case status >= 500:
+			entry[StandardLevelField] = LevelError.String()
+		case status >= 400:
+			entry[StandardLevelField] = LevelWarn.String()
+		default:
+			entry[StandardLevelField] = LevelInfo.String()
+		}
+	}
+	return entry, nil
+}
+
+// String returns the log_format string used to create this AccessLogParser.
+func (p *AccessLogParser) String() string {
+	return p.format
+}
@@ -0,0 +1,95 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAccessLogParser(t *testing.T) {
+	tests := map[string]struct {
+		format   string
+		input    string
+		expected LogEntry
+		err      error
+	}{
+		"Common": {
+			format: CommonLogFormat,
+			input:  `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			expected: LogEntry{
+				"remote_addr":          "127.0.0.1",
+				"remote_user":          "frank",
+				StandardTimestampField: "2000-10-10T20:55:36Z",
+				"request":              "GET /apache_pb.gif HTTP/1.0",
+				"request_method":       "GET",
+				"request_uri":          "/apache_pb.gif",
+				"server_protocol":      "HTTP/1.0",
+				"status":               int64(200),
+				"body_bytes_sent":      int64(2326),
+				StandardLevelField:     "info",
+			},
+		},
+		"Combined with quoted user agent": {
+			format: CombinedLogFormat,
+			input:  `10.1.2.3 - - [01/Apr/2023:12:00:00 +0000] "POST /login HTTP/1.1" 503 - "https://example.com/" "Mozilla/5.0 (X11; \"quoted\" Linux)"`,
+			expected: LogEntry{
+				"remote_addr":          "10.1.2.3",
+				StandardTimestampField: "2023-04-01T12:00:00Z",
+				"request":              "POST /login HTTP/1.1",
+				"request_method":       "POST",
+				"request_uri":          "/login",
+				"server_protocol":      "HTTP/1.1",
+				"status":               int64(503),
+				"http_referer":         "https://example.com/",
+				"http_user_agent":      `Mozilla/5.0 (X11; \"quoted\" Linux)`,
+				StandardLevelField:     "error",
+			},
+		},
+		"Custom nginx format": {
+			format: `$remote_addr [$time_iso8601] "$request" $status $request_time ${upstream_addr}`,
+			input:  `::1 [2023-04-01T12:00:00+02:00] "GET / HTTP/2.0" 404 0.012 10.0.0.5:8080`,
+			expected: LogEntry{
+				"remote_addr":          "::1",
+				StandardTimestampField: "2023-04-01T10:00:00Z",
+				"request":              "GET / HTTP/2.0",
+				"request_method":       "GET",
+				"request_uri":          "/",
+				"server_protocol":      "HTTP/2.0",
+				"status":               int64(404),
+				"request_time":         0.012,
+				"upstream_addr":        "10.0.0.5:8080",
+				StandardLevelField:     "warn",
+			},
+		},
+		"No match": {
+			format: CommonLogFormat,
+			input:  `not an access log`,
+			err:    ErrNoMatch,
+		},
+		"Adjacent variables": {
+			format: `$remote_addr$remote_user`,
+			err:    ErrInvalidLogFormat,
+		},
+		"No variables": {
+			format: `static text`,
+			err:    ErrInvalidLogFormat,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p, err := NewAccessLogParser(tc.format)
+			if err == nil {
+				var entry LogEntry
+				entry, err = p.Parse(tc.input)
+				if tc.err == nil {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expected, entry)
+					return
+				}
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
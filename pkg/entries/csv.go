@@ -0,0 +1,86 @@
+package entries
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrFieldCount = errors.New("wrong number of fields")
+)
+
+func init() {
+	RegisterParser("csv", func(args ...string) (Parser, error) {
+		if err := parserArgs("csv", args, 0, 2); err != nil {
+			return nil, err
+		}
+		p := NewCSVParser()
+		if len(args) > 1 {
+			r, size := utf8.DecodeRuneInString(args[1])
+			if size == 0 || size != len(args[1]) {
+				return nil, fmt.Errorf("%w: csv delimiter must be a single character", ErrParserArgs)
+			}
+			p.Delimiter = r
+		}
+		if len(args) > 0 && len(args[0]) > 0 {
+			header, err := p.readRecord(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid csv header: %v", ErrParserArgs, err)
+			}
+			p.Header = header
+		}
+		return p, nil
+	})
+}
+
+// CSVParser is a Parser for lines of delimiter separated values.
+// Each value is mapped to a field named by the corresponding Header value.
+// If no Header is given, then the first line parsed is used as the Header, and ErrSkipEntry is returned for it.
+// A CSVParser without a Header is not safe for concurrent use.
+type CSVParser struct {
+	Header    []string
+	Delimiter rune // Delimiter separates each value. Defaults to a comma.
+}
+
+// NewCSVParser creates a CSVParser that will read its header from the first line.
+func NewCSVParser(header ...string) *CSVParser {
+	return &CSVParser{
+		Header:    header,
+		Delimiter: ',',
+	}
+}
+
+func (p *CSVParser) Parse(s string) (LogEntry, error) {
+	record, err := p.readRecord(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Header) == 0 {
+		p.Header = record
+		return nil, ErrSkipEntry
+	}
+	if len(record) != len(p.Header) {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrFieldCount, len(p.Header), len(record))
+	}
+	entry := LogEntry{}
+	for i, field := range p.Header {
+		entry[field] = record[i]
+	}
+	return entry, nil
+}
+
+func (p *CSVParser) readRecord(s string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(s))
+	r.Comma = p.Delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	record, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
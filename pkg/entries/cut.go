@@ -21,7 +21,7 @@ type cutOpts struct {
 // CutOpt represents a functional option for Cut.
 type CutOpt func(opts *cutOpts)
 
-// CutField specifies the field to use as the basis for Cut.
+// CutField specifies the field to use as the basis for Cut, which may be a field path like "request.line".
 func CutField(field string) CutOpt {
 	return func(opts *cutOpts) {
 		opts.field = field
@@ -67,9 +67,10 @@ func NewCutCollectSpec() CutCollectSpec {
 // Map calls can override each other by specifying the same field and/or idx multiple times.
 // Map can accept negative indexes to refer to fields at the end of a line of text, starting with -1.
 // Any value set by a mapping may be overridden by another mapping. Ensure that all calls to Map reference any given field only once.
+// The field may be a field path like "http.method", as described in LogEntry.Set.
 func (c CutCollectSpec) Map(field string, idx int) CutCollectSpec {
 	c[idx] = func(entry LogEntry, value string) {
-		entry[field] = value
+		_ = entry.Set(field, value)
 	}
 	return c
 }
@@ -146,9 +147,9 @@ func Cut(entry LogEntry, opt ...CutOpt) (LogEntry, error) {
 		}
 		entry, remaining := opts.collector(entry, fields)
 		if opts.removeSource {
-			delete(entry, opts.field)
+			entry.Delete(opts.field)
 		} else {
-			entry[opts.field] = remaining
+			_ = entry.Set(opts.field, remaining)
 		}
 	}
 	return entry, nil
@@ -16,13 +16,18 @@ const (
 	StandardModuleField    = "@module"    // StandardModuleField references a source system specific component hierarchy
 	StandardCallerField    = "@caller"    // StandardCallerField specifies the caller of the routine emitting this log entry
 	StandardTagField       = "@tag"       // StandardTagField contains classifiers for a LogEntry that may be unrelated to the payload, but relate to the context in which it was emitted
+
+	ReadTimestampField  = "@read_timestamp"   // ReadTimestampField is set by sources to the time that a LogEntry was read
+	ReadLineNumberField = "@read_line_number" // ReadLineNumberField is set by file sources to the line number that a LogEntry was read from
 )
 
 // LogEntry is a single entry in a log, with potentially many fields.
 type LogEntry map[string]any
 
+// HasField determines whether a field exists in this LogEntry.
+// The name may be a field path like "http.status", as described in Set.
 func (e LogEntry) HasField(name string) bool {
-	_, ok := e[name]
+	_, ok := e.Get(name)
 	return ok
 }
 
@@ -54,109 +59,95 @@ func (e LogEntry) HasTag(tag string) bool {
 }
 
 func (e LogEntry) AsFloat(name string) (float64, bool) {
-	if !e.HasField(name) {
+	val, ok := e.Get(name)
+	if !ok {
 		return 0, false
 	}
-	if f, ok := e[name].(float64); ok {
+	if f, ok := val.(float64); ok {
 		return f, true
 	}
-	if s, ok := e[name].(string); ok {
+	if s, ok := val.(string); ok {
 		f, err := strconv.ParseFloat(s, 64)
 		if err != nil {
 			return 0, false
 		}
 		return f, true
 	}
-	v := reflect.ValueOf(e[name])
+	v := reflect.ValueOf(val)
 	if v.CanFloat() {
-		switch v.Kind() {
-		case reflect.Float64:
-			return e[name].(float64), true
-		case reflect.Float32:
-			return float64(e[name].(float32)), true
-		}
+		return v.Float(), true
 	}
 	return 0, false
 }
 
 func (e LogEntry) AsInt(name string) (int64, bool) {
-	if !e.HasField(name) {
+	val, ok := e.Get(name)
+	if !ok {
 		return 0, false
 	}
-	if i, ok := e[name].(int64); ok {
+	if i, ok := val.(int64); ok {
 		return i, true
 	}
-	if s, ok := e[name].(string); ok {
+	if s, ok := val.(string); ok {
 		i, err := strconv.ParseInt(s, 10, 64)
 		if err != nil {
 			return 0, false
 		}
 		return i, true
 	}
-	v := reflect.ValueOf(e[name])
+	v := reflect.ValueOf(val)
 	if v.CanInt() {
-		switch v.Kind() {
-		case reflect.Int64:
-			return e[name].(int64), true
-		case reflect.Int32:
-			return int64(e[name].(int32)), true
-		case reflect.Int:
-			return int64(e[name].(int)), true
-		}
+		return v.Int(), true
 	}
 	return 0, false
 }
 
 func (e LogEntry) AsUint(name string) (uint64, bool) {
-	if !e.HasField(name) {
+	val, ok := e.Get(name)
+	if !ok {
 		return 0, false
 	}
-	if i, ok := e[name].(uint64); ok {
+	if i, ok := val.(uint64); ok {
 		return i, true
 	}
-	if s, ok := e[name].(string); ok {
+	if s, ok := val.(string); ok {
 		i, err := strconv.ParseUint(s, 10, 64)
 		if err != nil {
 			return 0, false
 		}
 		return i, true
 	}
-	v := reflect.ValueOf(e[name])
+	v := reflect.ValueOf(val)
 	if v.CanUint() {
-		switch v.Kind() {
-		case reflect.Uint64:
-			return e[name].(uint64), true
-		case reflect.Uint32:
-			return uint64(e[name].(uint32)), true
-		case reflect.Uint:
-			return uint64(e[name].(uint)), true
-		}
+		return v.Uint(), true
 	}
 	return 0, false
 }
 
 func (e LogEntry) AsString(name string) (string, bool) {
-	if !e.HasField(name) {
+	val, ok := e.Get(name)
+	if !ok {
 		return "", false
 	}
-	if s, ok := e[name].(string); ok {
+	if s, ok := val.(string); ok {
 		return s, true
 	}
-	if s, ok := e[name].(interface{ String() string }); ok {
+	if s, ok := val.(interface{ String() string }); ok {
 		return s.String(), true
 	}
-	if err, ok := e[name].(error); ok {
+	if err, ok := val.(error); ok {
 		return err.Error(), true
 	}
-	return fmt.Sprintf("%v", e[name]), true
+	return fmt.Sprintf("%v", val), true
 }
 
 func (e LogEntry) AsTime(name string, format ...string) (time.Time, bool) {
 	var none time.Time
-	if !e.HasField(name) {
+	val, ok := e.Get(name)
+	if !ok {
 		return none, false
 	}
-	if t, ok := e[name].(time.Time); ok {
+	if t, ok := val.(time.Time); ok {
 		return t.UTC(), true
 	}
 	if s, ok := e.AsString(name); ok {
@@ -180,11 +171,54 @@ func (e LogEntry) AsTime(name string, format ...string) (time.Time, bool) {
 func (e LogEntry) Format(format string, fields ...string) string {
 	args := make([]any, len(fields))
 	for i, f := range fields {
-		args[i] = e[f]
+		args[i], _ = e.Get(f)
 	}
 	return fmt.Sprintf(format, args...)
 }
 
+// Clone returns a deep copy of this LogEntry, so the copy can be changed without affecting the original.
+// Nested objects and arrays are copied recursively. Other values - like strings, numbers, and times - are immutable, so they're shared.
+func (e LogEntry) Clone() LogEntry {
+	if e == nil {
+		return nil
+	}
+	clone := make(LogEntry, len(e))
+	for k, v := range e {
+		clone[k] = cloneValue(v)
+	}
+	return clone
+}
+
+func cloneValue(val any) any {
+	switch v := val.(type) {
+	case LogEntry:
+		return v.Clone()
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		clone := make(map[string]any, len(v))
+		for k, nested := range v {
+			clone[k] = cloneValue(nested)
+		}
+		return clone
+	case []any:
+		if v == nil {
+			return v
+		}
+		clone := make([]any, len(v))
+		for i, nested := range v {
+			clone[i] = cloneValue(nested)
+		}
+		return clone
+	case []string:
+		return append([]string(nil), v...)
+	case []byte:
+		return append([]byte(nil), v...)
+	}
+	return val
+}
+
 func FromString(msg string) LogEntry {
 	entry := LogEntry{}
 	if err := json.Unmarshal([]byte(msg), &entry); err != nil {
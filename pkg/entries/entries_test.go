@@ -182,3 +182,27 @@ func TestLogEntry_AsTime(t *testing.T) {
 		})
 	}
 }
+
+func TestLogEntry_Clone(t *testing.T) {
+	entry := LogEntry{
+		"message": "A",
+		"http": map[string]any{
+			"status":  200,
+			"headers": []any{"a", map[string]any{"b": "c"}},
+		},
+		"tags": []string{"x", "y"},
+	}
+	clone := entry.Clone()
+	assert.Equal(t, entry, clone)
+
+	clone["message"] = "B"
+	clone["http"].(map[string]any)["status"] = 500
+	clone["http"].(map[string]any)["headers"].([]any)[1].(map[string]any)["b"] = "d"
+	clone["tags"].([]string)[0] = "z"
+
+	assert.Equal(t, "A", entry["message"])
+	assert.Equal(t, 200, entry["http"].(map[string]any)["status"])
+	assert.Equal(t, "c", entry["http"].(map[string]any)["headers"].([]any)[1].(map[string]any)["b"])
+	assert.Equal(t, "x", entry["tags"].([]string)[0])
+	assert.Nil(t, LogEntry(nil).Clone())
+}
@@ -0,0 +1,260 @@
+package entries
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultFlattenSeparator is used to join keys in Flatten, and split them in Unflatten, unless overridden with FlattenSeparator.
+const DefaultFlattenSeparator = "."
+
+type flattenOpts struct {
+	separator string
+	arrays    bool
+}
+
+// FlattenOpt represents a functional option for Flatten and Unflatten.
+type FlattenOpt func(opts *flattenOpts)
+
+// FlattenSeparator specifies the separator placed between the keys of nested objects.
+func FlattenSeparator(sep string) FlattenOpt {
+	return func(opts *flattenOpts) {
+		opts.separator = sep
+	}
+}
+
+// FlattenArrays specifies that array elements should be flattened with their index as the key, like "tags.0".
+// When used with Unflatten, objects with keys from 0 to N-1 will be rebuilt as arrays.
+func FlattenArrays() FlattenOpt {
+	return func(opts *flattenOpts) {
+		opts.arrays = true
+	}
+}
+
+func newFlattenOpts(opt []FlattenOpt) *flattenOpts {
+	opts := &flattenOpts{
+		separator: DefaultFlattenSeparator,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if len(opts.separator) == 0 {
+		opts.separator = DefaultFlattenSeparator
+	}
+	return opts
+}
+
+// Flatten replaces nested objects in a LogEntry with top level fields, joining the keys of each level with a separator.
+// For example, {"http":{"status":500}} becomes {"http.status":500}.
+// Keys that contain the separator or a backslash are escaped with a backslash, so {"k8s.io":{"name":"x"}} becomes {"k8s\\.io.name":"x"}, and Unflatten can restore them.
+// With the default separator, keys are escaped with EscapePathKey.
+// Arrays are left as-is unless FlattenArrays is given, and empty objects are retained so no field is lost.
+func Flatten(entry LogEntry, opt ...FlattenOpt) LogEntry {
+	opts := newFlattenOpts(opt)
+	flattened := LogEntry{}
+	for k, v := range entry {
+		flattenInto(flattened, escapeFlattenKey(k, opts.separator), v, opts)
+	}
+	for k := range entry {
+		delete(entry, k)
+	}
+	for k, v := range flattened {
+		entry[k] = v
+	}
+	return entry
+}
+
+func flattenInto(target LogEntry, key string, val any, opts *flattenOpts) {
+	if obj, ok := asObject(val); ok && len(obj) > 0 {
+		for k, v := range obj {
+			flattenInto(target, key+opts.separator+escapeFlattenKey(k, opts.separator), v, opts)
+		}
+		return
+	}
+	if arr, ok := val.([]any); ok && opts.arrays && len(arr) > 0 {
+		for i, v := range arr {
+			flattenInto(target, key+opts.separator+strconv.Itoa(i), v, opts)
+		}
+		return
+	}
+	target[key] = val
+}
+
+// Unflatten rebuilds nested objects from top level fields with keys joined by a separator, reversing Flatten.
+// For example, {"http.status":500} becomes {"http":{"status":500}}.
+// A backslash escapes the next character of a key, as written by Flatten, so {"k8s\\.io.name":"x"} becomes {"k8s.io":{"name":"x"}}.
+// If a field conflicts with another - like "http" and "http.status" both being set to a string - then the conflicting field is left as-is.
+// Keys with a trailing backslash are also left as-is.
+func Unflatten(entry LogEntry, opt ...FlattenOpt) LogEntry {
+	opts := newFlattenOpts(opt)
+	var keys []string
+	for k := range entry {
+		if strings.Contains(k, opts.separator) || strings.Contains(k, `\`) {
+			keys = append(keys, k)
+		}
+	}
+	// Sorting ensures that conflicts are resolved the same way every time.
+	sort.Strings(keys)
+	var touched []string
+	for _, k := range keys {
+		parts, ok := splitFlattenKey(k, opts.separator)
+		if !ok || (len(parts) == 1 && parts[0] == k) {
+			continue
+		}
+		if !unflattenInto(entry, parts, entry[k]) {
+			continue
+		}
+		delete(entry, k)
+		touched = append(touched, parts[0])
+	}
+	if opts.arrays {
+		for _, k := range touched {
+			entry[k] = rebuildArrays(entry[k])
+		}
+	}
+	return entry
+}
+
+// escapeFlattenKey escapes a backslash, and every character of the separator, in a key with a backslash.
+// Escaping each character rather than the whole separator keeps keys from running into an adjacent separator, like "a_" followed by "__".
+func escapeFlattenKey(key, sep string) string {
+	if sep == DefaultFlattenSeparator {
+		return EscapePathKey(key)
+	}
+	if !strings.ContainsAny(key, sep+`\`) {
+		return key
+	}
+	var buf strings.Builder
+	for i := 0; i < len(key); i++ {
+		if key[i] == '\\' || strings.IndexByte(sep, key[i]) >= 0 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(key[i])
+	}
+	return buf.String()
+}
+
+// splitFlattenKey splits a key at each unescaped separator, removing escapes from the parts.
+// It returns false if the key ends with an unfinished escape.
+func splitFlattenKey(key, sep string) ([]string, bool) {
+	var (
+		parts []string
+		buf   strings.Builder
+	)
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\':
+			if i+1 == len(key) {
+				return nil, false
+			}
+			i++
+			buf.WriteByte(key[i])
+		case strings.HasPrefix(key[i:], sep):
+			parts = append(parts, buf.String())
+			buf.Reset()
+			i += len(sep) - 1
+		default:
+			buf.WriteByte(key[i])
+		}
+	}
+	return append(parts, buf.String()), true
+}
+
+// unflattenInto sets a value in nested objects, returning false if a conflicting value is in the way.
+func unflattenInto(entry LogEntry, parts []string, val any) bool {
+	for _, p := range parts {
+		if len(p) == 0 {
+			return false
+		}
+	}
+	obj := map[string]any(entry)
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := obj[p]
+		if !ok {
+			created := map[string]any{}
+			obj[p] = created
+			obj = created
+			continue
+		}
+		nextObj, ok := asObject(next)
+		if !ok {
+			return false
+		}
+		obj = nextObj
+	}
+	last := parts[len(parts)-1]
+	if existing, ok := obj[last]; ok {
+		if _, isObj := asObject(existing); isObj {
+			return false
+		}
+	}
+	obj[last] = val
+	return true
+}
+
+// rebuildArrays replaces objects with keys from 0 to N-1 with arrays, recursively.
+func rebuildArrays(val any) any {
+	obj, ok := asObject(val)
+	if !ok {
+		return val
+	}
+	for k, v := range obj {
+		obj[k] = rebuildArrays(v)
+	}
+	if len(obj) == 0 {
+		return obj
+	}
+	arr := make([]any, len(obj))
+	for k, v := range obj {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(arr) || strconv.Itoa(i) != k {
+			return obj
+		}
+		arr[i] = v
+	}
+	return arr
+}
+
+type explodeOpts struct {
+	indexField string
+}
+
+// ExplodeOpt represents a functional option for Explode.
+type ExplodeOpt func(opts *explodeOpts)
+
+// ExplodeIndex specifies a field that will be set to the index of the element in each exploded entry.
+func ExplodeIndex(field string) ExplodeOpt {
+	return func(opts *explodeOpts) {
+		opts.indexField = field
+	}
+}
+
+// Explode creates an entry for each element of an array field, with the field set to that element.
+// The field may be a field path like "request.items", as described in LogEntry.Set.
+// Each exploded entry is a shallow copy of the original, where the objects containing the field are also copied so that each entry has its own element.
+// If the field is missing, not an array, or empty, then the original entry is returned as the only element.
+func Explode(entry LogEntry, field string, opt ...ExplodeOpt) []LogEntry {
+	opts := new(explodeOpts)
+	for _, o := range opt {
+		o(opts)
+	}
+	val, ok := entry.Get(field)
+	if !ok {
+		return []LogEntry{entry}
+	}
+	arr, ok := val.([]any)
+	if !ok || len(arr) == 0 {
+		return []LogEntry{entry}
+	}
+	exploded := make([]LogEntry, len(arr))
+	for i, elem := range arr {
+		e := entry.copyPath(field, opts.indexField)
+		_ = e.Set(field, elem)
+		if len(opts.indexField) > 0 {
+			_ = e.Set(opts.indexField, int64(i))
+		}
+		exploded[i] = e
+	}
+	return exploded
+}
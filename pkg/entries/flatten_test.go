@@ -0,0 +1,144 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	tests := map[string]struct {
+		entry    LogEntry
+		opts     []FlattenOpt
+		expected LogEntry
+	}{
+		"Nested objects": {
+			entry:    LogEntry{"http": map[string]any{"status": 500, "req": map[string]any{"method": "GET"}}, "msg": "a"},
+			expected: LogEntry{"http.status": 500, "http.req.method": "GET", "msg": "a"},
+		},
+		"Custom separator": {
+			entry:    LogEntry{"http": LogEntry{"status": 500}},
+			opts:     []FlattenOpt{FlattenSeparator("_")},
+			expected: LogEntry{"http_status": 500},
+		},
+		"Arrays left as-is": {
+			entry:    LogEntry{"tags": []any{"a", "b"}},
+			expected: LogEntry{"tags": []any{"a", "b"}},
+		},
+		"Arrays flattened": {
+			entry:    LogEntry{"tags": []any{"a", map[string]any{"b": true}}},
+			opts:     []FlattenOpt{FlattenArrays()},
+			expected: LogEntry{"tags.0": "a", "tags.1.b": true},
+		},
+		"Empty object retained": {
+			entry:    LogEntry{"meta": map[string]any{}},
+			expected: LogEntry{"meta": map[string]any{}},
+		},
+		"Keys with separators escaped": {
+			entry:    LogEntry{"k8s.io": map[string]any{"name": "x", `a\b`: 1}, "a.b": 2},
+			expected: LogEntry{`k8s\.io.name`: "x", `k8s\.io.a\\b`: 1, `a\.b`: 2},
+		},
+		"Custom separator escaped": {
+			entry:    LogEntry{"a_": map[string]any{"b": 1}},
+			opts:     []FlattenOpt{FlattenSeparator("__")},
+			expected: LogEntry{`a\___b`: 1},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Flatten(tc.entry, tc.opts...))
+		})
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	tests := map[string]struct {
+		entry    LogEntry
+		opts     []FlattenOpt
+		expected LogEntry
+	}{
+		"Nested objects": {
+			entry:    LogEntry{"http.status": 500, "http.req.method": "GET", "msg": "a"},
+			expected: LogEntry{"http": map[string]any{"status": 500, "req": map[string]any{"method": "GET"}}, "msg": "a"},
+		},
+		"Custom separator": {
+			entry:    LogEntry{"http__status": 500, "http.host": "x"},
+			opts:     []FlattenOpt{FlattenSeparator("__")},
+			expected: LogEntry{"http": map[string]any{"status": 500}, "http.host": "x"},
+		},
+		"Merge into existing object": {
+			entry:    LogEntry{"http": map[string]any{"status": 500}, "http.host": "x"},
+			expected: LogEntry{"http": map[string]any{"status": 500, "host": "x"}},
+		},
+		"Conflict left as-is": {
+			entry:    LogEntry{"http": "plain", "http.status": 500},
+			expected: LogEntry{"http": "plain", "http.status": 500},
+		},
+		"Empty segment left as-is": {
+			entry:    LogEntry{"a..b": 1},
+			expected: LogEntry{"a..b": 1},
+		},
+		"Arrays rebuilt": {
+			entry:    LogEntry{"tags.0": "a", "tags.1.b": true, "ids.0": 1, "ids.2": 3},
+			opts:     []FlattenOpt{FlattenArrays()},
+			expected: LogEntry{"tags": []any{"a", map[string]any{"b": true}}, "ids": map[string]any{"0": 1, "2": 3}},
+		},
+		"Numeric keys without arrays": {
+			entry:    LogEntry{"tags.0": "a"},
+			expected: LogEntry{"tags": map[string]any{"0": "a"}},
+		},
+		"Escaped keys": {
+			entry:    LogEntry{`k8s\.io.name`: "x", `a\.b`: 2, `a\___b`: 3, "c\\": 4},
+			expected: LogEntry{"k8s.io": map[string]any{"name": "x"}, "a.b": 2, "a___b": 3, "c\\": 4},
+		},
+		"Escaped custom separator": {
+			entry:    LogEntry{`a\___b`: 1},
+			opts:     []FlattenOpt{FlattenSeparator("__")},
+			expected: LogEntry{"a_": map[string]any{"b": 1}},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Unflatten(tc.entry, tc.opts...))
+		})
+	}
+}
+
+func TestFlatten_RoundTrip(t *testing.T) {
+	tests := map[string][]FlattenOpt{
+		"Default separator": nil,
+		"Custom separator":  {FlattenSeparator("__")},
+	}
+
+	for name, opts := range tests {
+		opts := opts
+		t.Run(name, func(t *testing.T) {
+			entry := LogEntry{"a.b": 1, "a__": map[string]any{"c.d": "x", `e\f`: true}, "tags[0]": "t"}
+			flattened := Flatten(entry.Clone(), opts...)
+			assert.Equal(t, entry, Unflatten(flattened, opts...))
+		})
+	}
+}
+
+func TestExplode(t *testing.T) {
+	entry := LogEntry{
+		"id":      "abc",
+		"request": map[string]any{"items": []any{"a", "b"}, "host": "x"},
+	}
+	exploded := Explode(entry, "request.items", ExplodeIndex("item_index"))
+	assert.Equal(t, []LogEntry{
+		{"id": "abc", "request": map[string]any{"items": "a", "host": "x"}, "item_index": int64(0)},
+		{"id": "abc", "request": map[string]any{"items": "b", "host": "x"}, "item_index": int64(1)},
+	}, exploded)
+	assert.Equal(t, []any{"a", "b"}, entry["request"].(map[string]any)["items"], "Original entry should be unchanged")
+
+	for _, field := range []string{"id", "missing", "request.host"} {
+		exploded = Explode(LogEntry{"id": "abc", "empty": []any{}}, field)
+		assert.Equal(t, []LogEntry{{"id": "abc", "empty": []any{}}}, exploded)
+	}
+	exploded = Explode(LogEntry{"empty": []any{}}, "empty")
+	assert.Equal(t, []LogEntry{{"empty": []any{}}}, exploded)
+}
@@ -0,0 +1,239 @@
+package entries
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUnknownGrokPattern   = errors.New("unknown grok pattern")
+	ErrRecursiveGrokPattern = errors.New("recursive grok pattern")
+	ErrInvalidGrokPattern   = errors.New("invalid grok pattern")
+	ErrGrokType             = errors.New("unable to convert grok capture")
+)
+
+var (
+	grokNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	grokRefPattern  = regexp.MustCompile(`%\{([A-Za-z0-9_]+)(?::([^:}]+))?(?::(int|float|string))?}`)
+
+	grokBuiltins     map[string]string
+	grokBuiltinsOnce sync.Once
+)
+
+func init() {
+	RegisterParser("grok", func(args ...string) (Parser, error) {
+		if err := parserArgs("grok", args, 1, -1); err != nil {
+			return nil, err
+		}
+		lib := NewGrokLibrary()
+		for _, file := range args[1:] {
+			if err := lib.LoadFile(file); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrParserArgs, err)
+			}
+		}
+		g, err := lib.Compile(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrParserArgs, err)
+		}
+		return g, nil
+	})
+}
+
+// GrokLibrary is a set of named patterns that may be referenced in a Grok expression.
+// A GrokLibrary is not safe for concurrent modification, but a compiled Grok is safe for concurrent use.
+type GrokLibrary struct {
+	patterns map[string]string
+}
+
+// NewGrokLibrary creates a GrokLibrary with the built-in patterns, like IP, TIMESTAMP_ISO8601, and LOGLEVEL.
+func NewGrokLibrary() *GrokLibrary {
+	grokBuiltinsOnce.Do(func() {
+		lib := &GrokLibrary{patterns: map[string]string{}}
+		if err := lib.Load(strings.NewReader(builtinGrokPatterns)); err != nil {
+			panic("invalid built-in grok patterns: " + err.Error())
+		}
+		grokBuiltins = lib.patterns
+	})
+	patterns := make(map[string]string, len(grokBuiltins))
+	for name, pattern := range grokBuiltins {
+		patterns[name] = pattern
+	}
+	return &GrokLibrary{patterns: patterns}
+}
+
+// Add defines a named pattern, replacing any existing pattern with the same name.
+// The pattern may reference other patterns, which don't need to be defined until Compile is called.
+func (l *GrokLibrary) Add(name, pattern string) error {
+	if !grokNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: pattern name '%s' must only contain letters, numbers, and underscores", ErrInvalidGrokPattern, name)
+	}
+	l.patterns[name] = pattern
+	return nil
+}
+
+// Load reads pattern definitions in the form "NAME PATTERN", one per line.
+// Blank lines and lines starting with '#' are ignored.
+func (l *GrokLibrary) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		idx := strings.IndexAny(line, " \t")
+		if idx < 0 {
+			return fmt.Errorf("%w: missing pattern on line %d", ErrInvalidGrokPattern, lineNum)
+		}
+		if err := l.Add(line[:idx], strings.TrimSpace(line[idx:])); err != nil {
+			return fmt.Errorf("%w on line %d", err, lineNum)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadFile reads pattern definitions from a file, as described in Load.
+func (l *GrokLibrary) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	return l.Load(f)
+}
+
+// Compile creates a Grok from an expression referencing patterns in this library.
+// References take the form %{PATTERN}, %{PATTERN:field}, or %{PATTERN:field:type}, where type may be int, float, or string.
+// Regex named groups like (?P<field>...) may also be used to capture fields.
+func (l *GrokLibrary) Compile(expr string) (*Grok, error) {
+	g := &Grok{
+		expr:     expr,
+		captures: map[string]grokCapture{},
+	}
+	expanded, err := l.expand(expr, g, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGrokPattern, err)
+	}
+	g.re = re
+	return g, nil
+}
+
+func (l *GrokLibrary) expand(expr string, g *Grok, visiting map[string]bool) (string, error) {
+	var (
+		buf  strings.Builder
+		last int
+	)
+	for _, m := range grokRefPattern.FindAllStringSubmatchIndex(expr, -1) {
+		buf.WriteString(expr[last:m[0]])
+		last = m[1]
+
+		name := expr[m[2]:m[3]]
+		pattern, ok := l.patterns[name]
+		if !ok {
+			return "", fmt.Errorf("%w '%s'", ErrUnknownGrokPattern, name)
+		}
+		if visiting[name] {
+			return "", fmt.Errorf("%w '%s'", ErrRecursiveGrokPattern, name)
+		}
+		visiting[name] = true
+		inner, err := l.expand(pattern, g, visiting)
+		delete(visiting, name)
+		if err != nil {
+			return "", err
+		}
+
+		if m[4] < 0 {
+			buf.WriteString("(?:" + inner + ")")
+			continue
+		}
+		capture := grokCapture{field: expr[m[4]:m[5]]}
+		if m[6] >= 0 {
+			capture.typ = expr[m[6]:m[7]]
+		}
+		group := "grok" + strconv.Itoa(len(g.captures))
+		g.captures[group] = capture
+		buf.WriteString("(?P<" + group + ">" + inner + ")")
+	}
+	buf.WriteString(expr[last:])
+	return buf.String(), nil
+}
+
+// CompileGrok compiles a Grok expression using only the built-in patterns.
+func CompileGrok(expr string) (*Grok, error) {
+	return NewGrokLibrary().Compile(expr)
+}
+
+type grokCapture struct {
+	field string
+	typ   string
+}
+
+func (c grokCapture) convert(val string) (any, error) {
+	switch c.typ {
+	case "int":
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w '%s' to int for field '%s'", ErrGrokType, val, c.field)
+		}
+		return i, nil
+	case "float":
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w '%s' to float for field '%s'", ErrGrokType, val, c.field)
+		}
+		return f, nil
+	default:
+		return val, nil
+	}
+}
+
+// Grok is a compiled Grok expression, which is a Parser that sets a field for each named capture.
+// Capturing into a standard field - like %{TIMESTAMP_ISO8601:@timestamp} or %{LOGLEVEL:@level} - will populate that field for other operations.
+type Grok struct {
+	expr     string
+	re       *regexp.Regexp
+	captures map[string]grokCapture
+}
+
+func (g *Grok) Parse(s string) (LogEntry, error) {
+	match := g.re.FindStringSubmatchIndex(s)
+	if match == nil {
+		return nil, ErrNoMatch
+	}
+	entry := LogEntry{}
+	for i, name := range g.re.SubexpNames() {
+		if len(name) == 0 || match[2*i] < 0 {
+			continue
+		}
+		capture, ok := g.captures[name]
+		if !ok {
+			capture = grokCapture{field: name}
+		}
+		if _, ok := entry[capture.field]; ok {
+			continue
+		}
+		val, err := capture.convert(s[match[2*i]:match[2*i+1]])
+		if err != nil {
+			return nil, err
+		}
+		entry[capture.field] = val
+	}
+	return entry, nil
+}
+
+// String returns the original Grok expression.
+func (g *Grok) String() string {
+	return g.expr
+}
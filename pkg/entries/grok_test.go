@@ -0,0 +1,128 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileGrok(t *testing.T) {
+	tests := map[string]struct {
+		expr     string
+		input    string
+		expected LogEntry
+		err      error
+	}{
+		"Standard fields": {
+			expr:  `^%{TIMESTAMP_ISO8601:@timestamp} +%{LOGLEVEL:@level} %{GREEDYDATA:@message}$`,
+			input: `2023-04-01T12:00:00.123Z  WARN disk is almost full`,
+			expected: LogEntry{
+				StandardTimestampField: "2023-04-01T12:00:00.123Z",
+				StandardLevelField:     "WARN",
+				StandardMessageField:   "disk is almost full",
+			},
+		},
+		"Typed captures": {
+			expr:     `%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{INT:status:int} %{NUMBER:duration:float}`,
+			input:    `10.0.0.12 GET /api/v1/users?id=5 200 0.025`,
+			expected: LogEntry{"client": "10.0.0.12", "method": "GET", "path": "/api/v1/users?id=5", "status": int64(200), "duration": 0.025},
+		},
+		"IPv6": {
+			expr:     `^%{IP:client}$`,
+			input:    `2001:db8::ff00:42:8329`,
+			expected: LogEntry{"client": "2001:db8::ff00:42:8329"},
+		},
+		"Nested captures": {
+			expr:     `^%{SYSLOGBASE} %{GREEDYDATA:@message}`,
+			input:    `Apr  1 12:00:00 web-1 sshd[1234]: Accepted publickey`,
+			expected: LogEntry{StandardTimestampField: "Apr  1 12:00:00", "host": "web-1", "program": "sshd", "pid": int64(1234), StandardMessageField: "Accepted publickey"},
+		},
+		"Regex named group": {
+			expr:     `^(?P<word>\w+) %{INT:n:int}$`,
+			input:    `count 5`,
+			expected: LogEntry{"word": "count", "n": int64(5)},
+		},
+		"Optional capture": {
+			expr:     `^%{WORD:a}(?: %{WORD:b})?$`,
+			input:    `alone`,
+			expected: LogEntry{"a": "alone"},
+		},
+		"No match": {
+			expr:  `^%{INT:n}$`,
+			input: `abc`,
+			err:   ErrNoMatch,
+		},
+		"Type conversion": {
+			expr:  `^%{NOTSPACE:n:int}$`,
+			input: `abc`,
+			err:   ErrGrokType,
+		},
+		"Unknown pattern": {
+			expr: `%{NOPE:a}`,
+			err:  ErrUnknownGrokPattern,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			g, err := CompileGrok(tc.expr)
+			if err == nil {
+				var entry LogEntry
+				entry, err = g.Parse(tc.input)
+				if tc.err == nil {
+					require.NoError(t, err)
+					assert.Equal(t, tc.expected, entry)
+					return
+				}
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestGrokLibrary(t *testing.T) {
+	lib := NewGrokLibrary()
+	err := lib.Load(strings.NewReader(`
+# Custom patterns
+REQID req-[0-9a-f]+
+REQUEST %{REQID:request_id} %{WORD:action}
+`))
+	require.NoError(t, err)
+	g, err := lib.Compile(`^%{REQUEST}$`)
+	require.NoError(t, err)
+	entry, err := g.Parse("req-1f2e login")
+	require.NoError(t, err)
+	assert.Equal(t, LogEntry{"request_id": "req-1f2e", "action": "login"}, entry)
+
+	_, err = CompileGrok(`%{REQID}`)
+	assert.ErrorIs(t, err, ErrUnknownGrokPattern, "Custom patterns should not leak into other libraries")
+
+	require.NoError(t, lib.Add("LOOP_A", "%{LOOP_B}"))
+	require.NoError(t, lib.Add("LOOP_B", "%{LOOP_A}"))
+	_, err = lib.Compile(`%{LOOP_A}`)
+	assert.ErrorIs(t, err, ErrRecursiveGrokPattern)
+
+	assert.ErrorIs(t, lib.Add("bad name", "x"), ErrInvalidGrokPattern)
+	assert.ErrorIs(t, lib.Load(strings.NewReader("MISSING")), ErrInvalidGrokPattern)
+}
+
+func TestNewParser_Grok(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns")
+	require.NoError(t, os.WriteFile(patterns, []byte("USERID u[0-9]+\n"), 0600))
+
+	parser, err := NewParser("grok", "%{USERID:user} %{LOGLEVEL:@level}", patterns)
+	require.NoError(t, err)
+	entry, err := parser.Parse("u42 ERROR")
+	require.NoError(t, err)
+	assert.Equal(t, LogEntry{"user": "u42", StandardLevelField: "ERROR"}, entry)
+
+	_, err = NewParser("grok")
+	assert.ErrorIs(t, err, ErrParserArgs)
+	_, err = NewParser("grok", "%{USERID:user}", filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, ErrParserArgs)
+}
@@ -0,0 +1,188 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrNoPairs           = errors.New("no key/value pairs found")
+	ErrUnterminatedQuote = errors.New("unterminated quoted value")
+	ErrEmptyKey          = errors.New("empty key")
+	ErrMissingValue      = errors.New("missing value")
+)
+
+func init() {
+	RegisterParser("logfmt", func(args ...string) (Parser, error) {
+		if err := parserArgs("logfmt", args, 0, 0); err != nil {
+			return nil, err
+		}
+		return NewLogfmtParser(), nil
+	})
+	RegisterParser("kv", func(args ...string) (Parser, error) {
+		if err := parserArgs("kv", args, 0, 3); err != nil {
+			return nil, err
+		}
+		p := NewKVParser()
+		if len(args) > 0 {
+			p.PairSeparator = args[0]
+		}
+		if len(args) > 1 {
+			p.ValueSeparator = args[1]
+		}
+		if len(args) > 2 {
+			p.Quotes = args[2]
+		}
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	})
+}
+
+// KVParser is a Parser for lines of key/value pairs, like 'a=1 b="two words"'.
+// Pair separators that are whitespace will match any run of whitespace.
+// Values may be quoted with any of the Quotes characters, and a backslash may be used to escape characters within a quoted value.
+type KVParser struct {
+	PairSeparator  string // PairSeparator separates each key/value pair. Defaults to a single space.
+	ValueSeparator string // ValueSeparator separates a key from its value. Defaults to "=".
+	Quotes         string // Quotes is the set of characters that may be used to quote a value. Defaults to a double quote.
+	BareKeys       bool   // BareKeys allows keys without a value, which are set to true.
+}
+
+// NewKVParser creates a KVParser with default separators and quotes.
+func NewKVParser() *KVParser {
+	return &KVParser{
+		PairSeparator:  " ",
+		ValueSeparator: "=",
+		Quotes:         `"`,
+	}
+}
+
+// NewLogfmtParser creates a KVParser for logfmt formatted lines, which may contain bare keys.
+func NewLogfmtParser() *KVParser {
+	p := NewKVParser()
+	p.BareKeys = true
+	return p
+}
+
+func (p *KVParser) validate() error {
+	switch {
+	case len(p.PairSeparator) == 0:
+		return fmt.Errorf("%w: pair separator must not be empty", ErrParserArgs)
+	case len(p.ValueSeparator) == 0:
+		return fmt.Errorf("%w: value separator must not be empty", ErrParserArgs)
+	case p.PairSeparator == p.ValueSeparator:
+		return fmt.Errorf("%w: pair and value separators must be different", ErrParserArgs)
+	}
+	return nil
+}
+
+func (p *KVParser) Parse(s string) (LogEntry, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	var (
+		entry      = LogEntry{}
+		foundValue bool
+		ws         = strings.TrimSpace(p.PairSeparator) == ""
+	)
+	for len(s) > 0 {
+		if ws {
+			s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		} else {
+			s = strings.TrimPrefix(s, p.PairSeparator)
+		}
+		if len(s) == 0 {
+			break
+		}
+
+		key, rest, hasValue := p.readKey(s, ws)
+		key = strings.TrimSpace(key)
+		s = rest
+		if len(key) == 0 {
+			if !hasValue {
+				continue
+			}
+			return nil, ErrEmptyKey
+		}
+		if !hasValue {
+			if !p.BareKeys {
+				return nil, fmt.Errorf("%w for key '%s'", ErrMissingValue, key)
+			}
+			entry[key] = true
+			continue
+		}
+
+		var val string
+		var err error
+		val, s, err = p.readValue(s, ws)
+		if err != nil {
+			return nil, fmt.Errorf("%w for key '%s'", err, key)
+		}
+		entry[key] = val
+		foundValue = true
+	}
+	if !foundValue {
+		return nil, ErrNoPairs
+	}
+	return entry, nil
+}
+
+// readKey reads up to the next value separator, or the end of the pair.
+func (p *KVParser) readKey(s string, ws bool) (key, rest string, hasValue bool) {
+	pairEnd := p.pairEnd(s, ws)
+	valIdx := strings.Index(s[:pairEnd], p.ValueSeparator)
+	if valIdx < 0 {
+		return s[:pairEnd], s[pairEnd:], false
+	}
+	return s[:valIdx], s[valIdx+len(p.ValueSeparator):], true
+}
+
+func (p *KVParser) readValue(s string, ws bool) (val, rest string, err error) {
+	if !ws {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	}
+	if len(s) == 0 || !strings.ContainsRune(p.Quotes, rune(s[0])) {
+		end := p.pairEnd(s, ws)
+		return strings.TrimSpace(s[:end]), s[end:], nil
+	}
+	quote := s[0]
+	var buf strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					buf.WriteByte('\n')
+				case 't':
+					buf.WriteByte('\t')
+				default:
+					buf.WriteByte(s[i])
+				}
+				continue
+			}
+		case quote:
+			return buf.String(), s[i+1:], nil
+		}
+		buf.WriteByte(s[i])
+	}
+	return "", "", ErrUnterminatedQuote
+}
+
+func (p *KVParser) pairEnd(s string, ws bool) int {
+	if ws {
+		if idx := strings.IndexFunc(s, unicode.IsSpace); idx >= 0 {
+			return idx
+		}
+		return len(s)
+	}
+	if idx := strings.Index(s, p.PairSeparator); idx >= 0 {
+		return idx
+	}
+	return len(s)
+}
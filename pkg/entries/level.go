@@ -0,0 +1,228 @@
+package entries
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Level is a canonical logging level. Levels are ordered by severity, so they may be compared directly.
+type Level int
+
+const (
+	LevelUnknown Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelNotice
+	LevelWarn
+	LevelError
+	LevelFatal
+	LevelAlert
+	LevelEmergency
+)
+
+var levelNames = [...]string{"unknown", "trace", "debug", "info", "notice", "warn", "error", "fatal", "alert", "emergency"}
+
+func (l Level) String() string {
+	if l < LevelUnknown || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// LevelMapping maps raw level values to a canonical Level. Keys are matched case-insensitively.
+type LevelMapping map[string]Level
+
+var (
+	// DefaultLevelFields are the fields that are checked for a level, in order, unless overridden with LevelFields.
+	DefaultLevelFields = []string{StandardLevelField, "level", "lvl", "severity", "levelname", "loglevel", "log_level"}
+
+	// CommonLevels maps level names used by common Go, Java, Node, and Python loggers, as well as syslog.
+	CommonLevels = LevelMapping{
+		"trace": LevelTrace, "trc": LevelTrace, "t": LevelTrace, "finest": LevelTrace, "finer": LevelTrace, "verbose": LevelTrace, "all": LevelTrace,
+		"debug": LevelDebug, "dbg": LevelDebug, "d": LevelDebug, "fine": LevelDebug, "config": LevelDebug,
+		"info": LevelInfo, "inf": LevelInfo, "i": LevelInfo, "information": LevelInfo, "informational": LevelInfo,
+		"notice": LevelNotice, "n": LevelNotice,
+		"warn": LevelWarn, "warning": LevelWarn, "wrn": LevelWarn, "w": LevelWarn,
+		"error": LevelError, "err": LevelError, "eror": LevelError, "e": LevelError, "severe": LevelError, "dpanic": LevelError,
+		"fatal": LevelFatal, "ftl": LevelFatal, "f": LevelFatal, "critical": LevelFatal, "crit": LevelFatal, "c": LevelFatal, "panic": LevelFatal,
+		"alert": LevelAlert, "a": LevelAlert,
+		"emergency": LevelEmergency, "emerg": LevelEmergency,
+	}
+	// SyslogLevels maps syslog severities, from 0 (emergency) to 7 (debug).
+	SyslogLevels = LevelMapping{
+		"0": LevelEmergency, "1": LevelAlert, "2": LevelFatal, "3": LevelError,
+		"4": LevelWarn, "5": LevelNotice, "6": LevelInfo, "7": LevelDebug,
+	}
+	// PinoLevels maps the numeric levels used by pino and bunyan in Node.
+	PinoLevels = LevelMapping{
+		"10": LevelTrace, "20": LevelDebug, "30": LevelInfo, "40": LevelWarn, "50": LevelError, "60": LevelFatal,
+	}
+	// PythonLevels maps the numeric levels used by the Python logging module.
+	PythonLevels = LevelMapping{
+		"5": LevelTrace, "10": LevelDebug, "20": LevelInfo, "30": LevelWarn, "40": LevelError, "50": LevelFatal,
+	}
+	// ZapLevels maps the numeric levels used by zap in Go, including its "Level(N)" format for unnamed levels.
+	ZapLevels = LevelMapping{
+		"-1": LevelDebug, "0": LevelInfo, "1": LevelWarn, "2": LevelError, "3": LevelError, "4": LevelFatal, "5": LevelFatal,
+	}
+	// Log4jLevels maps the numeric levels used by Log4j 2 in Java.
+	Log4jLevels = LevelMapping{
+		"100": LevelFatal, "200": LevelError, "300": LevelWarn, "400": LevelInfo, "500": LevelDebug, "600": LevelTrace,
+	}
+	// SlogLevels maps the numeric levels used by log/slog in Go.
+	SlogLevels = LevelMapping{
+		"-4": LevelDebug, "0": LevelInfo, "4": LevelWarn, "8": LevelError,
+	}
+
+	namedLevelMappings = map[string]LevelMapping{
+		"common": CommonLevels,
+		"syslog": SyslogLevels,
+		"pino":   PinoLevels,
+		"python": PythonLevels,
+		"zap":    ZapLevels,
+		"log4j":  Log4jLevels,
+		"slog":   SlogLevels,
+	}
+)
+
+// NamedLevelMapping returns one of the built-in LevelMapping values by name.
+// Valid names are common, syslog, pino, python, zap, log4j, and slog.
+func NamedLevelMapping(name string) (LevelMapping, bool) {
+	m, ok := namedLevelMappings[strings.ToLower(name)]
+	return m, ok
+}
+
+// ParseLevelName returns the Level with the given canonical name, like "warn".
+func ParseLevelName(name string) (Level, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for i, n := range levelNames {
+		if i > 0 && n == name {
+			return Level(i), true
+		}
+	}
+	return LevelUnknown, false
+}
+
+type levelOpts struct {
+	fields   []string
+	mappings []LevelMapping
+}
+
+// LevelOpt represents a functional option for NormalizeLevel and ParseLevel.
+type LevelOpt func(opts *levelOpts)
+
+// LevelFields specifies the candidate fields to check for a level, in order of preference.
+func LevelFields(fields ...string) LevelOpt {
+	return func(opts *levelOpts) {
+		opts.fields = fields
+	}
+}
+
+// LevelMappings specifies mappings that are checked in order before the default rules in ParseLevel.
+// This is useful for sources with numeric levels that would otherwise be ambiguous, or a custom level vocabulary.
+func LevelMappings(mappings ...LevelMapping) LevelOpt {
+	normalized := make([]LevelMapping, len(mappings))
+	for i, m := range mappings {
+		normalized[i] = make(LevelMapping, len(m))
+		for k, l := range m {
+			normalized[i][strings.ToLower(strings.TrimSpace(k))] = l
+		}
+	}
+	return func(opts *levelOpts) {
+		opts.mappings = append(opts.mappings, normalized...)
+	}
+}
+
+func newLevelOpts(opt []LevelOpt) *levelOpts {
+	opts := &levelOpts{
+		fields: DefaultLevelFields,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	return opts
+}
+
+// ParseLevel interprets a value as a canonical Level.
+// Any mappings given with LevelMappings are checked first, then CommonLevels.
+// Numbers are interpreted as SyslogLevels from 0 to 7, PinoLevels from 10 to 60, and Log4jLevels from 100 to 600.
+// Zap's "Level(N)" format is interpreted with ZapLevels.
+func ParseLevel(val any, opt ...LevelOpt) (Level, bool) {
+	return parseLevel(val, newLevelOpts(opt))
+}
+
+func parseLevel(val any, opts *levelOpts) (Level, bool) {
+	if l, ok := val.(Level); ok {
+		return l, l != LevelUnknown
+	}
+	key, ok := levelKey(val)
+	if !ok {
+		return LevelUnknown, false
+	}
+	for _, m := range opts.mappings {
+		if l, ok := m[key]; ok {
+			return l, true
+		}
+	}
+	if l, ok := CommonLevels[key]; ok {
+		return l, true
+	}
+	if strings.HasPrefix(key, "level(") && strings.HasSuffix(key, ")") {
+		l, ok := ZapLevels[key[len("level("):len(key)-1]]
+		return l, ok
+	}
+	if _, err := strconv.Atoi(key); err == nil {
+		for _, m := range []LevelMapping{SyslogLevels, PinoLevels, Log4jLevels} {
+			if l, ok := m[key]; ok {
+				return l, true
+			}
+		}
+	}
+	return LevelUnknown, false
+}
+
+// levelKey converts a raw level value to a key for a LevelMapping.
+func levelKey(val any) (string, bool) {
+	if val == nil {
+		return "", false
+	}
+	if f, ok := val.(float64); ok {
+		if f != math.Trunc(f) {
+			return "", false
+		}
+		return strconv.FormatInt(int64(f), 10), true
+	}
+	s, ok := LogEntry{"val": val}.AsString("val")
+	if !ok {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(s)), true
+}
+
+// NormalizeLevel detects a level in the first candidate field that has one, and writes its canonical name to the StandardLevelField.
+// Candidate fields default to DefaultLevelFields, and values are interpreted as described in ParseLevel.
+// If no level can be found, then the entry is returned unchanged.
+func NormalizeLevel(entry LogEntry, opt ...LevelOpt) LogEntry {
+	opts := newLevelOpts(opt)
+	for _, field := range opts.fields {
+		val, ok := entry.Get(field)
+		if !ok {
+			continue
+		}
+		if l, ok := parseLevel(val, opts); ok {
+			entry[StandardLevelField] = l.String()
+			return entry
+		}
+	}
+	return entry
+}
+
+// Level interprets the StandardLevelField of this LogEntry as a canonical Level, as described in ParseLevel.
+// LevelUnknown is returned if there is no level, or it's not recognized.
+func (e LogEntry) Level() Level {
+	l, _ := ParseLevel(e[StandardLevelField])
+	return l
+}
@@ -0,0 +1,72 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]struct {
+		val      any
+		opts     []LevelOpt
+		expected Level
+	}{
+		"Name":              {val: "info", expected: LevelInfo},
+		"Upper case":        {val: "WARNING", expected: LevelWarn},
+		"Abbreviation":      {val: "ERR", expected: LevelError},
+		"Single letter":     {val: "D", expected: LevelDebug},
+		"Surrounding space": {val: " notice ", expected: LevelNotice},
+		"Python critical":   {val: "CRITICAL", expected: LevelFatal},
+		"JUL severe":        {val: "SEVERE", expected: LevelError},
+		"Zap dpanic":        {val: "dpanic", expected: LevelError},
+		"Zap unnamed":       {val: "Level(-1)", expected: LevelDebug},
+		"Syslog number":     {val: int64(4), expected: LevelWarn},
+		"Syslog string":     {val: "0", expected: LevelEmergency},
+		"Pino number":       {val: float64(30), expected: LevelInfo},
+		"Log4j number":      {val: 200, expected: LevelError},
+		"Level value":       {val: LevelAlert, expected: LevelAlert},
+		"Custom mapping":    {val: "VERBOSE", opts: []LevelOpt{LevelMappings(LevelMapping{"Verbose": LevelDebug})}, expected: LevelDebug},
+		"Named mapping":     {val: int64(20), opts: []LevelOpt{LevelMappings(PythonLevels)}, expected: LevelInfo},
+		"Mappings in order": {val: "4", opts: []LevelOpt{LevelMappings(SlogLevels, ZapLevels)}, expected: LevelWarn},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			l, ok := ParseLevel(tc.val, tc.opts...)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, l)
+		})
+	}
+
+	for _, val := range []any{"", "nonsense", 1.5, int64(1000), nil, true, LevelUnknown} {
+		_, ok := ParseLevel(val)
+		assert.False(t, ok, "%v should not be parsed as a level", val)
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	entry := NormalizeLevel(LogEntry{"severity": "bogus", "levelname": "WARNING"})
+	assert.Equal(t, "warn", entry[StandardLevelField], "Unrecognized values should be skipped")
+	assert.Equal(t, "WARNING", entry["levelname"], "Source fields should be retained")
+
+	entry = NormalizeLevel(LogEntry{"lvl": float64(40), "level": "info"}, LevelFields("lvl"), LevelMappings(PythonLevels))
+	assert.Equal(t, "error", entry[StandardLevelField])
+
+	entry = NormalizeLevel(LogEntry{StandardMessageField: "no level"})
+	assert.NotContains(t, entry, StandardLevelField)
+}
+
+func TestLogEntry_Level(t *testing.T) {
+	assert.Equal(t, LevelFatal, LogEntry{StandardLevelField: "Critical"}.Level())
+	assert.Equal(t, LevelUnknown, LogEntry{}.Level())
+	assert.True(t, LogEntry{StandardLevelField: "error"}.Level() > LogEntry{StandardLevelField: "warn"}.Level())
+
+	l, ok := ParseLevelName(LevelEmergency.String())
+	assert.True(t, ok)
+	assert.Equal(t, LevelEmergency, l)
+	_, ok = ParseLevelName("unknown")
+	assert.False(t, ok)
+	assert.Equal(t, "Level(42)", Level(42).String())
+}
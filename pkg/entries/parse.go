@@ -0,0 +1,132 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	ParseErrorField = "@parse_error" // ParseErrorField is set on a LogEntry with the reason that a Parser failed to parse its source field
+)
+
+var (
+	ErrUnknownParser = errors.New("unknown parser")
+	ErrParserArgs    = errors.New("invalid parser arguments")
+	ErrSkipEntry     = errors.New("entry should be skipped")
+)
+
+// Parser interprets a string - usually an unstructured log message - as a set of fields.
+// A Parser may return ErrSkipEntry to indicate that the input is not a log entry, like a CSV header line.
+type Parser interface {
+	Parse(s string) (LogEntry, error)
+}
+
+// ParserFunc allows using a function as a Parser.
+type ParserFunc func(s string) (LogEntry, error)
+
+func (fn ParserFunc) Parse(s string) (LogEntry, error) {
+	return fn(s)
+}
+
+// ParserFactory creates a new Parser from string arguments, like those given in a DSL parse statement.
+// A new Parser is created for each use, so a Parser may keep state about the stream it's parsing.
+type ParserFactory func(args ...string) (Parser, error)
+
+var (
+	parsers   = map[string]ParserFactory{}
+	parsersMu sync.RWMutex
+)
+
+// RegisterParser makes a Parser available by name to NewParser.
+// Registering a name that is already registered will replace the existing ParserFactory.
+func RegisterParser(name string, factory ParserFactory) {
+	if factory == nil {
+		panic("parser factory is nil")
+	}
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[name] = factory
+}
+
+// NewParser creates a new Parser from a factory registered with RegisterParser.
+func NewParser(name string, args ...string) (Parser, error) {
+	parsersMu.RLock()
+	factory, ok := parsers[name]
+	parsersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownParser, name)
+	}
+	return factory(args...)
+}
+
+// ParserNames returns the names of all registered parsers in alphabetical order.
+func ParserNames() []string {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	names := make([]string, 0, len(parsers))
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parserArgs validates the number of arguments given to a ParserFactory. A max less than 0 allows any number of arguments.
+func parserArgs(name string, args []string, min, max int) error {
+	switch {
+	case min == max && len(args) != min:
+		return fmt.Errorf("%w: %s requires %d argument(s), got %d", ErrParserArgs, name, min, len(args))
+	case max < 0 && len(args) < min:
+		return fmt.Errorf("%w: %s requires at least %d argument(s), got %d", ErrParserArgs, name, min, len(args))
+	case max >= 0 && (len(args) < min || len(args) > max):
+		return fmt.Errorf("%w: %s requires %d to %d arguments, got %d", ErrParserArgs, name, min, max, len(args))
+	}
+	return nil
+}
+
+type parseOpts struct {
+	field string
+}
+
+// ParseOpt represents a functional option for Parse.
+type ParseOpt func(opts *parseOpts)
+
+// ParseField specifies the field to use as the input for Parse.
+func ParseField(field string) ParseOpt {
+	return func(opts *parseOpts) {
+		opts.field = field
+	}
+}
+
+// Parse uses a Parser to interpret a field of a LogEntry, merging the resulting fields into the entry.
+// Parse assumes it should be parsing the StandardMessageField unless overridden.
+// If the field doesn't exist, then the entry is returned as-is.
+// If the Parser fails, then ParseErrorField will be set with the reason, and the entry will otherwise be unchanged.
+// ErrSkipEntry is returned if the Parser indicates that this entry should be skipped.
+func Parse(entry LogEntry, parser Parser, opt ...ParseOpt) (LogEntry, error) {
+	opts := &parseOpts{
+		field: StandardMessageField,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+
+	str, ok := entry.AsString(opts.field)
+	if !ok {
+		return entry, nil
+	}
+	parsed, err := parser.Parse(str)
+	if err != nil {
+		if errors.Is(err, ErrSkipEntry) {
+			return entry, err
+		}
+		entry[ParseErrorField] = err.Error()
+		return entry, nil
+	}
+	for k, v := range parsed {
+		entry[k] = v
+	}
+	return entry, nil
+}
@@ -0,0 +1,144 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewParser(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		args     []string
+		input    string
+		expected LogEntry
+		err      error
+	}{
+		"Logfmt": {
+			name:     "logfmt",
+			input:    `level=info msg="request \"done\"" status=200 cached`,
+			expected: LogEntry{"level": "info", "msg": `request "done"`, "status": "200", "cached": true},
+		},
+		"Logfmt empty value": {
+			name:     "logfmt",
+			input:    `a= b=1`,
+			expected: LogEntry{"a": "", "b": "1"},
+		},
+		"Logfmt unstructured": {
+			name:  "logfmt",
+			input: `just some text`,
+			err:   ErrNoPairs,
+		},
+		"Logfmt unterminated quote": {
+			name:  "logfmt",
+			input: `a="open`,
+			err:   ErrUnterminatedQuote,
+		},
+		"KV default": {
+			name:     "kv",
+			input:    `a=1   b='2'`,
+			expected: LogEntry{"a": "1", "b": "'2'"},
+		},
+		"KV custom separators": {
+			name:     "kv",
+			args:     []string{",", ":", `'"`},
+			input:    `user: 'jane doe', id:42,role:"admin, owner"`,
+			expected: LogEntry{"user": "jane doe", "id": "42", "role": "admin, owner"},
+		},
+		"KV missing value": {
+			name:  "kv",
+			input: `a=1 b`,
+			err:   ErrMissingValue,
+		},
+		"KV invalid args": {
+			name: "kv",
+			args: []string{"=", "="},
+			err:  ErrParserArgs,
+		},
+		"CSV header": {
+			name:     "csv",
+			args:     []string{"ts,level,msg"},
+			input:    `2023-04-01,INFO,"hello, world"`,
+			expected: LogEntry{"ts": "2023-04-01", "level": "INFO", "msg": "hello, world"},
+		},
+		"CSV delimiter": {
+			name:     "csv",
+			args:     []string{"a|b", "|"},
+			input:    `1|2`,
+			expected: LogEntry{"a": "1", "b": "2"},
+		},
+		"CSV field count": {
+			name:  "csv",
+			args:  []string{"a,b"},
+			input: `1,2,3`,
+			err:   ErrFieldCount,
+		},
+		"Regex": {
+			name:     "regex",
+			args:     []string{`^(?P<method>[A-Z]+) (?P<path>\S+)(?: (?P<proto>HTTP/\S+))?$`},
+			input:    `GET /index.html`,
+			expected: LogEntry{"method": "GET", "path": "/index.html"},
+		},
+		"Regex no match": {
+			name:  "regex",
+			args:  []string{`^(?P<method>[A-Z]+)$`},
+			input: `get`,
+			err:   ErrNoMatch,
+		},
+		"Regex unnamed groups": {
+			name: "regex",
+			args: []string{`^([A-Z]+)$`},
+			err:  ErrNoNamedGroups,
+		},
+		"Unknown": {
+			name: "nope",
+			err:  ErrUnknownParser,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			parser, err := NewParser(tc.name, tc.args...)
+			if err == nil {
+				_, err = parser.Parse(tc.input)
+			}
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			entry, _ := parser.Parse(tc.input)
+			assert.Equal(t, tc.expected, entry)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	parser := NewLogfmtParser()
+	entry, err := Parse(LogEntry{StandardMessageField: "a=1 b=2"}, parser)
+	require.NoError(t, err)
+	assert.Equal(t, LogEntry{StandardMessageField: "a=1 b=2", "a": "1", "b": "2"}, entry)
+
+	entry, err = Parse(LogEntry{"raw": "a=1"}, parser, ParseField("raw"))
+	require.NoError(t, err)
+	assert.Equal(t, "1", entry["a"])
+
+	entry, err = Parse(LogEntry{"other": "a=1"}, parser)
+	require.NoError(t, err)
+	assert.Equal(t, LogEntry{"other": "a=1"}, entry, "Entries without the source field should be unchanged")
+
+	entry, err = Parse(LogEntry{StandardMessageField: "not structured"}, parser)
+	require.NoError(t, err, "Parse failures should not stop processing")
+	assert.Equal(t, ErrNoPairs.Error(), entry[ParseErrorField])
+	assert.Len(t, entry, 2)
+}
+
+func TestCSVParser_HeaderLine(t *testing.T) {
+	parser := NewCSVParser()
+	_, err := parser.Parse("a,b")
+	assert.ErrorIs(t, err, ErrSkipEntry, "The header line should be skipped")
+	entry, err := parser.Parse("1,2")
+	require.NoError(t, err)
+	assert.Equal(t, LogEntry{"a": "1", "b": "2"}, entry)
+}
@@ -0,0 +1,348 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPath  = errors.New("invalid field path")
+	ErrPathConflict = errors.New("field path conflicts with an existing value")
+)
+
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return "[" + strconv.Itoa(s.index) + "]"
+	}
+	return s.key
+}
+
+// isSimplePath determines whether a path can only refer to a top level field.
+func isSimplePath(path string) bool {
+	return !strings.ContainsAny(path, `.[\`)
+}
+
+// parsePath splits a field path into segments.
+// Segments are separated by a dot, and array elements are referenced with a bracketed index, which may be negative to count from the end.
+// A backslash escapes the next character, so keys containing dots or brackets may be referenced like "k8s\.io/name".
+func parsePath(path string) ([]pathSegment, error) {
+	var (
+		segments []pathSegment
+		buf      strings.Builder
+		pending  bool
+	)
+	flush := func() error {
+		if !pending {
+			return nil
+		}
+		if buf.Len() == 0 {
+			return fmt.Errorf("%w '%s': empty key", ErrInvalidPath, path)
+		}
+		segments = append(segments, pathSegment{key: buf.String()})
+		buf.Reset()
+		pending = false
+		return nil
+	}
+	// A key is expected at the start of the path, and after every dot.
+	pending = true
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("%w '%s': trailing escape", ErrInvalidPath, path)
+			}
+			i++
+			buf.WriteByte(path[i])
+			pending = true
+		case '.':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			pending = true
+		case '[':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w '%s': unterminated index", ErrInvalidPath, path)
+			}
+			idx, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("%w '%s': invalid index '%s'", ErrInvalidPath, path, path[i+1:i+end])
+			}
+			segments = append(segments, pathSegment{index: idx, isIndex: true})
+			pending = false
+			i += end
+		default:
+			buf.WriteByte(c)
+			pending = true
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// EscapePathKey escapes a key so that it may be used as a single segment of a field path, even if it contains dots, brackets, or backslashes.
+func EscapePathKey(key string) string {
+	if isSimplePath(key) && !strings.Contains(key, "]") {
+		return key
+	}
+	var buf strings.Builder
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(`.[]\`, key[i]) >= 0 {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(key[i])
+	}
+	return buf.String()
+}
+
+func asObject(val any) (map[string]any, bool) {
+	switch v := val.(type) {
+	case map[string]any:
+		return v, true
+	case LogEntry:
+		return v, true
+	}
+	return nil, false
+}
+
+func resolveIndex(arr []any, idx int) (int, bool) {
+	if idx < 0 {
+		idx += len(arr)
+	}
+	return idx, idx >= 0 && idx < len(arr)
+}
+
+func getSegment(val any, seg pathSegment) (any, bool) {
+	if seg.isIndex {
+		arr, ok := val.([]any)
+		if !ok {
+			return nil, false
+		}
+		idx, ok := resolveIndex(arr, seg.index)
+		if !ok {
+			return nil, false
+		}
+		return arr[idx], true
+	}
+	obj, ok := asObject(val)
+	if !ok {
+		return nil, false
+	}
+	v, ok := obj[seg.key]
+	return v, ok
+}
+
+// Get returns the value referenced by a field path, like "http.status" or "tags[0]".
+// A top level field matching the whole path is preferred, so existing fields with dots in their name may still be referenced directly.
+// See Set for details about the path syntax.
+func (e LogEntry) Get(path string) (any, bool) {
+	if val, ok := e[path]; ok {
+		return val, true
+	}
+	if isSimplePath(path) {
+		return nil, false
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	var val any = e
+	for _, seg := range segments {
+		var ok bool
+		val, ok = getSegment(val, seg)
+		if !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// Set sets the value referenced by a field path, creating intermediate objects as needed.
+//   - Path segments are separated by a dot, like "http.request.method".
+//   - Array elements are referenced with a bracketed index, like "tags[0]". Negative indexes count from the end of the array.
+//   - A backslash escapes the next character, so a key containing a dot may be referenced like "k8s\.io/name".
+//
+// Array elements may be replaced, but arrays are not extended.
+// If the path runs through an existing value that isn't an object or array, then ErrPathConflict is returned.
+// A top level field matching the whole path is preferred, as described in Get.
+func (e LogEntry) Set(path string, val any) error {
+	if _, ok := e[path]; ok || isSimplePath(path) {
+		e[path] = val
+		return nil
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	var cur any = e
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if seg.isIndex {
+			arr, ok := cur.([]any)
+			if !ok {
+				return fmt.Errorf("%w: '%s' is not an array at '%s'", ErrPathConflict, path, seg)
+			}
+			idx, ok := resolveIndex(arr, seg.index)
+			if !ok {
+				return fmt.Errorf("%w: index %d is out of range in '%s'", ErrPathConflict, seg.index, path)
+			}
+			if last {
+				arr[idx] = val
+				return nil
+			}
+			cur = arr[idx]
+			continue
+		}
+		obj, ok := asObject(cur)
+		if !ok {
+			return fmt.Errorf("%w: '%s' is not an object at '%s'", ErrPathConflict, path, seg)
+		}
+		if last {
+			obj[seg.key] = val
+			return nil
+		}
+		next, ok := obj[seg.key]
+		if !ok || next == nil {
+			if segments[i+1].isIndex {
+				return fmt.Errorf("%w: array '%s' doesn't exist in '%s'", ErrPathConflict, seg, path)
+			}
+			next = map[string]any{}
+			obj[seg.key] = next
+		}
+		cur = next
+	}
+	return nil
+}
+
+// Delete removes the value referenced by a field path, as described in Set, returning whether it existed.
+// Deleting an array element will remove it from the array, shifting later elements down.
+func (e LogEntry) Delete(path string) bool {
+	if _, ok := e[path]; ok {
+		delete(e, path)
+		return true
+	}
+	if isSimplePath(path) {
+		return false
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	_, ok := deleteSegments(e, segments)
+	return ok
+}
+
+// deleteSegments removes the value at the end of the segments, returning the container, which may have been replaced if it's an array.
+func deleteSegments(val any, segments []pathSegment) (any, bool) {
+	seg := segments[0]
+	if seg.isIndex {
+		arr, ok := val.([]any)
+		if !ok {
+			return val, false
+		}
+		idx, ok := resolveIndex(arr, seg.index)
+		if !ok {
+			return val, false
+		}
+		if len(segments) == 1 {
+			updated := make([]any, 0, len(arr)-1)
+			updated = append(updated, arr[:idx]...)
+			return append(updated, arr[idx+1:]...), true
+		}
+		child, ok := deleteSegments(arr[idx], segments[1:])
+		arr[idx] = child
+		return arr, ok
+	}
+	obj, ok := asObject(val)
+	if !ok {
+		return val, false
+	}
+	child, ok := obj[seg.key]
+	if !ok {
+		return val, false
+	}
+	if len(segments) == 1 {
+		delete(obj, seg.key)
+		return val, true
+	}
+	child, ok = deleteSegments(child, segments[1:])
+	obj[seg.key] = child
+	return val, ok
+}
+
+func setSegment(container any, seg pathSegment, val any) {
+	if seg.isIndex {
+		if arr, ok := container.([]any); ok {
+			if idx, ok := resolveIndex(arr, seg.index); ok {
+				arr[idx] = val
+			}
+		}
+		return
+	}
+	if obj, ok := asObject(container); ok {
+		obj[seg.key] = val
+	}
+}
+
+// copyPath returns a shallow copy of this LogEntry, where the objects and arrays along each path are also copied.
+// This allows values at the end of the paths to be set without affecting the original LogEntry.
+func (e LogEntry) copyPath(paths ...string) LogEntry {
+	cp := make(LogEntry, len(e))
+	for k, v := range e {
+		cp[k] = v
+	}
+	for _, path := range paths {
+		if _, ok := e[path]; ok || isSimplePath(path) {
+			continue
+		}
+		segments, err := parsePath(path)
+		if err != nil {
+			continue
+		}
+		var cur any = cp
+		for _, seg := range segments[:len(segments)-1] {
+			child, ok := getSegment(cur, seg)
+			if !ok {
+				break
+			}
+			var copied any
+			switch c := child.(type) {
+			case map[string]any:
+				m := make(map[string]any, len(c))
+				for k, v := range c {
+					m[k] = v
+				}
+				copied = m
+			case LogEntry:
+				m := make(LogEntry, len(c))
+				for k, v := range c {
+					m[k] = v
+				}
+				copied = m
+			case []any:
+				a := make([]any, len(c))
+				copy(a, c)
+				copied = a
+			}
+			if copied == nil {
+				break
+			}
+			setSegment(cur, seg, copied)
+			cur = copied
+		}
+	}
+	return cp
+}
@@ -0,0 +1,138 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func nestedEntry() LogEntry {
+	return LogEntry{
+		"http": map[string]any{
+			"status": float64(500),
+			"headers": map[string]any{
+				"x.request.id": "abc",
+			},
+		},
+		"tags":       []any{"a", "b", map[string]any{"name": "c"}},
+		"dotted.key": "literal",
+		"k8s.io":     map[string]any{"app": "api"},
+		"ctx":        LogEntry{"user": "bob"},
+	}
+}
+
+func TestLogEntry_Get(t *testing.T) {
+	tests := map[string]struct {
+		path     string
+		expected any
+		found    bool
+	}{
+		"Top level":               {path: "tags", expected: []any{"a", "b", map[string]any{"name": "c"}}, found: true},
+		"Nested":                  {path: "http.status", expected: float64(500), found: true},
+		"Index":                   {path: "tags[1]", expected: "b", found: true},
+		"Negative index":          {path: "tags[-1].name", expected: "c", found: true},
+		"Literal key wins":        {path: "dotted.key", expected: "literal", found: true},
+		"Escaped dot":             {path: `http.headers.x\.request\.id`, expected: "abc", found: true},
+		"Literal prefix":          {path: "k8s.io.app", found: false},
+		"Escaped prefix":          {path: `k8s\.io.app`, expected: "api", found: true},
+		"Missing":                 {path: "http.method", found: false},
+		"Index out of range":      {path: "tags[3]", found: false},
+		"Index into object":       {path: "http[0]", found: false},
+		"Key into scalar":         {path: "http.status.code", found: false},
+		"Invalid path":            {path: "http..status", found: false},
+		"Unterminated index":      {path: "tags[0", found: false},
+		"Index without field":     {path: "[0]", found: false},
+		"Trailing escape":         {path: `http\`, found: false},
+		"Nested LogEntry":         {path: "ctx.user", expected: "bob", found: true},
+		"Simple missing with dot": {path: "nothing.here", found: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			val, ok := nestedEntry().Get(tc.path)
+			assert.Equal(t, tc.found, ok)
+			assert.Equal(t, tc.expected, val)
+		})
+	}
+}
+
+func TestLogEntry_Set(t *testing.T) {
+	entry := nestedEntry()
+	require.NoError(t, entry.Set("http.method", "GET"))
+	require.NoError(t, entry.Set("tags[0]", "z"))
+	require.NoError(t, entry.Set("tags[-1].name", "y"))
+	require.NoError(t, entry.Set("a.b.c", true))
+	require.NoError(t, entry.Set("dotted.key", "updated"))
+	require.NoError(t, entry.Set(`new\.key`, 1))
+
+	assert.Equal(t, "GET", entry["http"].(map[string]any)["method"])
+	assert.Equal(t, []any{"z", "b", map[string]any{"name": "y"}}, entry["tags"])
+	assert.Equal(t, map[string]any{"b": map[string]any{"c": true}}, entry["a"])
+	assert.Equal(t, "updated", entry["dotted.key"])
+	assert.Equal(t, 1, entry["new.key"])
+
+	assert.ErrorIs(t, entry.Set("http.status.code", 1), ErrPathConflict)
+	assert.ErrorIs(t, entry.Set("tags[5]", 1), ErrPathConflict)
+	assert.ErrorIs(t, entry.Set("http[0]", 1), ErrPathConflict)
+	assert.ErrorIs(t, entry.Set("list[0]", 1), ErrPathConflict)
+	assert.ErrorIs(t, entry.Set("a..b", 1), ErrInvalidPath)
+	assert.NotContains(t, entry, "list")
+}
+
+func TestLogEntry_Delete(t *testing.T) {
+	entry := nestedEntry()
+	assert.True(t, entry.Delete("http.status"))
+	assert.True(t, entry.Delete("tags[1]"))
+	assert.True(t, entry.Delete("tags[-1].name"))
+	assert.True(t, entry.Delete("dotted.key"))
+	assert.False(t, entry.Delete("http.status"))
+	assert.False(t, entry.Delete("tags[5]"))
+	assert.False(t, entry.Delete("nothing"))
+
+	assert.NotContains(t, entry["http"], "status")
+	assert.Equal(t, []any{"a", map[string]any{}}, entry["tags"])
+	assert.NotContains(t, entry, "dotted.key")
+}
+
+func TestEscapePathKey(t *testing.T) {
+	entry := nestedEntry()
+	assert.Equal(t, "plain", EscapePathKey("plain"))
+	assert.Equal(t, `x\.request\.id`, EscapePathKey("x.request.id"))
+	assert.Equal(t, `a\[0\]\\`, EscapePathKey(`a[0]\`))
+
+	val, ok := entry.Get("http.headers." + EscapePathKey("x.request.id"))
+	assert.True(t, ok)
+	assert.Equal(t, "abc", val)
+}
+
+func TestNestedPaths(t *testing.T) {
+	entry := nestedEntry()
+	status, ok := entry.AsFloat("http.status")
+	assert.True(t, ok)
+	assert.Equal(t, float64(500), status)
+	assert.True(t, entry.HasField("tags[2].name"))
+
+	entry = Reassign(entry, NewReassignSpec().Move("http.status", "response.code"))
+	assert.Equal(t, map[string]any{"code": float64(500)}, entry["response"])
+	assert.False(t, entry.HasField("http.status"))
+
+	entry = Transform(entry, NewTransformSpec().Transform("response.code", func(val any) any {
+		return val.(float64) + 1
+	}))
+	assert.Equal(t, float64(501), entry["response"].(map[string]any)["code"])
+
+	entry, err := Cut(LogEntry{"request": map[string]any{"line": "GET /index.html"}},
+		CutField("request.line"),
+		CutCollector(NewCutCollectSpec().Map("request.method", 0).Map("request.path", 1).Collector()),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"line": "", "method": "GET", "path": "/index.html"}, entry["request"])
+
+	entry = Keep(nestedEntry(), "http.status", "dotted.key", "tags")
+	assert.Equal(t, LogEntry{
+		"http":       map[string]any{"status": float64(500)},
+		"dotted.key": "literal",
+		"tags":       []any{"a", "b", map[string]any{"name": "c"}},
+	}, entry)
+}
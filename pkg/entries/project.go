@@ -0,0 +1,34 @@
+package entries
+
+// Drop removes the given fields from a LogEntry.
+// Fields may be field paths like "http.headers", as described in LogEntry.Set.
+// Fields that don't exist in the LogEntry are ignored.
+func Drop(entry LogEntry, fields ...string) LogEntry {
+	for _, f := range fields {
+		entry.Delete(f)
+	}
+	return entry
+}
+
+// Keep removes all fields from a LogEntry except those given.
+// Fields may be field paths like "http.status", in which case only that part of the enclosing object is kept.
+// Array elements can't be kept individually, so the whole array must be kept instead.
+func Keep(entry LogEntry, fields ...string) LogEntry {
+	kept := LogEntry{}
+	for _, f := range fields {
+		if val, ok := entry[f]; ok {
+			kept[f] = val
+			continue
+		}
+		if val, ok := entry.Get(f); ok {
+			_ = kept.Set(f, val)
+		}
+	}
+	for f := range entry {
+		delete(entry, f)
+	}
+	for f, val := range kept {
+		entry[f] = val
+	}
+	return entry
+}
@@ -0,0 +1,26 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDrop(t *testing.T) {
+	entry := LogEntry{
+		"a": "a",
+		"b": "b",
+		"c": "c",
+	}
+	entry = Drop(entry, "a", "c", "doesn't exist")
+	assert.Equal(t, LogEntry{"b": "b"}, entry)
+}
+
+func TestKeep(t *testing.T) {
+	entry := LogEntry{
+		"a": "a",
+		"b": "b",
+		"c": "c",
+	}
+	entry = Keep(entry, "a", "c", "doesn't exist")
+	assert.Equal(t, LogEntry{"a": "a", "c": "c"}, entry)
+}
@@ -1,5 +1,9 @@
 package entries
 
+import (
+	"sort"
+)
+
 type SourceField string
 type TargetField string
 
@@ -17,24 +21,46 @@ func (s ReassignSpec) Move(source SourceField, target TargetField) ReassignSpec
 }
 
 func (e LogEntry) getSourceFieldVal(field SourceField) (any, bool) {
-	s := string(field)
-	if e.HasField(s) {
-		return e[s], true
-	}
-	return nil, false
+	return e.Get(string(field))
 }
 
-func (e LogEntry) setTargetFieldVal(field TargetField, val any) {
-	e[string(field)] = val
+func (e LogEntry) setTargetFieldVal(field TargetField, val any) error {
+	return e.Set(string(field), val)
 }
 
 // Reassign runs a ReassignSpec against a LogEntry to move one or more fields.
+// Fields may be field paths like "http.status", as described in LogEntry.Set.
+// All source fields are removed before any target is set, so fields may be swapped, like moving "a" to "b" and "b" to "a".
+// A source nested in another source is moved out of it first, so moving "http" to "x" and "http.status" to "y" sets "y" to the status, and "x" to the rest of the http object.
+// If a value can't be set at the target path, then it's left in its source field.
 func Reassign(entry LogEntry, spec ReassignSpec) LogEntry {
-	for s, t := range spec {
-		val, ok := entry.getSourceFieldVal(s)
-		if ok {
-			entry.setTargetFieldVal(t, val)
-			delete(entry, string(s))
+	type move struct {
+		source SourceField
+		target TargetField
+		val    any
+	}
+	sources := make([]SourceField, 0, len(spec))
+	for s := range spec {
+		sources = append(sources, s)
+	}
+	// A nested path sorts after the path that contains it, so reverse order removes nested sources first.
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i] > sources[j]
+	})
+	var moves []move
+	for _, s := range sources {
+		if val, ok := entry.getSourceFieldVal(s); ok {
+			moves = append(moves, move{source: s, target: spec[s], val: val})
+			entry.Delete(string(s))
+		}
+	}
+	// Targets are set in a consistent order, in case one is nested in another.
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].target < moves[j].target
+	})
+	for _, m := range moves {
+		if err := entry.setTargetFieldVal(m.target, m.val); err != nil {
+			_ = entry.Set(string(m.source), m.val)
 		}
 	}
 	return entry
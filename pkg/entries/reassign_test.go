@@ -20,3 +20,43 @@ func TestReassign(t *testing.T) {
 	assert.False(t, entry.HasField("z"), "Should not be a 'z' field")
 	assert.False(t, entry.HasField("doesn't exist"), "Should not be a 'doesn't exist' field")
 }
+
+func TestReassign_Swap(t *testing.T) {
+	entry := LogEntry{
+		"a": 1,
+		"b": 2,
+	}
+	entry = Reassign(entry, NewReassignSpec().Move("a", "b").Move("b", "a"))
+	assert.Equal(t, LogEntry{"a": 2, "b": 1}, entry)
+}
+
+func TestReassign_NestedSources(t *testing.T) {
+	tests := map[string]struct {
+		parent, nested TargetField
+	}{
+		"Parent target first": {parent: "a", nested: "b"},
+		"Nested target first": {parent: "b", nested: "a"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			entry := LogEntry{
+				"http": map[string]any{"status": 500, "method": "GET"},
+				"tags": []any{"x", "y", "z"},
+			}
+			spec := NewReassignSpec().
+				Move("http", tc.parent).
+				Move("http.status", tc.nested).
+				Move("tags", "rest").
+				Move("tags[0]", "first")
+			entry = Reassign(entry, spec)
+			assert.Equal(t, LogEntry{
+				string(tc.parent): map[string]any{"method": "GET"},
+				string(tc.nested): 500,
+				"rest":            []any{"y", "z"},
+				"first":           "x",
+			}, entry, "Nested sources should be moved out of their parent first")
+		})
+	}
+}
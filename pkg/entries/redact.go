@@ -0,0 +1,459 @@
+package entries
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrMissingRedactKey = errors.New("a key is required to redact with HMAC")
+	ErrInvalidDetector  = errors.New("invalid redaction detector")
+)
+
+// MaskMode determines how a detected value is replaced by a Redactor.
+type MaskMode int
+
+const (
+	// MaskFull replaces the value with a placeholder naming the detector, like "[REDACTED:email]".
+	MaskFull MaskMode = iota
+	// MaskPartial masks most of the value while preserving its format, like "j***@example.com" or "****-****-****-1111".
+	MaskPartial
+	// MaskHMAC replaces the value with a keyed hash, like "[email:1a2b3c4d5e6f7a8b]", so the same value is always replaced the same way.
+	// This allows redacted values to be correlated and joined without exposing them.
+	MaskHMAC
+)
+
+var maskModeNames = [...]string{"full", "partial", "hash"}
+
+func (m MaskMode) String() string {
+	if m < MaskFull || int(m) >= len(maskModeNames) {
+		return fmt.Sprintf("MaskMode(%d)", int(m))
+	}
+	return maskModeNames[m]
+}
+
+// ParseMaskMode returns the MaskMode with the given name: full, partial, or hash.
+func ParseMaskMode(name string) (MaskMode, bool) {
+	for i, n := range maskModeNames {
+		if n == strings.ToLower(name) {
+			return MaskMode(i), true
+		}
+	}
+	return MaskFull, false
+}
+
+// Detector finds sensitive values in a string.
+// If the pattern has capture groups, then the first group that participated in the match is redacted instead of the whole match.
+type Detector struct {
+	name     string
+	re       *regexp.Regexp
+	validate func(s string, loc []int) bool
+	partial  func(s string) string
+}
+
+// Name returns the name of this Detector, which is used in redacted placeholders.
+func (d *Detector) Name() string {
+	return d.name
+}
+
+var (
+	// EmailDetector finds email addresses.
+	EmailDetector = &Detector{
+		name:    "email",
+		re:      regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+		partial: partialEmail,
+	}
+	// IPv4Detector finds IPv4 addresses.
+	IPv4Detector = &Detector{
+		name:    "ipv4",
+		re:      regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+		partial: partialIPv4,
+	}
+	// IPv6Detector finds IPv6 addresses, including compressed forms like "2001:db8::1".
+	IPv6Detector = &Detector{
+		name:     "ipv6",
+		re:       longest(`(?i)(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}|(?:[0-9a-f]{1,4}:){1,7}:|(?:[0-9a-f]{1,4}:){1,6}:[0-9a-f]{1,4}|(?:[0-9a-f]{1,4}:){1,5}(?::[0-9a-f]{1,4}){1,2}|(?:[0-9a-f]{1,4}:){1,4}(?::[0-9a-f]{1,4}){1,3}|(?:[0-9a-f]{1,4}:){1,3}(?::[0-9a-f]{1,4}){1,4}|(?:[0-9a-f]{1,4}:){1,2}(?::[0-9a-f]{1,4}){1,5}|[0-9a-f]{1,4}:(?::[0-9a-f]{1,4}){1,6}|:(?::[0-9a-f]{1,4}){1,7}`),
+		validate: validIPv6,
+		partial:  partialIPv6,
+	}
+	// JWTDetector finds JSON Web Tokens.
+	JWTDetector = &Detector{
+		name:    "jwt",
+		re:      regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+		partial: partialJWT,
+	}
+	// CreditCardDetector finds credit card numbers of 13 to 19 digits, optionally separated by spaces or dashes, that pass a Luhn check.
+	CreditCardDetector = &Detector{
+		name:     "credit_card",
+		re:       regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		validate: validCreditCard,
+		partial:  partialKeepLast(4),
+	}
+	// AWSKeyDetector finds AWS access key IDs, and secret access keys that are labeled as such.
+	AWSKeyDetector = &Detector{
+		name:    "aws_key",
+		re:      regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16})\b|(?i:aws_?secret_?access_?key)["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})`),
+		partial: partialAWSKey,
+	}
+	// BearerDetector finds bearer tokens in authorization headers, redacting only the token.
+	BearerDetector = &Detector{
+		name:    "bearer",
+		re:      regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+		partial: partialKeepLast(4),
+	}
+
+	namedDetectors = map[string]*Detector{
+		EmailDetector.name:      EmailDetector,
+		IPv4Detector.name:       IPv4Detector,
+		IPv6Detector.name:       IPv6Detector,
+		JWTDetector.name:        JWTDetector,
+		CreditCardDetector.name: CreditCardDetector,
+		AWSKeyDetector.name:     AWSKeyDetector,
+		BearerDetector.name:     BearerDetector,
+	}
+)
+
+func longest(pattern string) *regexp.Regexp {
+	re := regexp.MustCompile(pattern)
+	re.Longest()
+	return re
+}
+
+// DefaultDetectors returns all built-in Detectors.
+func DefaultDetectors() []*Detector {
+	return []*Detector{EmailDetector, JWTDetector, BearerDetector, AWSKeyDetector, CreditCardDetector, IPv6Detector, IPv4Detector}
+}
+
+// NamedDetector returns a built-in Detector by name.
+// Valid names are email, ipv4, ipv6, jwt, credit_card, aws_key, and bearer.
+func NamedDetector(name string) (*Detector, bool) {
+	d, ok := namedDetectors[strings.ToLower(name)]
+	return d, ok
+}
+
+// NewRegexDetector creates a Detector from a regular expression.
+// If the pattern has capture groups, then the first group that participated in the match is redacted instead of the whole match, so a pattern like `password=(\S+)` will only redact the password.
+func NewRegexDetector(name, pattern string) (*Detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDetector, err)
+	}
+	if len(name) == 0 {
+		name = "regex"
+	}
+	return &Detector{
+		name:    name,
+		re:      re,
+		partial: partialKeepLast(4),
+	}, nil
+}
+
+type detection struct {
+	start, end int
+	detector   *Detector
+}
+
+func (d *Detector) find(s string) []detection {
+	var found []detection
+	for _, loc := range d.re.FindAllStringSubmatchIndex(s, -1) {
+		start, end := loc[0], loc[1]
+		for g := 2; g < len(loc); g += 2 {
+			if loc[g] >= 0 {
+				start, end = loc[g], loc[g+1]
+				break
+			}
+		}
+		if start == end {
+			continue
+		}
+		if d.validate != nil && !d.validate(s, []int{start, end}) {
+			continue
+		}
+		found = append(found, detection{start, end, d})
+	}
+	return found
+}
+
+type redactorOpts struct {
+	detectors []*Detector
+	mode      MaskMode
+	key       []byte
+	fields    []string
+}
+
+// RedactOpt represents a functional option for NewRedactor.
+type RedactOpt func(opts *redactorOpts)
+
+// RedactDetectors specifies the Detectors used to find sensitive values. Defaults to DefaultDetectors.
+func RedactDetectors(detectors ...*Detector) RedactOpt {
+	return func(opts *redactorOpts) {
+		opts.detectors = append(opts.detectors, detectors...)
+	}
+}
+
+// RedactMask specifies how detected values are replaced. Defaults to MaskFull.
+func RedactMask(mode MaskMode) RedactOpt {
+	return func(opts *redactorOpts) {
+		opts.mode = mode
+	}
+}
+
+// RedactKey specifies the key used to hash values with MaskHMAC.
+func RedactKey(key []byte) RedactOpt {
+	return func(opts *redactorOpts) {
+		opts.key = key
+	}
+}
+
+// RedactFields limits redaction to the given fields, which may be field paths as described in LogEntry.Set.
+// By default, all string values in the entry are redacted, including those in nested objects and arrays.
+func RedactFields(fields ...string) RedactOpt {
+	return func(opts *redactorOpts) {
+		opts.fields = fields
+	}
+}
+
+// Redactor finds and replaces sensitive values in the string fields of a LogEntry.
+// A Redactor is safe for concurrent use.
+type Redactor struct {
+	opts *redactorOpts
+}
+
+// NewRedactor creates a Redactor. ErrMissingRedactKey is returned if MaskHMAC is used without a key.
+func NewRedactor(opt ...RedactOpt) (*Redactor, error) {
+	opts := new(redactorOpts)
+	for _, o := range opt {
+		o(opts)
+	}
+	if len(opts.detectors) == 0 {
+		opts.detectors = DefaultDetectors()
+	}
+	if opts.mode == MaskHMAC && len(opts.key) == 0 {
+		return nil, ErrMissingRedactKey
+	}
+	return &Redactor{opts: opts}, nil
+}
+
+// Redact replaces sensitive values in the entry, returning the modified entry.
+// Non-string values, like numbers, are not redacted.
+func (r *Redactor) Redact(entry LogEntry) LogEntry {
+	if len(r.opts.fields) == 0 {
+		for k, v := range entry {
+			entry[k] = r.redactValue(v)
+		}
+		return entry
+	}
+	for _, f := range r.opts.fields {
+		if val, ok := entry.Get(f); ok {
+			_ = entry.Set(f, r.redactValue(val))
+		}
+	}
+	return entry
+}
+
+func (r *Redactor) redactValue(val any) any {
+	switch v := val.(type) {
+	case string:
+		return r.RedactString(v)
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = r.redactValue(elem)
+		}
+	case LogEntry:
+		for k, elem := range v {
+			v[k] = r.redactValue(elem)
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = r.redactValue(elem)
+		}
+	}
+	return val
+}
+
+// RedactString replaces any sensitive values found in a string.
+// When detections overlap, the one that starts first is used, preferring the longest, then the first configured Detector.
+func (r *Redactor) RedactString(s string) string {
+	var found []detection
+	for _, d := range r.opts.detectors {
+		found = append(found, d.find(s)...)
+	}
+	if len(found) == 0 {
+		return s
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].start != found[j].start {
+			return found[i].start < found[j].start
+		}
+		return found[i].end > found[j].end
+	})
+	var (
+		buf  strings.Builder
+		last int
+	)
+	for _, f := range found {
+		if f.start < last {
+			continue
+		}
+		buf.WriteString(s[last:f.start])
+		buf.WriteString(r.mask(f.detector, s[f.start:f.end]))
+		last = f.end
+	}
+	buf.WriteString(s[last:])
+	return buf.String()
+}
+
+func (r *Redactor) mask(d *Detector, val string) string {
+	switch r.opts.mode {
+	case MaskPartial:
+		if d.partial != nil {
+			return d.partial(val)
+		}
+		return partialKeepLast(4)(val)
+	case MaskHMAC:
+		mac := hmac.New(sha256.New, r.opts.key)
+		mac.Write([]byte(val))
+		return "[" + d.name + ":" + hex.EncodeToString(mac.Sum(nil)[:8]) + "]"
+	default:
+		return "[REDACTED:" + d.name + "]"
+	}
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// maskAlnum replaces letters and digits with '*', preserving other characters so the format of the value is still recognizable.
+func maskAlnum(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if isAlnum(c) {
+			b[i] = '*'
+		}
+	}
+	return string(b)
+}
+
+// partialKeepLast masks all letters and digits except the last n, or all of them if the value is short.
+func partialKeepLast(n int) func(string) string {
+	return func(s string) string {
+		var count int
+		for i := 0; i < len(s); i++ {
+			if isAlnum(s[i]) {
+				count++
+			}
+		}
+		if count < 2*n {
+			return maskAlnum(s)
+		}
+		b := []byte(s)
+		for i := range b {
+			if !isAlnum(b[i]) {
+				continue
+			}
+			if count <= n {
+				break
+			}
+			b[i] = '*'
+			count--
+		}
+		return string(b)
+	}
+}
+
+func partialEmail(s string) string {
+	at := strings.LastIndexByte(s, '@')
+	if at <= 0 {
+		return maskAlnum(s)
+	}
+	return s[:1] + strings.Repeat("*", at-1) + s[at:]
+}
+
+func partialIPv4(s string) string {
+	parts := strings.Split(s, ".")
+	for i := 2; i < len(parts); i++ {
+		parts[i] = "*"
+	}
+	return strings.Join(parts, ".")
+}
+
+func partialIPv6(s string) string {
+	parts := strings.Split(s, ":")
+	kept := 0
+	for i, p := range parts {
+		if len(p) == 0 {
+			continue
+		}
+		if kept < 2 {
+			kept++
+			continue
+		}
+		parts[i] = "*"
+	}
+	return strings.Join(parts, ":")
+}
+
+func partialJWT(s string) string {
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		return maskAlnum(s)
+	}
+	return s[:dot] + ".***.***"
+}
+
+func partialAWSKey(s string) string {
+	if len(s) < 8 {
+		return maskAlnum(s)
+	}
+	return s[:4] + strings.Repeat("*", len(s)-8) + s[len(s)-4:]
+}
+
+func validIPv6(s string, loc []int) bool {
+	start, end := loc[0], loc[1]
+	if start > 0 && (isAlnum(s[start-1]) || s[start-1] == ':') {
+		return false
+	}
+	if end < len(s) && (isAlnum(s[end]) || s[end] == ':') {
+		return false
+	}
+	val := s[start:end]
+	return val != "::" && net.ParseIP(val) != nil
+}
+
+func validCreditCard(s string, loc []int) bool {
+	var digits []int
+	for _, c := range s[loc[0]:loc[1]] {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	return luhn(digits)
+}
+
+// luhn validates a number with the Luhn checksum used by payment cards.
+func luhn(digits []int) bool {
+	var sum int
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
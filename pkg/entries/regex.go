@@ -0,0 +1,61 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrNoNamedGroups = errors.New("pattern has no named capture groups")
+	ErrNoMatch       = errors.New("pattern does not match")
+)
+
+func init() {
+	RegisterParser("regex", func(args ...string) (Parser, error) {
+		if err := parserArgs("regex", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return NewRegexParser(args[0])
+	})
+}
+
+// RegexParser is a Parser that maps the named capture groups of a regular expression to fields.
+// Groups that don't participate in a match are not set.
+type RegexParser struct {
+	pattern *regexp.Regexp
+}
+
+// NewRegexParser creates a RegexParser with a pattern that must have at least one named capture group, like '(?P<name>\w+)'.
+func NewRegexParser(pattern string) (*RegexParser, error) {
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParserArgs, err)
+	}
+	var named bool
+	for _, name := range r.SubexpNames() {
+		if len(name) > 0 {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return nil, ErrNoNamedGroups
+	}
+	return &RegexParser{pattern: r}, nil
+}
+
+func (p *RegexParser) Parse(s string) (LogEntry, error) {
+	match := p.pattern.FindStringSubmatchIndex(s)
+	if match == nil {
+		return nil, ErrNoMatch
+	}
+	entry := LogEntry{}
+	for i, name := range p.pattern.SubexpNames() {
+		if len(name) == 0 || match[2*i] < 0 {
+			continue
+		}
+		entry[name] = s[match[2*i]:match[2*i+1]]
+	}
+	return entry, nil
+}
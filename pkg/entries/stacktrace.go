@@ -0,0 +1,325 @@
+package entries
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	StackLanguageJava   = "java"
+	StackLanguageGo     = "go"
+	StackLanguagePython = "python"
+)
+
+var (
+	ErrNoStackTrace = errors.New("no stack trace found")
+
+	javaFramePattern     = regexp.MustCompile(`^\s+at\s+(\S+?)\((.*)\)\s*$`)
+	javaCausedByPattern  = regexp.MustCompile(`^\s*Caused by:\s*(.*)$`)
+	javaThreadPattern    = regexp.MustCompile(`^Exception in thread "([^"]*)"\s+`)
+	javaTypePattern      = regexp.MustCompile(`^[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*$`)
+	goroutinePattern     = regexp.MustCompile(`^goroutine (\d+) \[([^\]]*)\]:\s*$`)
+	goFileLinePattern    = regexp.MustCompile(`^\s+(.+\.\w+):(\d+)(?: \+0x[0-9a-f]+)?\s*$`)
+	pythonFramePattern   = regexp.MustCompile(`^\s+File "([^"]*)", line (\d+)(?:, in (.*))?$`)
+	pythonTracebackStart = "Traceback (most recent call last):"
+)
+
+func init() {
+	RegisterParser("stacktrace", func(args ...string) (Parser, error) {
+		for _, lang := range args {
+			switch lang {
+			case StackLanguageJava, StackLanguageGo, StackLanguagePython:
+			default:
+				return nil, fmt.Errorf("%w: unknown stack trace language '%s'", ErrParserArgs, lang)
+			}
+		}
+		return &StackTraceParser{Languages: args}, nil
+	})
+}
+
+// StackTraceParser is a Parser for Java exceptions, Go panics, and Python tracebacks that have been joined into a single message, like with iterator.Joiner.
+// The stack trace may be preceded by other text, like the log line that reported the error.
+// If no stack trace is found, then ErrNoStackTrace is returned.
+//
+// The following fields are set.
+//   - stack_language: the detected language, which is one of java, go, or python.
+//   - exception_type: the exception class, like "java.lang.IllegalStateException" or "ValueError". Go panics use "panic" or "fatal error".
+//   - exception_message: the exception message, if any.
+//   - frames: an array of objects with the function, file, and line of each frame. Frames are always ordered from the innermost call outward.
+//   - caused_by: an array of objects with the type, message, and frames of each underlying cause, starting with the nearest cause. Only set if there are causes.
+//   - goroutine_id and goroutine_state: the goroutine that panicked, in Go stack traces.
+//   - goroutine_ids: the IDs of all goroutines in a Go stack dump.
+//   - stack_fingerprint: a hash of the exception types and frame functions, which is the same for identical crashes even if messages and line numbers differ.
+type StackTraceParser struct {
+	// Languages limits detection to the given languages. All languages are detected by default.
+	Languages []string
+}
+
+type stackException struct {
+	typ     string
+	message string
+	frames  []stackFrame
+}
+
+type stackFrame struct {
+	function string
+	file     string
+	line     int64
+}
+
+func (p *StackTraceParser) detects(lang string) bool {
+	if len(p.Languages) == 0 {
+		return true
+	}
+	for _, l := range p.Languages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *StackTraceParser) Parse(s string) (LogEntry, error) {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	entry := LogEntry{}
+	var exceptions []stackException
+	switch {
+	case p.detects(StackLanguagePython) && strings.Contains(s, pythonTracebackStart):
+		entry["stack_language"] = StackLanguagePython
+		exceptions = parsePythonTrace(lines)
+	case p.detects(StackLanguageGo) && containsLine(lines, goroutinePattern):
+		entry["stack_language"] = StackLanguageGo
+		exceptions = parseGoTrace(lines, entry)
+	case p.detects(StackLanguageJava) && containsLine(lines, javaFramePattern):
+		entry["stack_language"] = StackLanguageJava
+		exceptions = parseJavaTrace(lines)
+	}
+	if len(exceptions) == 0 {
+		return nil, ErrNoStackTrace
+	}
+
+	main := exceptions[0]
+	entry["exception_type"] = main.typ
+	if len(main.message) > 0 {
+		entry["exception_message"] = main.message
+	}
+	entry["frames"] = framesValue(main.frames)
+	if len(exceptions) > 1 {
+		causes := make([]any, len(exceptions)-1)
+		for i, ex := range exceptions[1:] {
+			cause := map[string]any{
+				"type":   ex.typ,
+				"frames": framesValue(ex.frames),
+			}
+			if len(ex.message) > 0 {
+				cause["message"] = ex.message
+			}
+			causes[i] = cause
+		}
+		entry["caused_by"] = causes
+	}
+	entry["stack_fingerprint"] = stackFingerprint(entry["stack_language"].(string), exceptions)
+	return entry, nil
+}
+
+func containsLine(lines []string, pattern *regexp.Regexp) bool {
+	for _, line := range lines {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func framesValue(frames []stackFrame) []any {
+	val := make([]any, len(frames))
+	for i, f := range frames {
+		frame := map[string]any{
+			"function": f.function,
+		}
+		if len(f.file) > 0 {
+			frame["file"] = f.file
+		}
+		if f.line > 0 {
+			frame["line"] = f.line
+		}
+		val[i] = frame
+	}
+	return val
+}
+
+// stackFingerprint hashes the parts of a stack trace that identify where a crash happened, ignoring messages and line numbers since they often change between occurrences and releases.
+func stackFingerprint(lang string, exceptions []stackException) string {
+	h := sha256.New()
+	h.Write([]byte(lang))
+	for _, ex := range exceptions {
+		h.Write([]byte("\n" + ex.typ))
+		for _, f := range ex.frames {
+			h.Write([]byte("\n\t" + f.function))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// splitException splits a line like "java.io.IOException: disk full" into a type and message.
+func splitException(s string) (typ string, msg string, ok bool) {
+	s = strings.TrimSpace(s)
+	typ, msg = s, ""
+	if idx := strings.Index(s, ":"); idx >= 0 {
+		typ, msg = s[:idx], strings.TrimSpace(s[idx+1:])
+	}
+	return typ, msg, javaTypePattern.MatchString(typ)
+}
+
+func parseJavaTrace(lines []string) []stackException {
+	var (
+		exceptions []stackException
+		cur        *stackException
+	)
+	for i, line := range lines {
+		if m := javaFramePattern.FindStringSubmatch(line); m != nil {
+			if cur == nil {
+				// The exception header is the line just before the first frame.
+				header := ""
+				if i > 0 {
+					header = javaThreadPattern.ReplaceAllString(lines[i-1], "")
+				}
+				typ, msg, ok := splitException(header)
+				if !ok {
+					typ, msg = "", strings.TrimSpace(header)
+				}
+				exceptions = append(exceptions, stackException{typ: typ, message: msg})
+				cur = &exceptions[len(exceptions)-1]
+			}
+			cur.frames = append(cur.frames, parseJavaFrame(m[1], m[2]))
+			continue
+		}
+		if m := javaCausedByPattern.FindStringSubmatch(line); m != nil && cur != nil {
+			typ, msg, _ := splitException(m[1])
+			exceptions = append(exceptions, stackException{typ: typ, message: msg})
+			cur = &exceptions[len(exceptions)-1]
+		}
+	}
+	return exceptions
+}
+
+func parseJavaFrame(function, location string) stackFrame {
+	frame := stackFrame{function: function, file: location}
+	if idx := strings.LastIndexByte(location, ':'); idx >= 0 {
+		if line, err := strconv.ParseInt(location[idx+1:], 10, 64); err == nil {
+			frame.file, frame.line = location[:idx], line
+		}
+	}
+	return frame
+}
+
+func parseGoTrace(lines []string, entry LogEntry) []stackException {
+	var (
+		exceptions []stackException
+		ids        []any
+		frames     []stackFrame
+		inMain     bool
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "panic: "):
+			exceptions = append(exceptions, stackException{typ: "panic", message: strings.TrimPrefix(trimmed, "panic: ")})
+			continue
+		case strings.HasPrefix(trimmed, "fatal error: "):
+			exceptions = append(exceptions, stackException{typ: "fatal error", message: strings.TrimPrefix(trimmed, "fatal error: ")})
+			continue
+		}
+		if m := goroutinePattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.ParseInt(m[1], 10, 64)
+			ids = append(ids, id)
+			inMain = len(ids) == 1
+			if inMain {
+				entry["goroutine_id"] = id
+				entry["goroutine_state"] = m[2]
+			}
+			continue
+		}
+		if !inMain {
+			continue
+		}
+		if len(trimmed) == 0 {
+			inMain = false
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") || !strings.HasSuffix(trimmed, ")") || i+1 >= len(lines) {
+			continue
+		}
+		m := goFileLinePattern.FindStringSubmatch(lines[i+1])
+		if m == nil {
+			continue
+		}
+		function := trimmed
+		if idx := strings.LastIndexByte(function, '('); idx > 0 {
+			function = function[:idx]
+		}
+		lineNum, _ := strconv.ParseInt(m[2], 10, 64)
+		frames = append(frames, stackFrame{function: function, file: m[1], line: lineNum})
+		i++
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	entry["goroutine_ids"] = ids
+	if len(exceptions) == 0 {
+		exceptions = append(exceptions, stackException{typ: "goroutine dump"})
+	}
+	// Later panics happened while handling the first, so the first panic is the nearest cause of the crash.
+	for i, j := 0, len(exceptions)-1; i < j; i, j = i+1, j-1 {
+		exceptions[i], exceptions[j] = exceptions[j], exceptions[i]
+	}
+	exceptions[0].frames = frames
+	return exceptions
+}
+
+func parsePythonTrace(lines []string) []stackException {
+	var (
+		exceptions []stackException
+		frames     []stackFrame
+		inTrace    bool
+	)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == pythonTracebackStart {
+			inTrace, frames = true, nil
+			continue
+		}
+		if !inTrace {
+			continue
+		}
+		if m := pythonFramePattern.FindStringSubmatch(line); m != nil {
+			lineNum, _ := strconv.ParseInt(m[2], 10, 64)
+			frames = append(frames, stackFrame{function: m[3], file: m[1], line: lineNum})
+			continue
+		}
+		if len(line) == 0 || line[0] == ' ' || line[0] == '\t' {
+			// Source lines and carets are indented under each frame.
+			continue
+		}
+		typ, msg, ok := splitException(line)
+		if !ok {
+			continue
+		}
+		// Python prints the most recent call last, so frames are reversed to be innermost first.
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+		exceptions = append(exceptions, stackException{typ: typ, message: msg, frames: frames})
+		inTrace, frames = false, nil
+	}
+	// Chained tracebacks are printed with the original cause first, and the exception that was actually raised last.
+	for i, j := 0, len(exceptions)-1; i < j; i, j = i+1, j-1 {
+		exceptions[i], exceptions[j] = exceptions[j], exceptions[i]
+	}
+	return exceptions
+}
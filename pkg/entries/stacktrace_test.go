@@ -0,0 +1,132 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStackTraceParser(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected LogEntry
+	}{
+		"Java": {
+			input: "2023-04-01 12:00:00 ERROR Request failed\n" +
+				"Exception in thread \"main\" java.lang.IllegalStateException: could not save\n" +
+				"\tat com.example.App.save(App.java:42)\n" +
+				"\tat com.example.App.main(App.java:10)\n" +
+				"Caused by: java.io.IOException: disk full\n" +
+				"\tat com.example.Store.write(Native Method)\n" +
+				"\t... 2 more",
+			expected: LogEntry{
+				"stack_language":    "java",
+				"exception_type":    "java.lang.IllegalStateException",
+				"exception_message": "could not save",
+				"frames": []any{
+					map[string]any{"function": "com.example.App.save", "file": "App.java", "line": int64(42)},
+					map[string]any{"function": "com.example.App.main", "file": "App.java", "line": int64(10)},
+				},
+				"caused_by": []any{
+					map[string]any{
+						"type":    "java.io.IOException",
+						"message": "disk full",
+						"frames": []any{
+							map[string]any{"function": "com.example.Store.write", "file": "Native Method"},
+						},
+					},
+				},
+			},
+		},
+		"Go": {
+			input: "panic: runtime error: index out of range [5] with length 3\n\n" +
+				"goroutine 1 [running]:\n" +
+				"main.(*Server).handle(0xc000010000, {0x4b1f28, 0x3})\n" +
+				"\t/app/server.go:27 +0x1d\n" +
+				"main.main()\n" +
+				"\t/app/main.go:8 +0x25\n\n" +
+				"goroutine 7 [chan receive]:\n" +
+				"main.worker()\n" +
+				"\t/app/worker.go:12 +0x3a\n" +
+				"exit status 2",
+			expected: LogEntry{
+				"stack_language":    "go",
+				"exception_type":    "panic",
+				"exception_message": "runtime error: index out of range [5] with length 3",
+				"goroutine_id":      int64(1),
+				"goroutine_state":   "running",
+				"goroutine_ids":     []any{int64(1), int64(7)},
+				"frames": []any{
+					map[string]any{"function": "main.(*Server).handle", "file": "/app/server.go", "line": int64(27)},
+					map[string]any{"function": "main.main", "file": "/app/main.go", "line": int64(8)},
+				},
+			},
+		},
+		"Python chained": {
+			input: "Traceback (most recent call last):\n" +
+				"  File \"/app/db.py\", line 5, in connect\n" +
+				"    raise OSError(\"refused\")\n" +
+				"OSError: refused\n\n" +
+				"The above exception was the direct cause of the following exception:\n\n" +
+				"Traceback (most recent call last):\n" +
+				"  File \"/app/main.py\", line 10, in <module>\n" +
+				"    main()\n" +
+				"  File \"/app/main.py\", line 6, in main\n" +
+				"    connect()\n" +
+				"app.errors.StartupError: database unavailable",
+			expected: LogEntry{
+				"stack_language":    "python",
+				"exception_type":    "app.errors.StartupError",
+				"exception_message": "database unavailable",
+				"frames": []any{
+					map[string]any{"function": "main", "file": "/app/main.py", "line": int64(6)},
+					map[string]any{"function": "<module>", "file": "/app/main.py", "line": int64(10)},
+				},
+				"caused_by": []any{
+					map[string]any{
+						"type":    "OSError",
+						"message": "refused",
+						"frames": []any{
+							map[string]any{"function": "connect", "file": "/app/db.py", "line": int64(5)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParser("stacktrace")
+			require.NoError(t, err)
+			entry, err := p.Parse(tc.input)
+			require.NoError(t, err)
+			assert.Len(t, entry["stack_fingerprint"], 16)
+			delete(entry, "stack_fingerprint")
+			assert.Equal(t, tc.expected, entry)
+		})
+	}
+}
+
+func TestStackTraceParser_Fingerprint(t *testing.T) {
+	p := new(StackTraceParser)
+	a, err := p.Parse("java.lang.NullPointerException: user 1\n\tat com.example.App.run(App.java:42)")
+	require.NoError(t, err)
+	b, err := p.Parse("java.lang.NullPointerException: user 2\n\tat com.example.App.run(App.java:57)")
+	require.NoError(t, err)
+	c, err := p.Parse("java.lang.NullPointerException: user 1\n\tat com.example.App.stop(App.java:42)")
+	require.NoError(t, err)
+	assert.Equal(t, a["stack_fingerprint"], b["stack_fingerprint"], "Messages and line numbers should not affect the fingerprint")
+	assert.NotEqual(t, a["stack_fingerprint"], c["stack_fingerprint"])
+}
+
+func TestStackTraceParser_Errors(t *testing.T) {
+	_, err := new(StackTraceParser).Parse("just a regular message")
+	assert.ErrorIs(t, err, ErrNoStackTrace)
+	p := &StackTraceParser{Languages: []string{StackLanguagePython}}
+	_, err = p.Parse("java.lang.NullPointerException\n\tat com.example.App.run(App.java:42)")
+	assert.ErrorIs(t, err, ErrNoStackTrace)
+	_, err = NewParser("stacktrace", "rust")
+	assert.ErrorIs(t, err, ErrParserArgs)
+}
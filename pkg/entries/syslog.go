@@ -0,0 +1,261 @@
+package entries
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSyslog = errors.New("invalid syslog message")
+
+	syslogFacilities = [...]string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+		"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+	}
+)
+
+func init() {
+	RegisterParser("syslog", func(args ...string) (Parser, error) {
+		if err := parserArgs("syslog", args, 0, 1); err != nil {
+			return nil, err
+		}
+		p := NewSyslogParser()
+		if len(args) > 0 {
+			loc, err := time.LoadLocation(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrParserArgs, err)
+			}
+			p.Location = loc
+		}
+		return p, nil
+	})
+}
+
+// SyslogParser is a Parser for RFC 5424 and RFC 3164 (BSD) syslog messages, detecting the format of each line.
+// The PRI part is optional for RFC 3164 messages, since it is often omitted when syslog is written to a file.
+//
+// The following fields are set when present in the message.
+//   - facility: the facility name, like "auth" or "local0".
+//   - severity: the numeric severity, which is also used to set the StandardLevelField with SyslogLevels.
+//   - StandardTimestampField: the message time in UTC.
+//   - host: the hostname of the sender.
+//   - StandardModuleField: the APP-NAME, or the TAG in RFC 3164 messages.
+//   - pid: the PROCID, as a number if possible.
+//   - msgid: the RFC 5424 MSGID.
+//   - structured_data: the RFC 5424 structured data, as a map of SD-IDs to parameter maps.
+//   - StandardMessageField: the free-form message.
+type SyslogParser struct {
+	// Location is used for RFC 3164 timestamps, which don't include a time zone. Defaults to UTC.
+	Location *time.Location
+	// Now is used to infer the year of RFC 3164 timestamps. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewSyslogParser creates a SyslogParser that assumes UTC for RFC 3164 timestamps.
+func NewSyslogParser() *SyslogParser {
+	return &SyslogParser{
+		Location: time.UTC,
+		Now:      time.Now,
+	}
+}
+
+func (p *SyslogParser) Parse(s string) (LogEntry, error) {
+	entry := LogEntry{}
+	rest := s
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: unterminated PRI", ErrInvalidSyslog)
+		}
+		pri, err := strconv.Atoi(rest[1:end])
+		if err != nil || pri < 0 || pri > 191 {
+			return nil, fmt.Errorf("%w: invalid PRI '%s'", ErrInvalidSyslog, rest[1:end])
+		}
+		facility, severity := pri/8, pri%8
+		entry["facility"] = syslogFacilities[facility]
+		entry["severity"] = int64(severity)
+		entry[StandardLevelField] = SyslogLevels[strconv.Itoa(severity)].String()
+		rest = rest[end+1:]
+		if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && strings.IndexByte(rest, ' ') > 0 {
+			if version := rest[:strings.IndexByte(rest, ' ')]; isDigits(version) {
+				return p.parse5424(entry, rest[len(version)+1:])
+			}
+		}
+	}
+	return p.parse3164(entry, rest)
+}
+
+func (p *SyslogParser) parse5424(entry LogEntry, s string) (LogEntry, error) {
+	var headers [5]string
+	for i := range headers {
+		idx := strings.IndexByte(s, ' ')
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: missing RFC 5424 header fields", ErrInvalidSyslog)
+		}
+		headers[i], s = s[:idx], s[idx+1:]
+	}
+	timestamp, host, app, procID, msgID := headers[0], headers[1], headers[2], headers[3], headers[4]
+	if timestamp != "-" {
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp '%s'", ErrInvalidSyslog, timestamp)
+		}
+		entry[StandardTimestampField] = t.UTC().Format(time.RFC3339Nano)
+	}
+	setSyslogField(entry, "host", host)
+	setSyslogField(entry, StandardModuleField, app)
+	setSyslogPID(entry, procID)
+	setSyslogField(entry, "msgid", msgID)
+
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	} else {
+		sd, rest, err := parseStructuredData(s)
+		if err != nil {
+			return nil, err
+		}
+		entry["structured_data"] = sd
+		s = rest
+	}
+	s = strings.TrimPrefix(s, " ")
+	s = strings.TrimPrefix(s, "\ufeff")
+	if len(s) > 0 {
+		entry[StandardMessageField] = s
+	}
+	return entry, nil
+}
+
+func parseStructuredData(s string) (map[string]any, string, error) {
+	sd := map[string]any{}
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		idEnd := strings.IndexAny(s, " ]")
+		if idEnd < 0 {
+			return nil, "", fmt.Errorf("%w: unterminated structured data", ErrInvalidSyslog)
+		}
+		params := map[string]any{}
+		sd[s[:idEnd]] = params
+		s = s[idEnd:]
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return nil, "", fmt.Errorf("%w: invalid structured data parameter", ErrInvalidSyslog)
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+			var (
+				buf    strings.Builder
+				closed bool
+			)
+			for i := 0; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				} else if s[i] == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				buf.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, "", fmt.Errorf("%w: unterminated structured data parameter", ErrInvalidSyslog)
+			}
+			params[name] = buf.String()
+		}
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("%w: unterminated structured data", ErrInvalidSyslog)
+		}
+		s = s[1:]
+	}
+	return sd, s, nil
+}
+
+func (p *SyslogParser) parse3164(entry LogEntry, s string) (LogEntry, error) {
+	t, rest, err := p.parse3164Timestamp(s)
+	if err != nil {
+		return nil, err
+	}
+	entry[StandardTimestampField] = t.UTC().Format(time.RFC3339Nano)
+
+	idx := strings.IndexByte(rest, ' ')
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: missing hostname", ErrInvalidSyslog)
+	}
+	entry["host"], rest = rest[:idx], rest[idx+1:]
+
+	// The TAG is optional, and ends at the first character that isn't alphanumeric, or a few common separators.
+	tagEnd := strings.IndexFunc(rest, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./", r))
+	})
+	if tagEnd > 0 && (rest[tagEnd] == ':' || rest[tagEnd] == '[') {
+		entry[StandardModuleField] = rest[:tagEnd]
+		rest = rest[tagEnd:]
+		if strings.HasPrefix(rest, "[") {
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				setSyslogPID(entry, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+		rest = strings.TrimPrefix(rest, ":")
+	}
+	rest = strings.TrimPrefix(rest, " ")
+	if len(rest) > 0 {
+		entry[StandardMessageField] = rest
+	}
+	return entry, nil
+}
+
+// parse3164Timestamp reads either a BSD timestamp like "Jan _2 15:04:05", or an RFC 3339 timestamp used by some syslog daemons.
+// BSD timestamps don't include a year, so the year is inferred such that the timestamp isn't far in the future.
+func (p *SyslogParser) parse3164Timestamp(s string) (time.Time, string, error) {
+	if idx := strings.IndexByte(s, ' '); idx > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, s[:idx]); err == nil {
+			return t, s[idx+1:], nil
+		}
+	}
+	const layout = time.Stamp
+	if len(s) < len(layout)+1 {
+		return time.Time{}, "", fmt.Errorf("%w: missing timestamp", ErrInvalidSyslog)
+	}
+	loc := p.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	t, err := time.ParseInLocation(layout, s[:len(layout)], loc)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("%w: invalid timestamp '%s'", ErrInvalidSyslog, s[:len(layout)])
+	}
+	return inferYear(t, now()), s[len(layout)+1:], nil
+}
+
+func setSyslogField(entry LogEntry, field, val string) {
+	if val != "-" && len(val) > 0 {
+		entry[field] = val
+	}
+}
+
+func setSyslogPID(entry LogEntry, val string) {
+	if pid, err := strconv.ParseInt(val, 10, 64); err == nil {
+		entry["pid"] = pid
+		return
+	}
+	setSyslogField(entry, "pid", val)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(s) > 0
+}
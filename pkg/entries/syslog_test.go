@@ -0,0 +1,117 @@
+package entries
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSyslogParser(t *testing.T) {
+	p := NewSyslogParser()
+	p.Now = func() time.Time {
+		return time.Date(2023, time.January, 15, 0, 0, 0, 0, time.UTC)
+	}
+	tests := map[string]struct {
+		input    string
+		expected LogEntry
+		err      error
+	}{
+		"RFC 5424": {
+			input: `<165>1 2023-04-01T12:00:00.003-05:00 mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication"][origin ip="10.0.0.1"] An application event`,
+			expected: LogEntry{
+				"facility":             "local4",
+				"severity":             int64(5),
+				StandardLevelField:     "notice",
+				StandardTimestampField: "2023-04-01T17:00:00.003Z",
+				"host":                 "mymachine.example.com",
+				StandardModuleField:    "evntslog",
+				"pid":                  int64(1234),
+				"msgid":                "ID47",
+				"structured_data": map[string]any{
+					"exampleSDID@32473": map[string]any{"iut": "3", "eventSource": `App"lication`},
+					"origin":            map[string]any{"ip": "10.0.0.1"},
+				},
+				StandardMessageField: "An application event",
+			},
+		},
+		"RFC 5424 nil values": {
+			input: `<34>1 - - su - - -`,
+			expected: LogEntry{
+				"facility":          "auth",
+				"severity":          int64(2),
+				StandardLevelField:  "fatal",
+				StandardModuleField: "su",
+			},
+		},
+		"RFC 3164": {
+			input: `<38>Dec 31 23:59:59 web-1 sshd[4321]: Accepted publickey for deploy`,
+			expected: LogEntry{
+				"facility":             "auth",
+				"severity":             int64(6),
+				StandardLevelField:     "info",
+				StandardTimestampField: "2022-12-31T23:59:59Z",
+				"host":                 "web-1",
+				StandardModuleField:    "sshd",
+				"pid":                  int64(4321),
+				StandardMessageField:   "Accepted publickey for deploy",
+			},
+		},
+		"RFC 3164 without PRI": {
+			input: `Jan  2 03:04:05 db kernel: Out of memory`,
+			expected: LogEntry{
+				StandardTimestampField: "2023-01-02T03:04:05Z",
+				"host":                 "db",
+				StandardModuleField:    "kernel",
+				StandardMessageField:   "Out of memory",
+			},
+		},
+		"RFC 3164 with RFC 3339 timestamp": {
+			input: `<13>2023-04-01T12:00:00+00:00 host1 no tag here`,
+			expected: LogEntry{
+				"facility":             "user",
+				"severity":             int64(5),
+				StandardLevelField:     "notice",
+				StandardTimestampField: "2023-04-01T12:00:00Z",
+				"host":                 "host1",
+				StandardMessageField:   "no tag here",
+			},
+		},
+		"Invalid PRI": {
+			input: `<999>1 - - - - - -`,
+			err:   ErrInvalidSyslog,
+		},
+		"Invalid timestamp": {
+			input: `not syslog at all`,
+			err:   ErrInvalidSyslog,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			entry, err := p.Parse(tc.input)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, entry)
+		})
+	}
+}
+
+func TestSyslogParser_Location(t *testing.T) {
+	p, err := NewParser("syslog", "America/Chicago")
+	require.NoError(t, err)
+	sp := p.(*SyslogParser)
+	sp.Now = func() time.Time {
+		return time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)
+	}
+	entry, err := sp.Parse("May  1 12:00:00 host app: message")
+	require.NoError(t, err)
+	assert.Equal(t, "2023-05-01T17:00:00Z", entry[StandardTimestampField])
+
+	_, err = NewParser("syslog", "Not/AZone")
+	assert.ErrorIs(t, err, ErrParserArgs)
+}
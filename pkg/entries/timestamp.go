@@ -0,0 +1,221 @@
+package entries
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// DefaultTimestampFields are the fields that are checked for a timestamp, in order, unless overridden with TimestampFields.
+	DefaultTimestampFields = []string{StandardTimestampField, "timestamp", "time", "ts", "@t", "datetime", "date"}
+
+	// timestampLayouts are tried in order when detecting the format of a string timestamp.
+	// Go will parse fractional seconds even when the layout doesn't specify them.
+	timestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02 15:04:05Z0700",
+		"2006-01-02 15:04:05 Z0700",
+		"2006-01-02 15:04:05 -0700 MST",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006/01/02 15:04:05",
+		"2006/01/02T15:04:05",
+		"02/Jan/2006:15:04:05 -0700",
+		"02/Jan/2006:15:04:05",
+		"01/02/2006 15:04:05",
+		"02-Jan-2006 15:04:05",
+		"Jan _2 2006 15:04:05",
+		"Jan _2, 2006 15:04:05",
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC850,
+		time.RFC822Z,
+		time.RFC822,
+		time.RubyDate,
+		time.UnixDate,
+		time.ANSIC,
+		time.Stamp,
+		"2006-01-02",
+	}
+)
+
+type timestampOpts struct {
+	fields   []string
+	layouts  []string
+	location *time.Location
+	now      func() time.Time
+}
+
+// TimestampOpt represents a functional option for NormalizeTimestamp and ParseTimestamp.
+type TimestampOpt func(opts *timestampOpts)
+
+// TimestampFields specifies the candidate fields to check for a timestamp, in order of preference.
+func TimestampFields(fields ...string) TimestampOpt {
+	return func(opts *timestampOpts) {
+		opts.fields = fields
+	}
+}
+
+// TimestampLayouts specifies Go reference layouts that will be tried before the built-in layouts.
+func TimestampLayouts(layouts ...string) TimestampOpt {
+	return func(opts *timestampOpts) {
+		opts.layouts = layouts
+	}
+}
+
+// TimestampLocation specifies the time zone to assume for timestamps that don't include one. Defaults to UTC.
+func TimestampLocation(loc *time.Location) TimestampOpt {
+	return func(opts *timestampOpts) {
+		opts.location = loc
+	}
+}
+
+// TimestampNow specifies the function used to get the current time, which is used to infer the year of timestamps that don't include one.
+func TimestampNow(now func() time.Time) TimestampOpt {
+	return func(opts *timestampOpts) {
+		opts.now = now
+	}
+}
+
+func newTimestampOpts(opt []TimestampOpt) *timestampOpts {
+	opts := &timestampOpts{
+		fields:   DefaultTimestampFields,
+		location: time.UTC,
+		now:      time.Now,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	return opts
+}
+
+// NormalizeTimestamp detects a timestamp in the first candidate field that has one, and writes it to the StandardTimestampField as an RFC 3339 string in UTC.
+// Candidate fields default to DefaultTimestampFields, and values are interpreted as described in ParseTimestamp.
+// If no timestamp can be found, then the entry is returned unchanged.
+func NormalizeTimestamp(entry LogEntry, opt ...TimestampOpt) LogEntry {
+	opts := newTimestampOpts(opt)
+	for _, field := range opts.fields {
+		val, ok := entry.Get(field)
+		if !ok {
+			continue
+		}
+		if t, ok := parseTimestamp(val, opts); ok {
+			entry[StandardTimestampField] = t.Format(time.RFC3339Nano)
+			return entry
+		}
+	}
+	return entry
+}
+
+// ParseTimestamp interprets a value as a time, returning it in UTC.
+//   - A time.Time is used as-is.
+//   - Numbers, and strings of digits, are treated as a Unix epoch timestamp. The unit is inferred from the magnitude of the number, and may be seconds, milliseconds, microseconds, or nanoseconds.
+//   - Other strings are parsed with any layouts given by TimestampLayouts, then with a list of common layouts.
+//
+// Timestamps without a time zone are assumed to be in the location given by TimestampLocation, or UTC.
+// Timestamps without a year - like those in BSD syslog - are assumed to be in the current year, unless that would put them more than a month in the future.
+func ParseTimestamp(val any, opt ...TimestampOpt) (time.Time, bool) {
+	return parseTimestamp(val, newTimestampOpts(opt))
+}
+
+func parseTimestamp(val any, opts *timestampOpts) (time.Time, bool) {
+	switch v := val.(type) {
+	case time.Time:
+		return v.UTC(), true
+	case string:
+		return parseTimestampString(v, opts)
+	case json.Number:
+		return parseTimestampString(v.String(), opts)
+	}
+	e := LogEntry{"val": val}
+	if i, ok := e.AsInt("val"); ok {
+		return epochInt(i), true
+	}
+	if u, ok := e.AsUint("val"); ok && u <= math.MaxInt64 {
+		return epochInt(int64(u)), true
+	}
+	if f, ok := e.AsFloat("val"); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return epochFloat(f), true
+	}
+	return time.Time{}, false
+}
+
+func parseTimestampString(s string, opts *timestampOpts) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return time.Time{}, false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return epochInt(i), true
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) && !strings.ContainsAny(s, "eEnN") {
+		return epochFloat(f), true
+	}
+	for _, layouts := range [][]string{opts.layouts, timestampLayouts} {
+		for _, layout := range layouts {
+			t, err := time.ParseInLocation(layout, s, opts.location)
+			if err != nil {
+				continue
+			}
+			if t.Year() == 0 && !strings.Contains(layout, "2006") {
+				t = inferYear(t, opts.now())
+			}
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// epochInt converts a Unix timestamp in an inferred unit to a time, without losing the precision of nanosecond timestamps.
+func epochInt(i int64) time.Time {
+	abs := i
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < 1e11:
+		return time.Unix(i, 0).UTC()
+	case abs < 1e14:
+		return time.Unix(0, i*int64(time.Millisecond)).UTC()
+	case abs < 1e17:
+		return time.Unix(0, i*int64(time.Microsecond)).UTC()
+	default:
+		return time.Unix(0, i).UTC()
+	}
+}
+
+// epochFloat converts a Unix timestamp in an inferred unit to a time, which may include fractional units.
+// Whole numbers are converted with epochInt, since floating point math would otherwise introduce errors in sub-second units.
+func epochFloat(f float64) time.Time {
+	if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return epochInt(int64(f))
+	}
+	abs := math.Abs(f)
+	switch {
+	case abs < 1e11:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC()
+	case abs < 1e14:
+		return time.Unix(0, int64(f*1e6)).UTC()
+	case abs < 1e17:
+		return time.Unix(0, int64(f*1e3)).UTC()
+	default:
+		return time.Unix(0, int64(f)).UTC()
+	}
+}
+
+// inferYear sets the year of a time that was parsed without one.
+// The current year is assumed, unless that would put the time more than a month in the future, in which case the previous year is used.
+func inferYear(t time.Time, now time.Time) time.Time {
+	now = now.In(t.Location())
+	year := now.Year()
+	if time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).After(now.AddDate(0, 1, 0)) {
+		year--
+	}
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
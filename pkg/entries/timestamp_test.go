@@ -0,0 +1,70 @@
+package entries
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	require.NoError(t, err)
+	now := TimestampNow(func() time.Time {
+		return time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+	})
+	tests := map[string]struct {
+		val      any
+		opts     []TimestampOpt
+		expected string
+	}{
+		"RFC 3339":               {val: "2023-04-01T12:00:00.5-05:00", expected: "2023-04-01T17:00:00.5Z"},
+		"Space separated":        {val: "2023-04-01 12:00:00,123", expected: "2023-04-01T12:00:00.123Z"},
+		"Slashes":                {val: "2023/04/01 12:00:00", expected: "2023-04-01T12:00:00Z"},
+		"Access log":             {val: "01/Apr/2023:12:00:00 +0200", expected: "2023-04-01T10:00:00Z"},
+		"RFC 1123":               {val: "Sat, 01 Apr 2023 12:00:00 GMT", expected: "2023-04-01T12:00:00Z"},
+		"Syslog":                 {val: "Jan  5 01:02:03", opts: []TimestampOpt{now}, expected: "2023-01-05T01:02:03Z"},
+		"Syslog previous year":   {val: "Dec 31 23:59:59", opts: []TimestampOpt{now}, expected: "2022-12-31T23:59:59Z"},
+		"Default zone":           {val: "2023-07-01 12:00:00", opts: []TimestampOpt{TimestampLocation(chicago)}, expected: "2023-07-01T17:00:00Z"},
+		"Explicit zone wins":     {val: "2023-07-01T12:00:00Z", opts: []TimestampOpt{TimestampLocation(chicago)}, expected: "2023-07-01T12:00:00Z"},
+		"Custom layout":          {val: "12:00:00 01.04.2023", opts: []TimestampOpt{TimestampLayouts("15:04:05 02.01.2006")}, expected: "2023-04-01T12:00:00Z"},
+		"Epoch seconds":          {val: float64(1680350400), expected: "2023-04-01T12:00:00Z"},
+		"Epoch fractional":       {val: 1680350400.25, expected: "2023-04-01T12:00:00.25Z"},
+		"Epoch millis":           {val: int64(1680350400123), expected: "2023-04-01T12:00:00.123Z"},
+		"Epoch micros string":    {val: "1680350400123456", expected: "2023-04-01T12:00:00.123456Z"},
+		"Epoch nanos":            {val: json.Number("1680350400123456789"), expected: "2023-04-01T12:00:00.123456789Z"},
+		"Time value":             {val: time.Date(2023, time.April, 1, 7, 0, 0, 0, chicago), expected: "2023-04-01T12:00:00Z"},
+		"Date only":              {val: "2023-04-01", expected: "2023-04-01T00:00:00Z"},
+		"Surrounding whitespace": {val: " 2023-04-01T12:00:00Z ", expected: "2023-04-01T12:00:00Z"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ts, ok := ParseTimestamp(tc.val, tc.opts...)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, ts.Format(time.RFC3339Nano))
+		})
+	}
+
+	for _, val := range []any{"", "not a time", true, nil, map[string]any{}} {
+		_, ok := ParseTimestamp(val)
+		assert.False(t, ok, "%v should not be parsed as a time", val)
+	}
+}
+
+func TestNormalizeTimestamp(t *testing.T) {
+	entry := NormalizeTimestamp(LogEntry{"ts": float64(1680350400), "time": "2000-01-01T00:00:00Z"})
+	assert.Equal(t, "2000-01-01T00:00:00Z", entry[StandardTimestampField], "Fields should be checked in order")
+	assert.Equal(t, float64(1680350400), entry["ts"], "Source fields should be retained")
+
+	entry = NormalizeTimestamp(LogEntry{"ts": float64(1680350400), "time": "2000-01-01T00:00:00Z"}, TimestampFields("ts"))
+	assert.Equal(t, "2023-04-01T12:00:00Z", entry[StandardTimestampField])
+
+	entry = NormalizeTimestamp(LogEntry{"time": "garbage", "ts": "2023-04-01T12:00:00+01:00"})
+	assert.Equal(t, "2023-04-01T11:00:00Z", entry[StandardTimestampField], "Unparseable fields should be skipped")
+
+	entry = NormalizeTimestamp(LogEntry{StandardTimestampField: "garbage"})
+	assert.Equal(t, LogEntry{StandardTimestampField: "garbage"}, entry, "Entries without a timestamp should be unchanged")
+}
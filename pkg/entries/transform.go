@@ -4,39 +4,63 @@ type SubjectField string
 
 type transFunc func(val any) any
 
-func (fn transFunc) then(after transFunc) transFunc {
+// entryTransFunc is a transform function that may also read the rest of the entry.
+// The given value is nil if the field is not found.
+type entryTransFunc func(entry LogEntry, val any) any
+
+func (fn entryTransFunc) then(after entryTransFunc) entryTransFunc {
 	if fn == nil {
 		return after
 	}
-	return func(val any) any {
-		val = fn(val)
-		return after(val)
+	return func(entry LogEntry, val any) any {
+		val = fn(entry, val)
+		return after(entry, val)
 	}
 }
 
 // TransformSpec contains the transform functions for any given field in a LogEntry.
+// Fields may be field paths like "http.status", as described in LogEntry.Set.
 // If the field is not found or is nil, then the transform function will not be executed.
-type TransformSpec map[SubjectField]transFunc
+type TransformSpec map[SubjectField]entryTransFunc
 
 func NewTransformSpec() TransformSpec {
 	return TransformSpec{}
 }
 
 func (e LogEntry) getSubjectFieldVal(field SubjectField) any {
-	return e[string(field)]
-}
-
-func (e LogEntry) setSubjectField(field SubjectField, val any) {
-	s := string(field)
-	if e.HasField(s) {
-		e[s] = val
-	}
+	val, _ := e.Get(string(field))
+	return val
 }
 
 // Transform will add a field transform.
 // Adding a transform for a field where one is already assigned will append the given transform function to the existing one.
 func (s TransformSpec) Transform(field SubjectField, trans transFunc) TransformSpec {
-	s[field] = s[field].then(trans)
+	if trans == nil {
+		return s
+	}
+	s[field] = s[field].then(func(_ LogEntry, val any) any {
+		if val == nil {
+			return nil
+		}
+		return trans(val)
+	})
+	return s
+}
+
+// Compute will add a field computation, where the value is computed from the whole LogEntry.
+// Unlike a transform function, a computation is run even if the field is not found, so it may create new fields.
+// If the computation returns nil, or the field path conflicts with an existing value, then the field will be left as-is.
+// Fields in the same TransformSpec are transformed in no particular order, so a computation that reads another computed field should be in a later TransformSpec.
+func (s TransformSpec) Compute(field SubjectField, compute func(entry LogEntry) any) TransformSpec {
+	if compute == nil {
+		return s
+	}
+	s[field] = s[field].then(func(entry LogEntry, val any) any {
+		if computed := compute(entry); computed != nil {
+			return computed
+		}
+		return val
+	})
 	return s
 }
 
@@ -61,8 +85,9 @@ func Transform(entry LogEntry, spec TransformSpec) LogEntry {
 			continue
 		}
 		val := entry.getSubjectFieldVal(field)
-		if val != nil {
-			entry.setSubjectField(field, trans(val))
+		result := trans(entry, val)
+		if val != nil || result != nil {
+			_ = entry.Set(string(field), result)
 		}
 	}
 	return entry
@@ -86,3 +86,31 @@ func TestTransform(t *testing.T) {
 		})
 	}
 }
+
+func TestCompute(t *testing.T) {
+	entry := LogEntry{
+		"latency_s": 1.5,
+		"host":      "example.com",
+		"path":      "/index.html",
+	}
+	spec := NewTransformSpec().
+		Compute("latency_ms", func(entry LogEntry) any {
+			s, _ := entry.AsFloat("latency_s")
+			return s * 1000
+		}).
+		Compute("url", func(entry LogEntry) any {
+			return entry.Format("%s%s", "host", "path")
+		}).
+		Compute("host", func(entry LogEntry) any {
+			return nil
+		})
+	result := Transform(entry, spec)
+	result = Transform(result, NewTransformSpec().Compute("slow", func(entry LogEntry) any {
+		ms, _ := entry.AsFloat("latency_ms")
+		return ms > 1000
+	}))
+	assert.Equal(t, 1500.0, result["latency_ms"])
+	assert.Equal(t, "example.com/index.html", result["url"])
+	assert.Equal(t, true, result["slow"], "Computations should see the results of earlier computations")
+	assert.Equal(t, "example.com", result["host"], "A nil computation should leave the field as-is")
+}
@@ -0,0 +1,496 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WindowStartField = "@window_start" // WindowStartField is the start of the window that an aggregated LogEntry summarizes, as an RFC 3339 string
+	WindowEndField   = "@window_end"   // WindowEndField is the end of the window that an aggregated LogEntry summarizes, as an RFC 3339 string
+)
+
+var (
+	ErrInvalidWindow    = errors.New("invalid window")
+	ErrInvalidAggregate = errors.New("invalid aggregate")
+)
+
+// AggregateFunc identifies how an Aggregate combines values.
+type AggregateFunc int
+
+const (
+	// AggregateCount counts entries, or entries that have the field if one is given.
+	AggregateCount AggregateFunc = iota
+	// AggregateSum adds numeric values.
+	AggregateSum
+	// AggregateMin finds the least numeric value.
+	AggregateMin
+	// AggregateMax finds the greatest numeric value.
+	AggregateMax
+	// AggregateAvg finds the mean of numeric values.
+	AggregateAvg
+	// AggregateDistinct counts distinct values, compared as strings.
+	AggregateDistinct
+	// AggregatePercentile finds a percentile of numeric values, interpolating between the closest values.
+	// All values in a window are kept until it closes, so this uses more memory than other aggregates.
+	AggregatePercentile
+)
+
+var aggregateFuncNames = [...]string{"count", "sum", "min", "max", "avg", "distinct", "percentile"}
+
+func (f AggregateFunc) String() string {
+	if f < AggregateCount || int(f) >= len(aggregateFuncNames) {
+		return fmt.Sprintf("AggregateFunc(%d)", int(f))
+	}
+	return aggregateFuncNames[f]
+}
+
+// ParseAggregateFunc returns the AggregateFunc with the given name: count, sum, min, max, avg, distinct, or percentile.
+func ParseAggregateFunc(name string) (AggregateFunc, bool) {
+	for i, n := range aggregateFuncNames {
+		if n == strings.ToLower(name) {
+			return AggregateFunc(i), true
+		}
+	}
+	return AggregateCount, false
+}
+
+// Aggregate describes a value computed over each group of entries in a window.
+// Entries where the field is missing - or isn't a number, for numeric aggregates - aren't included in the value, and the value is omitted if no entries are included.
+type Aggregate struct {
+	Func  AggregateFunc
+	Field string
+	// Percentile is the percentile from 0 to 100 found by AggregatePercentile.
+	Percentile float64
+	// As is the field that the value is written to. Defaults to the result of Name.
+	As string
+}
+
+// Name returns the field that the Aggregate is written to, like "count", "sum_bytes", or "p95_latency".
+func (a Aggregate) Name() string {
+	if len(a.As) > 0 {
+		return a.As
+	}
+	prefix := a.Func.String()
+	if a.Func == AggregatePercentile {
+		prefix = "p" + strings.ReplaceAll(strconv.FormatFloat(a.Percentile, 'f', -1, 64), ".", "_")
+	}
+	if len(a.Field) == 0 {
+		return prefix
+	}
+	return prefix + "_" + strings.ReplaceAll(a.Field, ".", "_")
+}
+
+type aggregateOpts struct {
+	size      time.Duration
+	slide     time.Duration
+	groupBy   []string
+	timeField string
+	timeOpts  []entries.TimestampOpt
+	arrival   bool
+	lateness  time.Duration
+}
+
+// AggregateOpt represents a functional option for Aggregator.
+type AggregateOpt func(opts *aggregateOpts)
+
+// AggregateWindow specifies tumbling windows of the given size, so each entry is in exactly one window.
+// Windows are aligned to the Unix epoch, so one minute windows start on the minute.
+func AggregateWindow(size time.Duration) AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.size = size
+		opts.slide = size
+	}
+}
+
+// AggregateSliding specifies sliding windows of the given size, with a new window starting every slide.
+// Each entry is in every window that covers its time, so a 5 minute window sliding every minute will include each entry 5 times.
+func AggregateSliding(size, slide time.Duration) AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.size = size
+		opts.slide = slide
+	}
+}
+
+// AggregateGroupBy computes aggregates separately for each distinct combination of values in the given fields.
+// The group's values are included in each aggregated entry.
+func AggregateGroupBy(fields ...string) AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.groupBy = fields
+	}
+}
+
+// AggregateTimeField specifies the field that holds each entry's time. Defaults to entries.StandardTimestampField.
+// Values are interpreted with entries.ParseTimestamp, using any given options. Entries without a time are ignored.
+func AggregateTimeField(field string, opt ...entries.TimestampOpt) AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.timeField = field
+		opts.timeOpts = opt
+	}
+}
+
+// AggregateArrivalTime windows entries by the time they're read, rather than by a time field.
+// Windows are emitted as soon as they end, even if no more entries are read.
+func AggregateArrivalTime() AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.arrival = true
+	}
+}
+
+// AggregateLateness specifies how long to wait for entries that arrive out of order before a window is emitted.
+// A window is emitted once an entry is read with a time at least lateness after the window's end, or when the input ends.
+// Entries for windows that have already been emitted are dropped. Defaults to 0.
+func AggregateLateness(lateness time.Duration) AggregateOpt {
+	return func(opts *aggregateOpts) {
+		opts.lateness = lateness
+	}
+}
+
+type aggregateState struct {
+	count    int64
+	sum      float64
+	min      float64
+	max      float64
+	distinct map[string]struct{}
+	values   []float64
+}
+
+type aggregateGroup struct {
+	values []any
+	count  int64
+	states []*aggregateState
+}
+
+type aggregateWindow struct {
+	start  time.Time
+	end    time.Time
+	groups map[string]*aggregateGroup
+	order  []*aggregateGroup
+}
+
+type aggregator struct {
+	aggs   []Aggregate
+	opts   *aggregateOpts
+	open   map[int64]*aggregateWindow
+	closed time.Time
+}
+
+// Aggregator takes control of the input Iterator, and emits a LogEntry for each group in each window with the computed aggregates once the window has closed.
+// Each emitted LogEntry has the window's bounds in WindowStartField and WindowEndField, with the start in entries.StandardTimestampField.
+// Windows with no entries aren't emitted, and any open windows are emitted in order when the input ends.
+// A window size must be given with AggregateWindow or AggregateSliding.
+func Aggregator(iter Iterator, aggs []Aggregate, opt ...AggregateOpt) (Iterator, error) {
+	opts := &aggregateOpts{
+		timeField: entries.StandardTimestampField,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.size <= 0 || opts.slide <= 0 || opts.slide > opts.size {
+		return nil, fmt.Errorf("%w: size must be positive, and slide must be positive and no more than size", ErrInvalidWindow)
+	}
+	if opts.lateness < 0 {
+		return nil, fmt.Errorf("%w: lateness must not be negative", ErrInvalidWindow)
+	}
+	if len(aggs) == 0 {
+		return nil, fmt.Errorf("%w: at least one aggregate is required", ErrInvalidAggregate)
+	}
+	for _, a := range aggs {
+		if a.Func < AggregateCount || a.Func > AggregatePercentile {
+			return nil, fmt.Errorf("%w: unknown function %s", ErrInvalidAggregate, a.Func)
+		}
+		if a.Func != AggregateCount && len(a.Field) == 0 {
+			return nil, fmt.Errorf("%w: %s requires a field", ErrInvalidAggregate, a.Func)
+		}
+		if a.Func == AggregatePercentile && !(a.Percentile >= 0 && a.Percentile <= 100) {
+			return nil, fmt.Errorf("%w: percentile must be from 0 to 100", ErrInvalidAggregate)
+		}
+	}
+	agg := &aggregator{
+		aggs: aggs,
+		opts: opts,
+		open: map[int64]*aggregateWindow{},
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		return agg.run(ctx, iter, emit)
+	}, iter), nil
+}
+
+func (a *aggregator) run(ctx context.Context, iter Iterator, emit func(entry entries.LogEntry) bool) error {
+	reads, stop := readAsync(ctx, iter)
+	defer stop()
+	var (
+		timer *time.Timer
+		// tick is only set for arrival time windows, which close as time passes rather than as entries are read.
+		tick <-chan time.Time
+	)
+	if a.opts.arrival {
+		timer = time.NewTimer(time.Hour)
+		timer.Stop()
+		defer timer.Stop()
+		tick = timer.C
+	}
+	resetWindowTimer := func() {
+		if timer == nil {
+			return
+		}
+		next, _ := a.nextEnd()
+		resetTimer(timer, next)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-tick:
+			if !a.flush(now, emit) {
+				return nil
+			}
+			resetWindowTimer()
+		case res, ok := <-reads:
+			if !ok {
+				return nil
+			}
+			if res.err != nil {
+				if !a.flush(time.Time{}, emit) {
+					return nil
+				}
+				if !IsEnd(res.err) && ctx.Err() == nil {
+					return res.err
+				}
+				return nil
+			}
+			var t time.Time
+			if a.opts.arrival {
+				t = time.Now()
+			} else {
+				val, ok := res.entry.Get(a.opts.timeField)
+				if !ok {
+					continue
+				}
+				if t, ok = entries.ParseTimestamp(val, a.opts.timeOpts...); !ok {
+					continue
+				}
+			}
+			a.add(t, res.entry)
+			if !a.opts.arrival && !a.flush(t.Add(-a.opts.lateness), emit) {
+				return nil
+			}
+			resetWindowTimer()
+		}
+	}
+}
+
+// add includes an entry in every open window that covers t.
+func (a *aggregator) add(t time.Time, entry entries.LogEntry) {
+	var (
+		size  = int64(a.opts.size)
+		slide = int64(a.opts.slide)
+		ns    = t.UnixNano()
+		// last is the start of the latest window that covers t.
+		last = ns - ((ns%slide)+slide)%slide
+	)
+	for start := last; start > ns-size; start -= slide {
+		end := time.Unix(0, start+size).UTC()
+		if !end.After(a.closed) {
+			// This window has already been emitted.
+			continue
+		}
+		w, ok := a.open[start]
+		if !ok {
+			w = &aggregateWindow{
+				start:  time.Unix(0, start).UTC(),
+				end:    end,
+				groups: map[string]*aggregateGroup{},
+			}
+			a.open[start] = w
+		}
+		a.addToWindow(w, entry)
+	}
+}
+
+func (a *aggregator) addToWindow(w *aggregateWindow, entry entries.LogEntry) {
+	var (
+		key    strings.Builder
+		values = make([]any, len(a.opts.groupBy))
+	)
+	for i, field := range a.opts.groupBy {
+		val, ok := entry.Get(field)
+		if !ok {
+			// Missing fields are grouped separately from empty strings.
+			key.WriteString("\x01\x00")
+			continue
+		}
+		values[i] = val
+		s, _ := entry.AsString(field)
+		key.WriteString(s)
+		key.WriteByte(0)
+	}
+	g, ok := w.groups[key.String()]
+	if !ok {
+		g = &aggregateGroup{
+			values: values,
+			states: make([]*aggregateState, len(a.aggs)),
+		}
+		for i := range g.states {
+			g.states[i] = new(aggregateState)
+		}
+		w.groups[key.String()] = g
+		w.order = append(w.order, g)
+	}
+	g.count++
+	for i, agg := range a.aggs {
+		state := g.states[i]
+		if len(agg.Field) == 0 {
+			continue
+		}
+		switch agg.Func {
+		case AggregateCount:
+			if entry.HasField(agg.Field) {
+				state.count++
+			}
+		case AggregateDistinct:
+			s, ok := entry.AsString(agg.Field)
+			if !ok {
+				continue
+			}
+			if state.distinct == nil {
+				state.distinct = map[string]struct{}{}
+			}
+			state.distinct[s] = struct{}{}
+		default:
+			f, ok := numberOf(entry, agg.Field)
+			if !ok {
+				continue
+			}
+			if state.count == 0 || f < state.min {
+				state.min = f
+			}
+			if state.count == 0 || f > state.max {
+				state.max = f
+			}
+			state.count++
+			state.sum += f
+			if agg.Func == AggregatePercentile {
+				state.values = append(state.values, f)
+			}
+		}
+	}
+}
+
+// nextEnd returns the earliest end of an open window.
+func (a *aggregator) nextEnd() (time.Time, bool) {
+	var (
+		next  time.Time
+		found bool
+	)
+	for _, w := range a.open {
+		if !found || w.end.Before(next) {
+			next = w.end
+			found = true
+		}
+	}
+	return next, found
+}
+
+// flush emits open windows in order that end at or before the watermark, or all open windows if the watermark is zero.
+func (a *aggregator) flush(watermark time.Time, emit func(entry entries.LogEntry) bool) bool {
+	var ready []*aggregateWindow
+	for start, w := range a.open {
+		if watermark.IsZero() || !w.end.After(watermark) {
+			ready = append(ready, w)
+			delete(a.open, start)
+		}
+	}
+	if !watermark.IsZero() && watermark.After(a.closed) {
+		a.closed = watermark
+	}
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].start.Before(ready[j].start)
+	})
+	for _, w := range ready {
+		for _, g := range w.order {
+			if !emit(a.result(w, g)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (a *aggregator) result(w *aggregateWindow, g *aggregateGroup) entries.LogEntry {
+	entry := entries.LogEntry{
+		entries.StandardTimestampField: w.start.Format(time.RFC3339Nano),
+		WindowStartField:               w.start.Format(time.RFC3339Nano),
+		WindowEndField:                 w.end.Format(time.RFC3339Nano),
+	}
+	for i, field := range a.opts.groupBy {
+		if g.values[i] != nil {
+			_ = entry.Set(field, g.values[i])
+		}
+	}
+	for i, agg := range a.aggs {
+		state := g.states[i]
+		name := agg.Name()
+		switch agg.Func {
+		case AggregateCount:
+			if len(agg.Field) == 0 {
+				entry[name] = g.count
+			} else {
+				entry[name] = state.count
+			}
+		case AggregateDistinct:
+			entry[name] = int64(len(state.distinct))
+		default:
+			if state.count == 0 {
+				continue
+			}
+			switch agg.Func {
+			case AggregateSum:
+				entry[name] = state.sum
+			case AggregateMin:
+				entry[name] = state.min
+			case AggregateMax:
+				entry[name] = state.max
+			case AggregateAvg:
+				entry[name] = state.sum / float64(state.count)
+			case AggregatePercentile:
+				entry[name] = percentile(state.values, agg.Percentile)
+			}
+		}
+	}
+	return entry
+}
+
+// numberOf reads a field as a number, which may be any numeric type or a numeric string.
+func numberOf(entry entries.LogEntry, field string) (float64, bool) {
+	if f, ok := entry.AsFloat(field); ok {
+		return f, true
+	}
+	if i, ok := entry.AsInt(field); ok {
+		return float64(i), true
+	}
+	if u, ok := entry.AsUint(field); ok {
+		return float64(u), true
+	}
+	return 0, false
+}
+
+// percentile finds the pth percentile of values by linear interpolation between the closest ranks.
+func percentile(values []float64, p float64) float64 {
+	sort.Float64s(values)
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return values[lower]
+	}
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
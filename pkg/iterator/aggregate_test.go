@@ -0,0 +1,147 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAggregator_Tumbling(t *testing.T) {
+	iter := FromSlice([]entries.LogEntry{
+		{"@timestamp": "2023-01-01T00:00:05Z", "service": "api", "@level": "error", "latency": 10},
+		{"@timestamp": "2023-01-01T00:00:30Z", "service": "api", "@level": "info", "latency": 20},
+		{"@timestamp": "2023-01-01T00:00:45Z", "service": "db", "@level": "error", "latency": "40"},
+		{"@timestamp": "2023-01-01T00:01:10Z", "service": "api", "@level": "error"},
+		{"service": "api", "latency": 1000},
+	})
+	agg, err := Aggregator(iter, []Aggregate{
+		{Func: AggregateCount},
+		{Func: AggregateSum, Field: "latency"},
+		{Func: AggregateMin, Field: "latency"},
+		{Func: AggregateMax, Field: "latency"},
+		{Func: AggregateAvg, Field: "latency", As: "avg"},
+		{Func: AggregateDistinct, Field: "@level"},
+	}, AggregateWindow(time.Minute), AggregateGroupBy("service"))
+	require.NoError(t, err)
+
+	result := _aggregateResult(t, agg)
+	assert.Equal(t, []entries.LogEntry{
+		{
+			"@timestamp": "2023-01-01T00:00:00Z", "@window_start": "2023-01-01T00:00:00Z", "@window_end": "2023-01-01T00:01:00Z",
+			"service": "api", "count": int64(2), "sum_latency": 30.0, "min_latency": 10.0, "max_latency": 20.0, "avg": 15.0, "distinct_@level": int64(2),
+		},
+		{
+			"@timestamp": "2023-01-01T00:00:00Z", "@window_start": "2023-01-01T00:00:00Z", "@window_end": "2023-01-01T00:01:00Z",
+			"service": "db", "count": int64(1), "sum_latency": 40.0, "min_latency": 40.0, "max_latency": 40.0, "avg": 40.0, "distinct_@level": int64(1),
+		},
+		{
+			"@timestamp": "2023-01-01T00:01:00Z", "@window_start": "2023-01-01T00:01:00Z", "@window_end": "2023-01-01T00:02:00Z",
+			"service": "api", "count": int64(1), "distinct_@level": int64(1),
+		},
+	}, result, "Entries without a time should be ignored, and numeric aggregates omitted without values")
+}
+
+func TestAggregator_Sliding(t *testing.T) {
+	iter := FromSlice([]entries.LogEntry{
+		{"ts": "2023-01-01T00:00:10Z"},
+		{"ts": "2023-01-01T00:00:40Z"},
+		{"ts": "2023-01-01T00:01:10Z"},
+	})
+	agg, err := Aggregator(iter, []Aggregate{{Func: AggregateCount}},
+		AggregateSliding(time.Minute, 30*time.Second),
+		AggregateTimeField("ts"),
+	)
+	require.NoError(t, err)
+
+	var counts []string
+	for _, entry := range _aggregateResult(t, agg) {
+		start, _ := entry.AsString(WindowStartField)
+		counts = append(counts, fmt.Sprintf("%s=%d", start[14:19], entry["count"]))
+	}
+	assert.Equal(t, []string{"59:30=1", "00:00=2", "00:30=2", "01:00=1"}, counts)
+}
+
+func TestAggregator_Lateness(t *testing.T) {
+	iter := FromSlice([]entries.LogEntry{
+		{"@timestamp": "2023-01-01T00:00:50Z"},
+		{"@timestamp": "2023-01-01T00:01:05Z"},
+		{"@timestamp": "2023-01-01T00:00:55Z"},
+		{"@timestamp": "2023-01-01T00:01:20Z"},
+		{"@timestamp": "2023-01-01T00:00:58Z"},
+	})
+	agg, err := Aggregator(iter, []Aggregate{{Func: AggregateCount}}, AggregateWindow(time.Minute), AggregateLateness(10*time.Second))
+	require.NoError(t, err)
+
+	result := _aggregateResult(t, agg)
+	require.Len(t, result, 2)
+	assert.Equal(t, int64(2), result[0]["count"], "The entry within the lateness should be counted, and the one after the window closed dropped")
+	assert.Equal(t, int64(2), result[1]["count"])
+}
+
+func TestAggregator_Percentile(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 1; i <= 100; i++ {
+		input = append(input, entries.LogEntry{"@timestamp": "2023-01-01T00:00:00Z", "latency": i})
+	}
+	agg, err := Aggregator(FromSlice(input), []Aggregate{
+		{Func: AggregatePercentile, Field: "latency", Percentile: 50},
+		{Func: AggregatePercentile, Field: "latency", Percentile: 95},
+		{Func: AggregatePercentile, Field: "latency", Percentile: 99.9},
+	}, AggregateWindow(time.Hour))
+	require.NoError(t, err)
+
+	result := _aggregateResult(t, agg)
+	require.Len(t, result, 1)
+	assert.InDelta(t, 50.5, result[0]["p50_latency"], 0.001)
+	assert.InDelta(t, 95.05, result[0]["p95_latency"], 0.001)
+	assert.InDelta(t, 99.901, result[0]["p99_9_latency"], 0.001)
+}
+
+func TestAggregator_Arrival(t *testing.T) {
+	ch := make(chan entries.LogEntry)
+	agg, err := Aggregator(FromChannel(ch), []Aggregate{{Func: AggregateCount}}, AggregateWindow(50*time.Millisecond), AggregateArrivalTime())
+	require.NoError(t, err)
+	defer func() {
+		_ = agg.Close()
+	}()
+	go func() {
+		ch <- entries.LogEntry{}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entry, _, err := agg.Next(ctx)
+	require.NoError(t, err, "Windows should be emitted when they end, even if no more entries are read")
+	assert.Equal(t, int64(1), entry["count"])
+}
+
+func TestAggregator_Invalid(t *testing.T) {
+	count := []Aggregate{{Func: AggregateCount}}
+	_, err := Aggregator(Empty(), count)
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+	_, err = Aggregator(Empty(), count, AggregateSliding(time.Minute, 2*time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+	_, err = Aggregator(Empty(), nil, AggregateWindow(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidAggregate)
+	_, err = Aggregator(Empty(), []Aggregate{{Func: AggregateSum}}, AggregateWindow(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidAggregate)
+	_, err = Aggregator(Empty(), []Aggregate{{Func: AggregatePercentile, Field: "x", Percentile: 101}}, AggregateWindow(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidAggregate)
+	_, err = Aggregator(Empty(), []Aggregate{{Func: AggregatePercentile, Field: "x", Percentile: math.NaN()}}, AggregateWindow(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidAggregate)
+}
+
+func _aggregateResult(t *testing.T, iter Iterator) []entries.LogEntry {
+	var result []entries.LogEntry
+	err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		result = append(result, entry)
+		return nil
+	})
+	require.NoError(t, err)
+	return result
+}
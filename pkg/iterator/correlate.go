@@ -0,0 +1,257 @@
+package iterator
+
+import (
+	"container/list"
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"sort"
+	"time"
+)
+
+const (
+	EventsField         = "@events"          // EventsField is the list of entries that were correlated into a composite entry, ordered by time
+	EventCountField     = "@event_count"     // EventCountField is the number of entries that were correlated into a composite entry
+	FirstTimestampField = "@first_timestamp" // FirstTimestampField is the earliest time of the entries in a composite entry, as an RFC 3339 string
+	LastTimestampField  = "@last_timestamp"  // LastTimestampField is the latest time of the entries in a composite entry, as an RFC 3339 string
+	DurationField       = "@duration_ms"     // DurationField is the number of milliseconds between the first and last times of the entries in a composite entry
+	ClosedByField       = "@closed_by"       // ClosedByField is the reason that a composite entry was closed: end, timeout, max_size, or input_end
+)
+
+const (
+	closedByEnd      = "end"
+	closedByTimeout  = "timeout"
+	closedByMaxSize  = "max_size"
+	closedByInputEnd = "input_end"
+)
+
+// DefaultCorrelateTimeout is how long a group waits for another entry before it's closed, unless CorrelateTimeout is given.
+const DefaultCorrelateTimeout = 30 * time.Second
+
+type correlateOpts struct {
+	end       func(entry entries.LogEntry) bool
+	timeout   time.Duration
+	maxSize   int
+	timeField string
+	timeOpts  []entries.TimestampOpt
+}
+
+// CorrelateOpt represents a functional option for Correlator.
+type CorrelateOpt func(opts *correlateOpts)
+
+// CorrelateEnd closes a group when an entry matches, like a "request completed" line. The matching entry is included in the group.
+func CorrelateEnd(match func(entry entries.LogEntry) bool) CorrelateOpt {
+	return func(opts *correlateOpts) {
+		opts.end = match
+	}
+}
+
+// CorrelateTimeout closes a group once no entries have been read for it for the timeout. Defaults to DefaultCorrelateTimeout.
+func CorrelateTimeout(timeout time.Duration) CorrelateOpt {
+	return func(opts *correlateOpts) {
+		opts.timeout = timeout
+	}
+}
+
+// CorrelateMaxSize closes a group once it has the given number of entries. The default of 0 means no limit.
+func CorrelateMaxSize(size int) CorrelateOpt {
+	return func(opts *correlateOpts) {
+		opts.maxSize = size
+	}
+}
+
+// CorrelateTimeField specifies the field that holds each entry's time. Defaults to entries.StandardTimestampField.
+// Values are interpreted with entries.ParseTimestamp, using any given options.
+func CorrelateTimeField(field string, opt ...entries.TimestampOpt) CorrelateOpt {
+	return func(opts *correlateOpts) {
+		opts.timeField = field
+		opts.timeOpts = opt
+	}
+}
+
+type correlation struct {
+	key      any
+	id       string
+	events   []entries.LogEntry
+	lastSeen time.Time
+	elem     *list.Element
+}
+
+type correlator struct {
+	opts   *correlateOpts
+	field  string
+	groups map[string]*correlation
+	// active holds open groups ordered by when they last read an entry, so the front is always the next to time out.
+	active *list.List
+}
+
+// Correlator groups entries that share a value in the key field - like a request ID - into a single composite entry, to reconstruct a transaction or session.
+// The composite has the key field, the entries in EventsField ordered by time, EventCountField, FirstTimestampField, LastTimestampField, DurationField,
+// the first time in entries.StandardTimestampField, the most severe entries.Level in entries.StandardLevelField, and the reason it was emitted in ClosedByField.
+// Groups are closed when an entry matches CorrelateEnd, when no entries have been read for the group within the CorrelateTimeout, when the CorrelateMaxSize is reached,
+// or when the input ends. Entries without the key field are passed through unchanged.
+func Correlator(iter Iterator, key string, opt ...CorrelateOpt) Iterator {
+	opts := &correlateOpts{
+		timeout:   DefaultCorrelateTimeout,
+		timeField: entries.StandardTimestampField,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	c := &correlator{
+		opts:   opts,
+		field:  key,
+		groups: map[string]*correlation{},
+		active: list.New(),
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		return c.run(ctx, iter, emit)
+	}, iter)
+}
+
+func (c *correlator) run(ctx context.Context, iter Iterator, emit func(entry entries.LogEntry) bool) error {
+	reads, stop := readAsync(ctx, iter)
+	defer stop()
+	timer := time.NewTimer(c.opts.timeout)
+	defer timer.Stop()
+	nextTimeout := func() time.Time {
+		front := c.active.Front()
+		if front == nil {
+			return time.Time{}
+		}
+		return front.Value.(*correlation).lastSeen.Add(c.opts.timeout)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-timer.C:
+			if !c.closeWhile(closedByTimeout, emit, func(g *correlation) bool {
+				return !g.lastSeen.Add(c.opts.timeout).After(now)
+			}) {
+				return nil
+			}
+			resetTimer(timer, nextTimeout())
+		case res, ok := <-reads:
+			if !ok {
+				return nil
+			}
+			if res.err != nil {
+				if !c.closeWhile(closedByInputEnd, emit, func(*correlation) bool { return true }) {
+					return nil
+				}
+				if !IsEnd(res.err) && ctx.Err() == nil {
+					return res.err
+				}
+				return nil
+			}
+			val, ok := res.entry.Get(c.field)
+			if !ok {
+				if !emit(res.entry) {
+					return nil
+				}
+				continue
+			}
+			key, _ := res.entry.AsString(c.field)
+			g, ok := c.groups[key]
+			if !ok {
+				g = &correlation{key: val, id: key}
+				g.elem = c.active.PushBack(g)
+				c.groups[key] = g
+			} else {
+				c.active.MoveToBack(g.elem)
+			}
+			g.events = append(g.events, res.entry)
+			g.lastSeen = time.Now()
+			switch {
+			case c.opts.end != nil && c.opts.end(res.entry):
+				if !c.close(g, closedByEnd, emit) {
+					return nil
+				}
+			case c.opts.maxSize > 0 && len(g.events) >= c.opts.maxSize:
+				if !c.close(g, closedByMaxSize, emit) {
+					return nil
+				}
+			}
+			resetTimer(timer, nextTimeout())
+		}
+	}
+}
+
+// closeWhile closes groups from the least recently active, until one doesn't match.
+func (c *correlator) closeWhile(reason string, emit func(entry entries.LogEntry) bool, match func(g *correlation) bool) bool {
+	for front := c.active.Front(); front != nil; front = c.active.Front() {
+		g := front.Value.(*correlation)
+		if !match(g) {
+			break
+		}
+		if !c.close(g, reason, emit) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *correlator) close(g *correlation, reason string, emit func(entry entries.LogEntry) bool) bool {
+	delete(c.groups, g.id)
+	c.active.Remove(g.elem)
+	return emit(c.composite(g, reason))
+}
+
+func (c *correlator) composite(g *correlation, reason string) entries.LogEntry {
+	type timedEvent struct {
+		entry entries.LogEntry
+		time  time.Time
+	}
+	var (
+		events = make([]timedEvent, len(g.events))
+		first  time.Time
+		last   time.Time
+		level  entries.Level
+		prev   time.Time
+	)
+	for i, entry := range g.events {
+		t := prev
+		if val, ok := entry.Get(c.opts.timeField); ok {
+			if parsed, ok := entries.ParseTimestamp(val, c.opts.timeOpts...); ok {
+				t = parsed
+				if first.IsZero() || t.Before(first) {
+					first = t
+				}
+				if last.IsZero() || t.After(last) {
+					last = t
+				}
+			}
+		}
+		// Entries without a time are kept after the entry read before them.
+		prev = t
+		events[i] = timedEvent{entry: entry, time: t}
+		if l := entry.Level(); l > level {
+			level = l
+		}
+	}
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].time.Before(events[b].time)
+	})
+
+	list := make([]any, len(events))
+	for i, e := range events {
+		list[i] = map[string]any(e.entry)
+	}
+	composite := entries.LogEntry{
+		EventsField:     list,
+		EventCountField: len(list),
+		ClosedByField:   reason,
+	}
+	_ = composite.Set(c.field, g.key)
+	if !first.IsZero() {
+		composite[entries.StandardTimestampField] = first.UTC().Format(time.RFC3339Nano)
+		composite[FirstTimestampField] = first.UTC().Format(time.RFC3339Nano)
+		composite[LastTimestampField] = last.UTC().Format(time.RFC3339Nano)
+		composite[DurationField] = last.Sub(first).Milliseconds()
+	}
+	if level > entries.LevelUnknown {
+		composite[entries.StandardLevelField] = level.String()
+	}
+	return composite
+}
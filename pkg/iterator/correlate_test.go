@@ -0,0 +1,101 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCorrelator(t *testing.T) {
+	iter := Correlator(FromSlice([]entries.LogEntry{
+		{"request_id": "a", "@timestamp": "2023-01-01T00:00:00Z", "@level": "info", "@message": "started"},
+		{"request_id": "b", "@timestamp": "2023-01-01T00:00:01Z", "@level": "info", "@message": "started"},
+		{"@message": "unrelated"},
+		{"request_id": "a", "@timestamp": "2023-01-01T00:00:00.500Z", "@level": "error", "@message": "query failed"},
+		{"request_id": "a", "@timestamp": "2023-01-01T00:00:02Z", "@level": "warn", "@message": "completed"},
+		{"request_id": "b", "@timestamp": "2023-01-01T00:00:03Z", "@level": "debug", "@message": "cache hit"},
+	}), "request_id", CorrelateEnd(func(entry entries.LogEntry) bool {
+		msg, _ := entry.AsString("@message")
+		return msg == "completed"
+	}))
+
+	result := _aggregateResult(t, iter)
+	require.Len(t, result, 3)
+	assert.Equal(t, entries.LogEntry{"@message": "unrelated"}, result[0], "Entries without the key should be passed through")
+
+	a := result[1]
+	assert.Equal(t, "a", a["request_id"])
+	assert.Equal(t, 3, a[EventCountField])
+	assert.Equal(t, "2023-01-01T00:00:00Z", a[entries.StandardTimestampField])
+	assert.Equal(t, "2023-01-01T00:00:00Z", a[FirstTimestampField])
+	assert.Equal(t, "2023-01-01T00:00:02Z", a[LastTimestampField])
+	assert.Equal(t, int64(2000), a[DurationField])
+	assert.Equal(t, "error", a[entries.StandardLevelField])
+	assert.Equal(t, "end", a[ClosedByField])
+	var msgs []string
+	for _, e := range a[EventsField].([]any) {
+		msgs = append(msgs, e.(map[string]any)["@message"].(string))
+	}
+	assert.Equal(t, []string{"started", "query failed", "completed"}, msgs)
+
+	b := result[2]
+	assert.Equal(t, "b", b["request_id"])
+	assert.Equal(t, 2, b[EventCountField])
+	assert.Equal(t, "info", b[entries.StandardLevelField])
+	assert.Equal(t, "input_end", b[ClosedByField])
+}
+
+func TestCorrelator_MaxSize(t *testing.T) {
+	iter := Correlator(FromSlice([]entries.LogEntry{
+		{"trace": map[string]any{"id": 1}},
+		{"trace": map[string]any{"id": 1}},
+		{"trace": map[string]any{"id": 1}},
+	}), "trace.id", CorrelateMaxSize(2))
+
+	result := _aggregateResult(t, iter)
+	require.Len(t, result, 2)
+	assert.Equal(t, map[string]any{"id": 1}, result[0]["trace"], "Nested keys should be set in the composite")
+	assert.Equal(t, 2, result[0][EventCountField])
+	assert.Equal(t, "max_size", result[0][ClosedByField])
+	assert.Equal(t, 1, result[1][EventCountField])
+	assert.Nil(t, result[0][DurationField], "Duration should be omitted without times")
+}
+
+func TestCorrelator_InputEndOrder(t *testing.T) {
+	iter := Correlator(FromSlice([]entries.LogEntry{
+		{"session": "a"},
+		{"session": "b"},
+		{"session": "c"},
+		{"session": "a"},
+	}), "session")
+
+	result := _aggregateResult(t, iter)
+	require.Len(t, result, 3)
+	var keys []any
+	for _, r := range result {
+		keys = append(keys, r["session"])
+	}
+	assert.Equal(t, []any{"b", "c", "a"}, keys, "Groups should be closed from the least recently active")
+}
+
+func TestCorrelator_Timeout(t *testing.T) {
+	ch := make(chan entries.LogEntry)
+	iter := Correlator(FromChannel(ch), "session", CorrelateTimeout(50*time.Millisecond))
+	defer func() {
+		_ = iter.Close()
+	}()
+	go func() {
+		ch <- entries.LogEntry{"session": "s1"}
+		ch <- entries.LogEntry{"session": "s1"}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entry, _, err := iter.Next(ctx)
+	require.NoError(t, err, "The group should be emitted once it's inactive, even if no more entries are read")
+	assert.Equal(t, 2, entry[EventCountField])
+	assert.Equal(t, "timeout", entry[ClosedByField])
+}
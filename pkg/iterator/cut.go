@@ -1,13 +1,14 @@
 package iterator
 
 import (
+	"context"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 )
 
 // Cutter injects entries.Cut for each entry in the iterator.
 func Cutter(iter Iterator, opt ...entries.CutOpt) Iterator {
-	return Func(func() (entries.LogEntry, int, error) {
-		entry, i, err := iter.Next()
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
 		if err != nil {
 			return Err(err)
 		}
@@ -0,0 +1,233 @@
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"time"
+)
+
+const (
+	RepeatCountField = "@repeat_count" // RepeatCountField is the number of duplicates that were suppressed after the first occurrence of an entry
+	FirstSeenField   = "@first_seen"   // FirstSeenField is the time that the first occurrence of a repeated entry was read, as an RFC 3339 string
+	LastSeenField    = "@last_seen"    // LastSeenField is the time that the last suppressed duplicate of an entry was read, as an RFC 3339 string
+)
+
+// DefaultDedupWindow is how long duplicates are suppressed after the first occurrence of an entry, unless DedupWindow is given.
+const DefaultDedupWindow = time.Minute
+
+type dedupOpts struct {
+	fields []string
+	ignore []string
+	window time.Duration
+	limit  int
+}
+
+// DedupOpt represents a functional option for Deduplicator.
+type DedupOpt func(opts *dedupOpts)
+
+// DedupFields compares entries by the values of the given fields, instead of the whole entry.
+func DedupFields(fields ...string) DedupOpt {
+	return func(opts *dedupOpts) {
+		opts.fields = fields
+	}
+}
+
+// DedupIgnore specifies more fields that are ignored when comparing whole entries.
+// entries.ReadTimestampField and entries.ReadLineNumberField are always ignored, because they differ for every line read from a file.
+func DedupIgnore(fields ...string) DedupOpt {
+	return func(opts *dedupOpts) {
+		opts.ignore = append(opts.ignore, fields...)
+	}
+}
+
+// DedupWindow specifies how long duplicates are suppressed after the first occurrence of an entry. Defaults to DefaultDedupWindow.
+func DedupWindow(window time.Duration) DedupOpt {
+	return func(opts *dedupOpts) {
+		opts.window = window
+	}
+}
+
+// DedupLimit specifies the most duplicates that will be suppressed before a summary is emitted, even if the window hasn't ended.
+// This keeps a constant flood of duplicates from being hidden for the whole window. The default of 0 means no limit.
+func DedupLimit(limit int) DedupOpt {
+	return func(opts *dedupOpts) {
+		opts.limit = limit
+	}
+}
+
+type dedupRun struct {
+	key       string
+	firstSeen time.Time
+	lastSeen  time.Time
+	last      entries.LogEntry
+	repeats   int
+	done      bool
+}
+
+type deduplicator struct {
+	opts   *dedupOpts
+	ignore map[string]bool
+	// runs are keyed by the canonical encoding of the compared values, so distinct entries are never mistaken for duplicates.
+	runs map[string]*dedupRun
+	// order holds runs in the order they started, which is also the order they expire.
+	order []*dedupRun
+}
+
+// Deduplicator suppresses entries that are duplicates of an entry read within a window of time, like a crash loop logging the same lines over and over.
+// The first occurrence of an entry is passed through as it's read. When the window ends - or the DedupLimit is reached - and duplicates were suppressed,
+// the last duplicate is emitted as a summary with RepeatCountField, FirstSeenField, and LastSeenField set. The next duplicate after that starts a new window.
+// Entries are compared by a canonical JSON encoding of the whole entry, except for read metadata and the fields given with DedupIgnore, or by the fields given with DedupFields.
+// Pending summaries are emitted when the input ends.
+func Deduplicator(iter Iterator, opt ...DedupOpt) Iterator {
+	opts := &dedupOpts{
+		ignore: []string{entries.ReadTimestampField, entries.ReadLineNumberField},
+		window: DefaultDedupWindow,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	d := &deduplicator{
+		opts:   opts,
+		ignore: map[string]bool{},
+		runs:   map[string]*dedupRun{},
+	}
+	for _, f := range opts.ignore {
+		d.ignore[f] = true
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		return d.run(ctx, iter, emit)
+	}, Mutable(iter))
+}
+
+func (d *deduplicator) run(ctx context.Context, iter Iterator, emit func(entry entries.LogEntry) bool) error {
+	reads, stop := readAsync(ctx, iter)
+	defer stop()
+	timer := time.NewTimer(d.opts.window)
+	defer timer.Stop()
+	nextExpiry := func() time.Time {
+		if len(d.order) == 0 {
+			return time.Time{}
+		}
+		return d.order[0].firstSeen.Add(d.opts.window)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-timer.C:
+			if !d.expire(now, emit) {
+				return nil
+			}
+			resetTimer(timer, nextExpiry())
+		case res, ok := <-reads:
+			if !ok {
+				return nil
+			}
+			if res.err != nil {
+				for _, r := range d.order {
+					if !d.finish(r, emit) {
+						return nil
+					}
+				}
+				if !IsEnd(res.err) && ctx.Err() == nil {
+					return res.err
+				}
+				return nil
+			}
+			now := time.Now()
+			if !d.expire(now, emit) {
+				return nil
+			}
+			key := d.key(res.entry)
+			r, ok := d.runs[key]
+			if !ok {
+				r = &dedupRun{key: key, firstSeen: now}
+				d.runs[key] = r
+				d.order = append(d.order, r)
+				if !emit(res.entry) {
+					return nil
+				}
+				resetTimer(timer, nextExpiry())
+				continue
+			}
+			r.repeats++
+			r.last = res.entry
+			r.lastSeen = now
+			if d.opts.limit > 0 && r.repeats >= d.opts.limit && !d.finish(r, emit) {
+				return nil
+			}
+		}
+	}
+}
+
+// expire finishes runs whose window has ended by now.
+func (d *deduplicator) expire(now time.Time, emit func(entry entries.LogEntry) bool) bool {
+	for len(d.order) > 0 {
+		r := d.order[0]
+		if !r.done && r.firstSeen.Add(d.opts.window).After(now) {
+			return true
+		}
+		d.order[0] = nil
+		d.order = d.order[1:]
+		if !d.finish(r, emit) {
+			return false
+		}
+	}
+	return true
+}
+
+// finish ends a run, emitting a summary if any duplicates were suppressed.
+// A finished run stays in the order until it expires, so it's skipped rather than searched for.
+func (d *deduplicator) finish(r *dedupRun, emit func(entry entries.LogEntry) bool) bool {
+	if r.done {
+		return true
+	}
+	r.done = true
+	delete(d.runs, r.key)
+	if r.repeats == 0 {
+		return true
+	}
+	summary := r.last
+	summary[RepeatCountField] = r.repeats
+	summary[FirstSeenField] = r.firstSeen.UTC().Format(time.RFC3339Nano)
+	summary[LastSeenField] = r.lastSeen.UTC().Format(time.RFC3339Nano)
+	return emit(summary)
+}
+
+// key returns the canonical encoding of the values that entries are compared by.
+func (d *deduplicator) key(entry entries.LogEntry) string {
+	var buf bytes.Buffer
+	if len(d.opts.fields) > 0 {
+		for _, f := range d.opts.fields {
+			val, ok := entry.Get(f)
+			if !ok {
+				buf.WriteByte(1)
+				continue
+			}
+			writeKeyValue(&buf, val)
+		}
+		return buf.String()
+	}
+	compared := make(map[string]any, len(entry))
+	for k, v := range entry {
+		if !d.ignore[k] {
+			compared[k] = v
+		}
+	}
+	writeKeyValue(&buf, compared)
+	return buf.String()
+}
+
+// writeKeyValue writes a value to the key as JSON, which orders map keys consistently.
+func writeKeyValue(buf *bytes.Buffer, val any) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%#v", val))
+	}
+	buf.Write(data)
+	buf.WriteByte(0)
+}
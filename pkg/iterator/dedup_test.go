@@ -0,0 +1,97 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestDeduplicator(t *testing.T) {
+	tests := map[string]struct {
+		opts     []DedupOpt
+		expected []string
+		repeats  []any
+	}{
+		"Whole entry": {
+			expected: []string{"panic", "started", "panic", "panic x2"},
+			repeats:  []any{nil, nil, nil, 2},
+		},
+		"Fields": {
+			opts:     []DedupOpt{DedupFields("@message")},
+			expected: []string{"panic", "started", "panic x3"},
+			repeats:  []any{nil, nil, 3},
+		},
+		"Ignore": {
+			opts:     []DedupOpt{DedupIgnore("pid")},
+			expected: []string{"panic", "started", "panic x3"},
+			repeats:  []any{nil, nil, 3},
+		},
+		"Limit": {
+			opts:     []DedupOpt{DedupLimit(1)},
+			expected: []string{"panic", "started", "panic x1", "panic", "panic"},
+			repeats:  []any{nil, nil, 1, nil, nil},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			iter := Deduplicator(FromSlice([]entries.LogEntry{
+				{"@message": "panic", entries.ReadLineNumberField: 1},
+				{"@message": "started", entries.ReadLineNumberField: 2},
+				{"@message": "panic", entries.ReadLineNumberField: 3},
+				{"@message": "panic", entries.ReadLineNumberField: 4},
+				{"@message": "panic", "pid": 2, entries.ReadLineNumberField: 5},
+			}), tc.opts...)
+			var (
+				result  []string
+				repeats []any
+			)
+			err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+				msg, _ := entry.AsString("@message")
+				if count, ok := entry[RepeatCountField]; ok {
+					msg = fmt.Sprintf("%s x%d", msg, count)
+					assert.True(t, entry.HasField(FirstSeenField))
+					assert.True(t, entry.HasField(LastSeenField))
+				}
+				result = append(result, msg)
+				repeats = append(repeats, entry[RepeatCountField])
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+			assert.Equal(t, tc.repeats, repeats)
+		})
+	}
+}
+
+func TestDeduplicator_Window(t *testing.T) {
+	ctx := context.Background()
+	ch := make(chan entries.LogEntry)
+	iter := Deduplicator(FromChannel(ch), DedupWindow(50*time.Millisecond))
+	defer func() {
+		_ = iter.Close()
+	}()
+	go func() {
+		for i := 0; i < 3; i++ {
+			ch <- entries.LogEntry{"@message": "panic"}
+		}
+	}()
+
+	entry, _, err := iter.Next(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, entry[RepeatCountField], "The first occurrence should be passed through")
+
+	cctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	entry, _, err = iter.Next(cctx)
+	require.NoError(t, err, "The summary should be emitted when the window ends, even if no more entries are read")
+	assert.Equal(t, 2, entry[RepeatCountField])
+	first, _ := entry.AsTime(FirstSeenField, time.RFC3339Nano)
+	last, _ := entry.AsTime(LastSeenField, time.RFC3339Nano)
+	assert.False(t, last.Before(first))
+}
@@ -0,0 +1,51 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// Flattener runs entries.Flatten on each entry that passes through the Iterator.
+func Flattener(iter Iterator, opt ...entries.FlattenOpt) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.Flatten(entry, opt...)
+		return entry, i, nil
+	})
+}
+
+// Unflattener runs entries.Unflatten on each entry that passes through the Iterator.
+func Unflattener(iter Iterator, opt ...entries.FlattenOpt) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.Unflatten(entry, opt...)
+		return entry, i, nil
+	})
+}
+
+// Exploder runs entries.Explode on each entry that passes through the Iterator, returning each exploded entry in turn.
+// Exploded entries retain the offset of the entry they came from.
+func Exploder(iter Iterator, field string, opt ...entries.ExplodeOpt) Iterator {
+	var (
+		pending []entries.LogEntry
+		idx     int
+	)
+	return Wrap(iter, func(ctx context.Context) (entries.LogEntry, int, error) {
+		if len(pending) == 0 {
+			entry, i, err := iter.Next(ctx)
+			if err != nil {
+				return Err(err)
+			}
+			pending, idx = entries.Explode(entry, field, opt...), i
+		}
+		entry := pending[0]
+		pending = pending[1:]
+		return entry, idx, nil
+	})
+}
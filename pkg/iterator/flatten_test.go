@@ -0,0 +1,30 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExploder(t *testing.T) {
+	ctx := context.Background()
+	iter := Exploder(FromSlice([]entries.LogEntry{
+		{"items": []any{1, 2}},
+		{"items": "none"},
+		{"items": []any{3}},
+	}), "items")
+
+	var (
+		items   []any
+		offsets []int
+	)
+	err := iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		items = append(items, entry["items"])
+		offsets = append(offsets, i)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{1, 2, "none", 3}, items)
+	assert.Equal(t, []int{0, 0, 1, 2}, offsets)
+}
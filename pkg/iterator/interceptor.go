@@ -3,15 +3,14 @@ package iterator
 import (
 	"context"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
-	"sync"
 )
 
 // Filter wraps an Iterator with a function that - when it returns true - will allow the return values of Next through.
 // If the wrapped Iterator returns a non-nil error, then all values will be passed through regardless
 func Filter(iter Iterator, filter func(entry entries.LogEntry, i int, err error) bool) Iterator {
-	return Func(func() (entries.LogEntry, int, error) {
+	return Wrap(iter, func(ctx context.Context) (entries.LogEntry, int, error) {
 		for {
-			entry, idx, err := iter.Next()
+			entry, idx, err := iter.Next(ctx)
 			if err != nil {
 				return entry, idx, err
 			}
@@ -23,35 +22,40 @@ func Filter(iter Iterator, filter func(entry entries.LogEntry, i int, err error)
 }
 
 // Cancellable wraps an iterator and makes it cancellable by context.
-// When the context is cancelled and Next is called, all LogEntries will be forwarded to Drain.
+// When the context is cancelled, the wrapped Iterator will be closed, and Next will return ErrAtEnd.
 func Cancellable(ctx context.Context, iter Iterator) Iterator {
-	var (
-		cancelled bool
-		drain     sync.Once
-	)
+	closed := make(chan struct{})
 	go func() {
-		<-ctx.Done()
-		cancelled = true
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+		case <-closed:
+		}
 	}()
-	return Func(func() (entries.LogEntry, int, error) {
-		if cancelled {
-			drain.Do(func() {
-				Drain(iter)
-			})
+	return withUpstream(New(func(nctx context.Context) (entries.LogEntry, int, error) {
+		if ctx.Err() != nil {
 			return End()
 		}
-		return iter.Next()
-	})
+		entry, i, err := iter.Next(nctx)
+		if err != nil && ctx.Err() != nil {
+			return End()
+		}
+		return entry, i, err
+	}, func() error {
+		close(closed)
+		return iter.Close()
+	}), iter)
 }
 
 // Concat will return entries from next after base has been exhausted.
+// Closing the returned Iterator will close both base and next.
 func Concat(base, next Iterator) Iterator {
 	var idx int
-	return Func(func() (entries.LogEntry, int, error) {
-		e, i, err := base.Next()
+	return withUpstream(New(func(ctx context.Context) (entries.LogEntry, int, error) {
+		e, i, err := base.Next(ctx)
 		if err != nil {
-			if IsEnd(err) {
-				e, i, err := next.Next()
+			if IsEnd(err) && ctx.Err() == nil {
+				e, i, err := next.Next(ctx)
 				if err != nil {
 					return e, i, err
 				}
@@ -61,5 +65,5 @@ func Concat(base, next Iterator) Iterator {
 		}
 		idx++
 		return e, i, err
-	})
+	}, base.Close, next.Close), base, next)
 }
@@ -9,6 +9,7 @@ import (
 )
 
 func TestFilter(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice([]entries.LogEntry{
 		{
 			"A": "A",
@@ -24,18 +25,19 @@ func TestFilter(t *testing.T) {
 		return entry.HasField("C")
 	})
 
-	el, _, err := iter.Next()
+	el, _, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	s, ok := el.AsString("C")
 	assert.True(t, ok, "Field 'C' should exist in this entry")
 	assert.Equal(t, "C", s)
 
-	el, _, err = iter.Next()
+	el, _, err = iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 }
 
 func TestCancellable(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice([]entries.LogEntry{
 		{
 			"A": "A",
@@ -47,10 +49,10 @@ func TestCancellable(t *testing.T) {
 			"C": "C",
 		},
 	})
-	ctx, cancel := context.WithCancel(context.Background())
-	iter = Cancellable(ctx, iter)
+	cctx, cancel := context.WithCancel(ctx)
+	iter = Cancellable(cctx, iter)
 
-	el, _, err := iter.Next()
+	el, _, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	s, ok := el.AsString("A")
 	assert.True(t, ok, "Field 'A' should exist in this entry")
@@ -59,12 +61,13 @@ func TestCancellable(t *testing.T) {
 	cancel()
 	time.Sleep(200 * time.Millisecond)
 
-	el, _, err = iter.Next()
+	el, _, err = iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 }
 
 func TestConcat(t *testing.T) {
+	ctx := context.Background()
 	iter1 := FromSlice([]entries.LogEntry{
 		{
 			"A": "A",
@@ -80,21 +83,48 @@ func TestConcat(t *testing.T) {
 		return true
 	})
 
-	el, i, err := iter.Next()
+	el, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, i)
 	s, ok := el.AsString("A")
 	assert.True(t, ok, "Field 'A' should exist in this entry")
 	assert.Equal(t, "A", s)
 
-	el, i, err = iter.Next()
+	el, i, err = iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, i)
 	s, ok = el.AsString("B")
 	assert.True(t, ok, "Field 'B' should exist in this entry")
 	assert.Equal(t, "B", s)
 
-	el, _, err = iter.Next()
+	el, _, err = iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 }
+
+func TestFilter_Close(t *testing.T) {
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	iter := Filter(base, func(entry entries.LogEntry, i int, err error) bool {
+		return true
+	})
+	assert.NoError(t, iter.Close())
+	assert.True(t, baseClosed(), "Upstream should be closed")
+}
+
+func TestCancellable_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	base, baseClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	_ = Cancellable(ctx, base)
+
+	cancel()
+	assert.Eventually(t, baseClosed, time.Second, 10*time.Millisecond, "Upstream should be closed when the context is cancelled")
+}
+
+func TestConcat_Close(t *testing.T) {
+	a, aClosed := _closeTracker(FromSlice(_testEntries()))
+	b, bClosed := _closeTracker(FromSlice(_testEntries()))
+	iter := Concat(a, b)
+	assert.NoError(t, iter.Close())
+	assert.True(t, aClosed(), "Base should be closed")
+	assert.True(t, bClosed(), "Next should be closed")
+}
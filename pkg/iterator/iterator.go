@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
-	"golang.org/x/sync/semaphore"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -13,27 +17,130 @@ var (
 
 type Iterator interface {
 	// Next returns the next LogEntry and its offset in the stream.
-	// Should return ErrAtEnd if the end of the stream is reached.
-	Next() (entries.LogEntry, int, error)
+	// Should return ErrAtEnd if the end of the stream is reached, or if the Iterator has been closed.
+	// If the context is cancelled while waiting for the next LogEntry, then the context's error should be returned.
+	Next(ctx context.Context) (entries.LogEntry, int, error)
 	// Iterate will progress through all LogEntry items in the stream, calling iter for each one along with the offset.
-	// If iter returns ErrAtEnd, then iteration will cease, returning a nil error.
+	// If iter returns ErrAtEnd, or the context is cancelled, then iteration will cease, returning a nil error.
 	// If any other error is returned, then iteration will cease, and the error will be returned.
-	Iterate(iter func(entry entries.LogEntry, i int) error) error
+	// The Iterator is closed when Iterate returns, since it's been consumed.
+	Iterate(ctx context.Context, iter func(entry entries.LogEntry, i int) error) error
+	// Closer releases resources held by the Iterator - like goroutines, files, and database rows - and closes any upstream iterators it reads from.
+	// Closing an Iterator more than once has no effect.
+	io.Closer
+}
+
+// NextFunc is a context aware implementation of Iterator.Next.
+type NextFunc func(ctx context.Context) (entries.LogEntry, int, error)
+
+var _ Iterator = (*funcIterator)(nil)
+
+type funcIterator struct {
+	next     NextFunc
+	onClose  []func() error
+	closed   int32
+	once     sync.Once
+	closeErr error
+	// upstream holds the iterators that entries are read from, so Mutable can find the Dupe branches above an Iterator.
+	upstream []Iterator
+	// onMutable is called by Mutable, for Dupe branches that share entries with other branches.
+	onMutable func()
+}
+
+// New creates an Iterator from a NextFunc.
+// The onClose functions will be called in order when the Iterator is closed, and the first error will be returned from Close.
+// After the Iterator is closed, Next will return ErrAtEnd without calling next.
+func New(next NextFunc, onClose ...func() error) Iterator {
+	return &funcIterator{
+		next:    next,
+		onClose: onClose,
+	}
+}
+
+// Wrap creates an Iterator from a NextFunc that reads from upstream, like a filter or transformation.
+// Closing the returned Iterator will close upstream.
+func Wrap(upstream Iterator, next NextFunc) Iterator {
+	return withUpstream(New(next, upstream.Close), upstream)
+}
+
+// withUpstream records the iterators that an Iterator created with New reads from, as described in Mutable.
+func withUpstream(iter Iterator, upstream ...Iterator) Iterator {
+	iter.(*funcIterator).upstream = upstream
+	return iter
+}
+
+// Mutable marks that entries read from iter will be changed in place, and returns iter.
+// Dupe branches share each LogEntry with each other until an Iterator reading from them is marked, then they give copies to the marked branches.
+// Operators in this package that change entries in place - like Tag or Cutter - mark their input already,
+// so Mutable is only needed by code outside this package that changes entries read from an Iterator.
+func Mutable(iter Iterator) Iterator {
+	if f, ok := iter.(*funcIterator); ok {
+		if f.onMutable != nil {
+			f.onMutable()
+		}
+		for _, u := range f.upstream {
+			Mutable(u)
+		}
+	}
+	return iter
+}
+
+func (f *funcIterator) Next(ctx context.Context) (entries.LogEntry, int, error) {
+	if atomic.LoadInt32(&f.closed) == 1 {
+		return End()
+	}
+	if err := ctx.Err(); err != nil {
+		return Err(err)
+	}
+	return f.next(ctx)
+}
+
+func (f *funcIterator) Iterate(ctx context.Context, iter func(entry entries.LogEntry, i int) error) error {
+	return iterate(ctx, f, iter)
+}
+
+func (f *funcIterator) Close() error {
+	f.once.Do(func() {
+		atomic.StoreInt32(&f.closed, 1)
+		for _, fn := range f.onClose {
+			if err := fn(); err != nil && f.closeErr == nil {
+				f.closeErr = err
+			}
+		}
+	})
+	return f.closeErr
 }
 
 var _ Iterator = (Func)(nil)
 
-// Func provides a quicker way to implement an Iterator.
-// A Func implements Iterator.Next, and implicitly provides a base Iterate implementation.
+// Func adapts a function to an Iterator, for iterators that don't need a context and don't hold any resources.
+// The context given to Next is checked before calling the function, and Close has no effect.
+// Use New or Wrap for iterators that need to release resources or close upstream iterators.
 type Func func() (entries.LogEntry, int, error)
 
-func (f Func) Next() (entries.LogEntry, int, error) {
+func (f Func) Next(ctx context.Context) (entries.LogEntry, int, error) {
+	if err := ctx.Err(); err != nil {
+		return Err(err)
+	}
 	return f()
 }
 
-func (f Func) Iterate(iter func(entry entries.LogEntry, i int) error) error {
+func (f Func) Iterate(ctx context.Context, iter func(entry entries.LogEntry, i int) error) error {
+	return iterate(ctx, f, iter)
+}
+
+func (f Func) Close() error {
+	return nil
+}
+
+func iterate(ctx context.Context, it Iterator, iter func(entry entries.LogEntry, i int) error) (rerr error) {
+	defer func() {
+		if err := it.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+	}()
 	for {
-		entry, i, err := f.Next()
+		entry, i, err := it.Next(ctx)
 		if err != nil {
 			if IsEnd(err) {
 				return nil
@@ -44,7 +151,6 @@ func (f Func) Iterate(iter func(entry entries.LogEntry, i int) error) error {
 			if IsEnd(err) {
 				return nil
 			}
-			Drain(f)
 			return err
 		}
 	}
@@ -60,9 +166,9 @@ func End() (entries.LogEntry, int, error) {
 	return Err(ErrAtEnd)
 }
 
-// IsEnd returns whether the error indicates the end of a stream.
+// IsEnd returns whether the error indicates the end of a stream, which includes the stream being cancelled.
 func IsEnd(err error) bool {
-	return errors.Is(err, ErrAtEnd)
+	return errors.Is(err, ErrAtEnd) || errors.Is(err, context.Canceled)
 }
 
 func FromSlice(slice []entries.LogEntry) Iterator {
@@ -79,22 +185,131 @@ func FromSlice(slice []entries.LogEntry) Iterator {
 }
 
 // FromChannel will create a new Iterator from a channel of entries.LogEntry.
+// Closing the Iterator doesn't affect the channel, so the producer should be stopped separately.
 func FromChannel(_entries <-chan entries.LogEntry) Iterator {
 	var next int
-	return Func(func() (entries.LogEntry, int, error) {
-		entry, ok := <-_entries
-		if !ok {
-			return End()
+	return New(func(ctx context.Context) (entries.LogEntry, int, error) {
+		select {
+		case entry, ok := <-_entries:
+			if !ok {
+				return End()
+			}
+			cur := next
+			next++
+			return entry, cur, nil
+		case <-ctx.Done():
+			return Err(ctx.Err())
 		}
-		cur := next
-		next++
-		return entry, cur, nil
 	})
 }
 
+// Generate creates an Iterator from a function that emits entries from a new goroutine, like a source reading lines from a file.
+// The context given to fn is derived from ctx, and is cancelled when the Iterator is closed.
+// Emit returns false when fn should stop, because the context is done.
+// Close waits for fn to return before closing the upstream iterators, so resources held by fn are released by the time Close returns.
+// If fn returns an error other than a context error, then it's returned from Next after all emitted entries have been read.
+func Generate(ctx context.Context, fn func(ctx context.Context, emit func(entry entries.LogEntry) bool) error, upstream ...Iterator) Iterator {
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		ch     = make(chan entries.LogEntry)
+		done   = make(chan struct{})
+		genErr error
+		next   int
+	)
+	go func() {
+		defer close(done)
+		defer close(ch)
+		genErr = fn(ctx, func(entry entries.LogEntry) bool {
+			select {
+			case ch <- entry:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	onClose := []func() error{
+		func() error {
+			cancel()
+			<-done
+			return nil
+		},
+	}
+	for _, u := range upstream {
+		onClose = append(onClose, u.Close)
+	}
+	return withUpstream(New(func(nctx context.Context) (entries.LogEntry, int, error) {
+		select {
+		case entry, ok := <-ch:
+			if !ok {
+				if genErr != nil && !IsEnd(genErr) && !errors.Is(genErr, context.DeadlineExceeded) {
+					return Err(genErr)
+				}
+				return End()
+			}
+			cur := next
+			next++
+			return entry, cur, nil
+		case <-nctx.Done():
+			return Err(nctx.Err())
+		}
+	}, onClose...), upstream...)
+}
+
+// readResult is an entry, or the error that ended reading, from readAsync.
+type readResult struct {
+	entry entries.LogEntry
+	idx   int
+	err   error
+}
+
+// readAsync reads from iter in a new goroutine, so a function given to Generate may wait for entries and timers at the same time.
+// The channel is closed after the first error is sent, or once ctx is done.
+// Stop must be called before the function given to Generate returns, since it waits for the reader to leave iter.Next before the upstream Iterator is closed.
+func readAsync(ctx context.Context, iter Iterator) (reads <-chan readResult, stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	var (
+		ch   = make(chan readResult)
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		defer close(ch)
+		for {
+			entry, idx, err := iter.Next(ctx)
+			select {
+			case ch <- readResult{entry: entry, idx: idx, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return ch, func() {
+		cancel()
+		<-done
+	}
+}
+
+// resetTimer stops the timer - draining it if it already fired - and restarts it to fire at next. A zero next leaves it stopped.
+func resetTimer(timer *time.Timer, next time.Time) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	if !next.IsZero() {
+		timer.Reset(time.Until(next))
+	}
+}
+
 // AsChannel will create a channel that is populated from the Iterator by a new goroutine.
 // If bufferSize is populated, then the returned channel will be a buffered channel.
-func AsChannel(iter Iterator, bufferSize ...int) <-chan entries.LogEntry {
+// The channel is closed when the Iterator is exhausted or the context is cancelled, and the Iterator is closed.
+func AsChannel(ctx context.Context, iter Iterator, bufferSize ...int) <-chan entries.LogEntry {
 	var ch chan entries.LogEntry
 	if len(bufferSize) == 0 {
 		ch = make(chan entries.LogEntry)
@@ -103,9 +318,13 @@ func AsChannel(iter Iterator, bufferSize ...int) <-chan entries.LogEntry {
 	}
 	go func() {
 		defer close(ch)
-		_ = iter.Iterate(func(entry entries.LogEntry, i int) error {
-			ch <- entry
-			return nil
+		_ = iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+			select {
+			case ch <- entry:
+				return nil
+			case <-ctx.Done():
+				return ErrAtEnd
+			}
 		})
 	}()
 	return ch
@@ -113,111 +332,315 @@ func AsChannel(iter Iterator, bufferSize ...int) <-chan entries.LogEntry {
 
 // Merge will take over the passed in iterators and forward all log entries elements to the new Iterator.
 // It's advised not to read from an iterator that has been passed to Merge.
+// Closing the merged Iterator will close both inputs.
 func Merge(a, b Iterator) Iterator {
-	aCh := AsChannel(a)
-	bCh := AsChannel(b)
+	return MergeAll(a, b)
+}
 
-	outCh := make(chan entries.LogEntry)
-	out := FromChannel(outCh)
+// MergeAll is like Merge, but for any number of iterators.
+// Each input is read in the background, and a single select loop forwards entries to the merged Iterator as they arrive, so entries from each input keep their relative order.
+// The merged Iterator ends when all inputs have ended, and closing it will close all inputs.
+func MergeAll(iters ...Iterator) Iterator {
+	switch len(iters) {
+	case 0:
+		return Empty()
+	case 1:
+		return iters[0]
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		// The last case is ctx.Done, and the rest are the inputs' reads, in order.
+		cases := make([]reflect.SelectCase, len(iters)+1)
+		for i, iter := range iters {
+			reads, stop := readAsync(ctx, iter)
+			defer stop()
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(reads)}
+		}
+		done := len(iters)
+		cases[done] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())}
 
-	go func() {
-		defer close(outCh)
-		for aCh != nil || bCh != nil {
-			select {
-			case ae, ok := <-aCh:
-				if !ok {
-					aCh = nil
-					continue
-				}
-				outCh <- ae
-			case be, ok := <-bCh:
-				if !ok {
-					bCh = nil
-					continue
+		var firstErr error
+		for remaining := len(iters); remaining > 0; {
+			chosen, val, ok := reflect.Select(cases)
+			if chosen == done {
+				return nil
+			}
+			if !ok {
+				// A zero channel value is never selected, so ended inputs are skipped.
+				cases[chosen].Chan = reflect.Value{}
+				remaining--
+				continue
+			}
+			read := val.Interface().(readResult)
+			if read.err != nil {
+				if !IsEnd(read.err) && firstErr == nil {
+					firstErr = read.err
 				}
-				outCh <- be
+				continue
+			}
+			if !emit(read.entry) {
+				return nil
 			}
 		}
-	}()
-	return out
+		return firstErr
+	}, iters...)
 }
 
 // Dupe will take control of and branch the duplicate Iterator into two identical iterators.
 // Any LogEntry posted to the source Iterator will be sent to both of the new iterators.
 // This is useful in a case similar to when you want to print messages as well as write them to a file.
 // It's not advised to read from an Iterator that has been passed to Dupe, use one of the returned iterators instead.
+// Closing one of the new iterators stops sending entries to it, and the source Iterator is closed once both have been closed.
+//
+// Operations that change entries in place - like Tag or Cutter - only affect their own branch.
+// Branches that are only read, like by a Filter and a sink, share the original LogEntry, so the common read-only case doesn't copy.
+// A branch that's read by an Iterator marked with Mutable receives a deep copy of each LogEntry, made as the branch reads it,
+// unless every branch that receives the LogEntry is marked, in which case the last of them to read it receives the original.
 func Dupe(iter Iterator) (Iterator, Iterator) {
 	if iter == nil {
 		return Empty(), Empty()
 	}
+	branches := DupeN(iter, 2)
+	return branches[0], branches[1]
+}
 
-	a := make(chan entries.LogEntry)
-	b := make(chan entries.LogEntry)
-	aiter := FromChannel(a)
-	biter := FromChannel(b)
+// dupedEntry is a LogEntry sent to Dupe branches, which is copied on read by branches that change it, as described in Dupe.
+type dupedEntry struct {
+	entry   entries.LogEntry
+	readers int32
+	// shared is true if any branch that received entry wasn't marked with Mutable, so the original is never changed.
+	shared bool
+	// copying is read locked while a branch copies entry, so the last reader doesn't receive the original until all copies have been made.
+	copying sync.RWMutex
+}
 
-	go func() {
-		sem := semaphore.NewWeighted(2)
-		ctx := context.Background()
+// take returns a LogEntry for the calling branch, which the branch owns if it's mutable.
+func (d *dupedEntry) take(mutable bool) entries.LogEntry {
+	if d.shared {
+		if mutable {
+			return d.entry.Clone()
+		}
+		return d.entry
+	}
+	d.copying.RLock()
+	if atomic.AddInt32(&d.readers, -1) > 0 {
+		defer d.copying.RUnlock()
+		return d.entry.Clone()
+	}
+	d.copying.RUnlock()
+	d.copying.Lock()
+	defer d.copying.Unlock()
+	return d.entry
+}
+
+// skip releases a branch's claim on the LogEntry without reading it.
+func (d *dupedEntry) skip() {
+	atomic.AddInt32(&d.readers, -1)
+}
 
+// DupeN is like Dupe, but branches the source Iterator into n identical iterators.
+// The source Iterator is closed once all n branches have been closed.
+func DupeN(iter Iterator, n int) []Iterator {
+	if n < 1 {
+		return nil
+	}
+	if iter == nil {
+		return emptyN(n)
+	}
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	return split(iter, n, func(entries.LogEntry, []int) []int {
+		return all
+	})
+}
+
+// split sends each entry from iter to the branches with the indices returned by targets, which may append to dst to avoid allocating.
+// If an entry is sent to more than one branch, then it's shared or copied as described in Dupe.
+// Closing a branch stops sending entries to it, and iter is closed once all branches have been closed.
+func split(iter Iterator, n int, targets func(entry entries.LogEntry, dst []int) []int) []Iterator {
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		chans     = make([]chan *dupedEntry, n)
+		closed    = make([]chan struct{}, n)
+		mutable   = make([]int32, n)
+		done      = make(chan struct{})
+		remaining = int32(n)
+		srcErr    error
+	)
+	for i := range chans {
+		chans[i] = make(chan *dupedEntry)
+		closed[i] = make(chan struct{})
+	}
+	go func() {
+		defer close(done)
 		defer func() {
-			_ = sem.Acquire(ctx, 2)
-			close(a)
-			close(b)
+			for _, ch := range chans {
+				close(ch)
+			}
 		}()
-		_ = iter.Iterate(func(entry entries.LogEntry, i int) error {
-			_ = sem.Acquire(ctx, 1)
-			go func() {
-				defer sem.Release(1)
-				a <- entry
-			}()
-			_ = sem.Acquire(ctx, 1)
-			go func() {
-				defer sem.Release(1)
-				b <- entry
-			}()
-			return nil
-		})
+		var dst []int
+		for {
+			entry, _, err := iter.Next(ctx)
+			if err != nil {
+				if !IsEnd(err) && ctx.Err() == nil {
+					srcErr = err
+				}
+				return
+			}
+			dst = targets(entry, dst[:0])
+			duped := &dupedEntry{entry: entry, readers: int32(len(dst))}
+			for _, i := range dst {
+				if atomic.LoadInt32(&mutable[i]) == 0 {
+					duped.shared = true
+				}
+			}
+			for _, i := range dst {
+				select {
+				case chans[i] <- duped:
+				case <-closed[i]:
+					duped.skip()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
 	}()
-	return aiter, biter
+	closeSource := func() error {
+		cancel()
+		<-done
+		return iter.Close()
+	}
+	branches := make([]Iterator, n)
+	for i := range branches {
+		i := i
+		var next int
+		branch := New(func(ctx context.Context) (entries.LogEntry, int, error) {
+			select {
+			case duped, ok := <-chans[i]:
+				if !ok {
+					if srcErr != nil {
+						return Err(srcErr)
+					}
+					return End()
+				}
+				cur := next
+				next++
+				return duped.take(atomic.LoadInt32(&mutable[i]) == 1), cur, nil
+			case <-ctx.Done():
+				return Err(ctx.Err())
+			}
+		}, func() error {
+			close(closed[i])
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				return closeSource()
+			}
+			return nil
+		}).(*funcIterator)
+		branch.upstream = []Iterator{iter}
+		branch.onMutable = func() {
+			atomic.StoreInt32(&mutable[i], 1)
+		}
+		branches[i] = branch
+	}
+	return branches
 }
 
 // Fanout will take control of the input Iterator and output entries received from the input Iterator to one of the output Iterators.
+// By default, each entry goes to whichever output is ready first. A partitioning strategy may be given instead, like FanoutHash.
 // It's not advised to read from the input Iterator after passing it to Fanout.
-// If an error occurs during iteration, then Drain will be called on the input.
-func Fanout(iter Iterator) (Iterator, Iterator) {
+// Closing one of the output iterators stops sending entries to it, and the input Iterator is closed once both have been closed.
+func Fanout(iter Iterator, opt ...FanoutOpt) (Iterator, Iterator) {
 	if iter == nil {
 		return Empty(), Empty()
 	}
+	branches := FanoutN(iter, 2, opt...)
+	return branches[0], branches[1]
+}
 
-	a := make(chan entries.LogEntry)
-	b := make(chan entries.LogEntry)
+// FanoutN is like Fanout, but spreads entries across n output iterators.
+// The input Iterator is closed once all n outputs have been closed.
+func FanoutN(iter Iterator, n int, opt ...FanoutOpt) []Iterator {
+	if n < 1 {
+		return nil
+	}
+	if iter == nil {
+		return emptyN(n)
+	}
+	opts := new(fanoutOpts)
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.strategy != nil {
+		return partition(iter, n, opts)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		// Every output reads from the same channel, so each entry goes to whichever output is ready first.
+		ch        = make(chan entries.LogEntry)
+		done      = make(chan struct{})
+		remaining = int32(n)
+		srcErr    error
+	)
 	go func() {
-		defer func() {
-			close(a)
-			close(b)
-		}()
-		err := iter.Iterate(func(entry entries.LogEntry, i int) error {
+		defer close(done)
+		defer close(ch)
+		for {
+			entry, _, err := iter.Next(ctx)
+			if err != nil {
+				if !IsEnd(err) && ctx.Err() == nil {
+					srcErr = err
+				}
+				return
+			}
 			select {
-			case a <- entry:
-			case b <- entry:
+			case ch <- entry:
+			case <-ctx.Done():
+				return
 			}
-			return nil
-		})
-		if err != nil {
-			Drain(iter)
 		}
 	}()
-	return FromChannel(a), FromChannel(b)
+	closeSource := func() error {
+		cancel()
+		<-done
+		return iter.Close()
+	}
+	branches := make([]Iterator, n)
+	for i := range branches {
+		var next int
+		branches[i] = withUpstream(New(func(ctx context.Context) (entries.LogEntry, int, error) {
+			select {
+			case entry, ok := <-ch:
+				if !ok {
+					if srcErr != nil {
+						return Err(srcErr)
+					}
+					return End()
+				}
+				cur := next
+				next++
+				return entry, cur, nil
+			case <-ctx.Done():
+				return Err(ctx.Err())
+			}
+		}, func() error {
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				return closeSource()
+			}
+			return nil
+		}), iter)
+	}
+	return branches
 }
 
-// Drain will drain all entries from a Iterator in a new goroutine.
-// This can be useful as an error fallback in case of an iteration error to prevent upstream blocking.
+// Drain will drain all entries from a Iterator in a new goroutine, and then close it.
+// This can be useful to let an upstream producer finish, when its entries aren't needed.
+// Use Close instead to stop an Iterator without reading the rest of its entries.
 func Drain(iter Iterator) {
-	ch := AsChannel(iter)
 	go func() {
-		for range ch {
-		}
+		_ = iter.Iterate(context.Background(), func(entries.LogEntry, int) error {
+			return nil
+		})
 	}()
 }
 
@@ -225,3 +648,11 @@ func Drain(iter Iterator) {
 func Empty() Iterator {
 	return FromSlice(nil)
 }
+
+func emptyN(n int) []Iterator {
+	iters := make([]Iterator, n)
+	for i := range iters {
+		iters[i] = Empty()
+	}
+	return iters
+}
@@ -1,29 +1,37 @@
 package iterator
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestEntrySlice_Next(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice(_testEntries())
-	a, i, err := iter.Next()
+	a, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, i)
 	assert.Equal(t, "A", a["message"])
 
-	b, i, err := iter.Next()
+	b, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, i)
 	assert.Equal(t, "B", b["message"])
 
-	c, i, err := iter.Next()
+	c, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, i)
 	assert.Equal(t, "C", c["message"])
 
-	z, i, err := iter.Next()
+	z, i, err := iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 	assert.Equal(t, -1, i)
@@ -31,23 +39,24 @@ func TestEntrySlice_Next(t *testing.T) {
 }
 
 func TestEntryChannel_Next(t *testing.T) {
+	ctx := context.Background()
 	iter := FromChannel(_testEntryChannel())
-	a, i, err := iter.Next()
+	a, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 0, i)
 	assert.Equal(t, "A", a["message"])
 
-	b, i, err := iter.Next()
+	b, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, i)
 	assert.Equal(t, "B", b["message"])
 
-	c, i, err := iter.Next()
+	c, i, err := iter.Next(ctx)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, i)
 	assert.Equal(t, "C", c["message"])
 
-	z, i, err := iter.Next()
+	z, i, err := iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 	assert.Equal(t, -1, i)
@@ -55,10 +64,11 @@ func TestEntryChannel_Next(t *testing.T) {
 }
 
 func TestEntrySlice_Iterate(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice(_testEntries())
 	count := 0
 
-	err := iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err := iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count += 1
 		return nil
 	})
@@ -67,10 +77,11 @@ func TestEntrySlice_Iterate(t *testing.T) {
 }
 
 func TestEntryChannel_Iterate(t *testing.T) {
+	ctx := context.Background()
 	iter := FromChannel(_testEntryChannel())
 	count := 0
 
-	err := iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err := iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count += 1
 		return nil
 	})
@@ -79,12 +90,13 @@ func TestEntryChannel_Iterate(t *testing.T) {
 }
 
 func TestMerge_FromSlices(t *testing.T) {
+	ctx := context.Background()
 	a := FromSlice(_testEntries())
 	b := FromSlice(_testEntries())
 	c := Merge(a, b)
 	count := 0
 
-	err := c.Iterate(func(entry entries.LogEntry, i int) error {
+	err := c.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count += 1
 		return nil
 	})
@@ -93,12 +105,13 @@ func TestMerge_FromSlices(t *testing.T) {
 }
 
 func TestMerge_FromChannels(t *testing.T) {
+	ctx := context.Background()
 	a := FromChannel(_testEntryChannel())
 	b := FromChannel(_testEntryChannel())
 	c := Merge(a, b)
 	count := 0
 
-	err := c.Iterate(func(entry entries.LogEntry, i int) error {
+	err := c.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count += 1
 		return nil
 	})
@@ -107,11 +120,12 @@ func TestMerge_FromChannels(t *testing.T) {
 }
 
 func TestDupe(t *testing.T) {
+	ctx := context.Background()
 	base := FromSlice(_testEntries())
 	a, b := Dupe(base)
 	merged := Merge(a, b)
 	count := 0
-	err := merged.Iterate(func(entry entries.LogEntry, i int) error {
+	err := merged.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count++
 		return nil
 	})
@@ -119,17 +133,382 @@ func TestDupe(t *testing.T) {
 	assert.Equal(t, 6, count)
 }
 
+func TestDupe_Isolated(t *testing.T) {
+	ctx := context.Background()
+	base := FromSlice([]entries.LogEntry{
+		{"message": "A", "http": map[string]any{"status": 200}},
+		{"message": "B", "http": map[string]any{"status": 404}},
+	})
+	a, b := Dupe(base)
+	a = Tag(a, "branch-a")
+
+	var (
+		wg     sync.WaitGroup
+		aSeen  []entries.LogEntry
+		bSeen  []entries.LogEntry
+		aError error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		aError = a.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+			assert.NoError(t, entry.Set("http.status", 500))
+			aSeen = append(aSeen, entry)
+			return nil
+		})
+	}()
+	err := b.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		bSeen = append(bSeen, entry)
+		return nil
+	})
+	wg.Wait()
+	assert.NoError(t, err)
+	assert.NoError(t, aError)
+
+	assert.Len(t, aSeen, 2)
+	assert.Len(t, bSeen, 2)
+	for i := range bSeen {
+		assert.True(t, aSeen[i].HasTag("branch-a"))
+		assert.False(t, bSeen[i].HasTag("branch-a"), "Tagging branch a shouldn't affect branch b")
+		status, _ := aSeen[i].Get("http.status")
+		assert.Equal(t, 500, status)
+	}
+	status, _ := bSeen[0].Get("http.status")
+	assert.Equal(t, 200, status, "Nested changes in branch a shouldn't affect branch b")
+	status, _ = bSeen[1].Get("http.status")
+	assert.Equal(t, 404, status, "Nested changes in branch a shouldn't affect branch b")
+}
+
+func TestDupe_Shared(t *testing.T) {
+	ctx := context.Background()
+	a, b := Dupe(FromSlice(_testEntries()))
+
+	var (
+		wg    sync.WaitGroup
+		aSeen []entries.LogEntry
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, a.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+			aSeen = append(aSeen, entry)
+			return nil
+		}))
+	}()
+	var bSeen []entries.LogEntry
+	assert.NoError(t, b.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		bSeen = append(bSeen, entry)
+		return nil
+	}))
+	wg.Wait()
+
+	require.Len(t, aSeen, 3)
+	require.Len(t, bSeen, 3)
+	for i := range aSeen {
+		assert.Equal(t, fmt.Sprintf("%p", aSeen[i]), fmt.Sprintf("%p", bSeen[i]), "Branches that are only read should share entries")
+	}
+}
+
+func TestDupe_MutableThroughFilter(t *testing.T) {
+	ctx := context.Background()
+	a, b := Dupe(FromSlice(_testEntries()))
+	a = Tag(Filter(a, func(entries.LogEntry, int, error) bool {
+		return true
+	}), "branch-a")
+
+	var (
+		wg    sync.WaitGroup
+		bSeen []entries.LogEntry
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, b.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+			bSeen = append(bSeen, entry)
+			return nil
+		}))
+	}()
+	count := 0
+	assert.NoError(t, a.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		assert.True(t, entry.HasTag("branch-a"))
+		count++
+		return nil
+	}))
+	wg.Wait()
+
+	assert.Equal(t, 3, count)
+	require.Len(t, bSeen, 3)
+	for _, entry := range bSeen {
+		assert.False(t, entry.HasTag("branch-a"), "Operators after a Filter should still give branch a its own copies")
+	}
+}
+
 func TestFanout(t *testing.T) {
+	ctx := context.Background()
 	base := FromSlice(_testEntries())
 	a, b := Fanout(base)
 	merged := Merge(a, b)
 	count := 0
-	err := merged.Iterate(func(entry entries.LogEntry, i int) error {
+	err := merged.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestMerge_Close(t *testing.T) {
+	ctx := context.Background()
+	a, aClosed := _closeTracker(FromSlice(_testEntries()))
+	b, bClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	merged := Merge(a, b)
+
+	_, _, err := merged.Next(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, merged.Close())
+	assert.True(t, aClosed(), "Input a should be closed")
+	assert.True(t, bClosed(), "Input b should be closed")
+
+	_, _, err = merged.Next(ctx)
+	assert.ErrorIs(t, err, ErrAtEnd)
+}
+
+func TestDupe_Close(t *testing.T) {
+	ctx := context.Background()
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	a, b := Dupe(base)
+
+	assert.NoError(t, a.Close())
+	assert.False(t, baseClosed(), "Source should still be open while b is open")
+
+	count := 0
+	err := b.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count, "Closing a shouldn't block b")
+	assert.True(t, baseClosed(), "Source should be closed after both branches are closed")
+}
+
+func TestFanout_Close(t *testing.T) {
+	ctx := context.Background()
+	base, baseClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	a, b := Fanout(base)
+
+	assert.NoError(t, a.Close())
+	assert.False(t, baseClosed(), "Source should still be open while b is open")
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, _, err := b.Next(cctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.NoError(t, b.Close())
+	assert.True(t, baseClosed(), "Source should be closed after both branches are closed")
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	var stopped int32
+	iter := Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		defer atomic.StoreInt32(&stopped, 1)
+		for i := 0; ; i++ {
+			if !emit(entries.LogEntry{"i": i}) {
+				return nil
+			}
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		entry, idx, err := iter.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, i, idx)
+		assert.Equal(t, i, entry["i"])
+	}
+	assert.NoError(t, iter.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stopped), "Close should wait for the generator to stop")
+
+	_, _, err := iter.Next(ctx)
+	assert.ErrorIs(t, err, ErrAtEnd)
+}
+
+func TestGenerate_Error(t *testing.T) {
+	ctx := context.Background()
+	errTest := errors.New("test error")
+	iter := Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		emit(entries.LogEntry{"message": "A"})
+		return errTest
+	})
+
+	count := 0
+	err := iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		count++
+		return nil
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, 1, count, "Emitted entries should be read before the error")
+}
+
+func TestGenerate_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	iter := Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	cancel()
+
+	_, _, err := iter.Next(context.Background())
+	assert.ErrorIs(t, err, ErrAtEnd)
+	assert.NoError(t, iter.Close())
+}
+
+func TestReadAsync_Stop(t *testing.T) {
+	var (
+		returned int32
+		entered  = make(chan struct{})
+	)
+	iter := New(func(ctx context.Context) (entries.LogEntry, int, error) {
+		close(entered)
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt32(&returned, 1)
+		return nil, -1, ctx.Err()
+	})
+
+	_, stop := readAsync(context.Background(), iter)
+	<-entered
+	stop()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&returned), "Stop should wait for the reader to leave Next")
+}
+
+func TestMergeAll_Order(t *testing.T) {
+	ctx := context.Background()
+	inputs := make([]Iterator, 5)
+	for i := range inputs {
+		slice := make([]entries.LogEntry, 100)
+		for j := range slice {
+			slice[j] = entries.LogEntry{"input": i, "seq": j}
+		}
+		inputs[i] = FromSlice(slice)
+	}
+	merged := MergeAll(inputs...)
+
+	lastSeq := map[int]int{}
+	count := 0
+	err := merged.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		assert.Equal(t, count, i, "Merged offsets should be sequential")
+		count++
+		input, seq := entry["input"].(int), entry["seq"].(int)
+		last, ok := lastSeq[input]
+		if ok {
+			assert.Equal(t, last+1, seq, "Entries from input %d should keep their order", input)
+		}
+		lastSeq[input] = seq
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 500, count)
+}
+
+func TestMergeAll_Close(t *testing.T) {
+	ctx := context.Background()
+	var (
+		inputs []Iterator
+		closed []func() bool
+	)
+	for i := 0; i < 4; i++ {
+		iter, isClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+		inputs = append(inputs, iter)
+		closed = append(closed, isClosed)
+	}
+	merged := MergeAll(inputs...)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, _, err := merged.Next(cctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.NoError(t, merged.Close())
+	for i, isClosed := range closed {
+		assert.True(t, isClosed(), "Input %d should be closed", i)
+	}
+}
+
+func TestMergeAll_Error(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+	failing := New(func(ctx context.Context) (entries.LogEntry, int, error) {
+		return Err(errBoom)
+	})
+	merged := MergeAll(FromSlice(_testEntries()), failing, FromSlice(_testEntries()))
+
+	count := 0
+	err := merged.Iterate(ctx, func(entry entries.LogEntry, i int) error {
+		count++
+		return nil
+	})
+	assert.ErrorIs(t, err, errBoom, "Input errors should be returned once the other inputs have ended")
+	assert.Equal(t, 2*len(_testEntries()), count, "Entries from the other inputs should still be merged")
+}
+
+func TestDupeN(t *testing.T) {
+	ctx := context.Background()
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	branches := DupeN(base, 3)
+	require.Len(t, branches, 3)
+
+	var (
+		wg     sync.WaitGroup
+		counts = make([]int, 3)
+	)
+	for i, branch := range branches {
+		i, branch := i, branch
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var msgs []string
+			err := branch.Iterate(ctx, func(entry entries.LogEntry, _ int) error {
+				msg, _ := entry.AsString("message")
+				msgs = append(msgs, msg)
+				counts[i]++
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"A", "B", "C"}, msgs, "Branch %d should receive entries in order", i)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []int{3, 3, 3}, counts)
+	assert.True(t, baseClosed(), "Source should be closed after all branches are closed")
+}
+
+func TestFanoutN(t *testing.T) {
+	ctx := context.Background()
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	branches := FanoutN(base, 3)
+	require.Len(t, branches, 3)
+
+	count := 0
+	err := MergeAll(branches...).Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count++
 		return nil
 	})
 	assert.NoError(t, err)
 	assert.Equal(t, 3, count)
+	assert.True(t, baseClosed(), "Source should be closed after all branches are closed")
+}
+
+// _closeTracker wraps an Iterator to report whether it has been closed.
+func _closeTracker(iter Iterator) (Iterator, func() bool) {
+	var closed int32
+	tracked := New(iter.Next, func() error {
+		atomic.StoreInt32(&closed, 1)
+		return iter.Close()
+	})
+	return tracked, func() bool {
+		return atomic.LoadInt32(&closed) == 1
+	}
 }
 
 func _testEntries() []entries.LogEntry {
@@ -1,8 +1,11 @@
 package iterator
 
 import (
+	"context"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"regexp"
+	"sort"
+	"time"
 )
 
 // Joiner will traverse an Iterator, returning messages that may be joined based on a set of startPatterns.
@@ -10,97 +13,294 @@ import (
 // Subsequent messages that do not match this pattern will have their @message field appended to the last start line.
 // If the Iterator starts with a LogEntry with a @message field that doesn't match the startPatterns, it will be added as a start anyway.
 func Joiner(iter Iterator, startPatterns ...string) Iterator {
-	opts := new(joinOpts).WithStartRegex(startPatterns...)
-	j := &joinerState{
-		iter: iter,
-		opts: opts,
-	}
-	return Func(j.nextFunc)
+	return JoinerWith(iter, JoinStart(startPatterns...))
 }
 
 type joinOpts struct {
 	startMatchRegex []*regexp.Regexp
+	contMatchRegex  []*regexp.Regexp
+	groupBy         string
+	idle            time.Duration
+	maxLines        int
+	maxBytes        int
+}
+
+// JoinOpt represents a functional option for JoinerWith.
+type JoinOpt func(opts *joinOpts)
+
+// JoinStart specifies patterns that match the first line of a message. Lines that don't match any of them are appended to the message before them.
+// Patterns that fail to compile are ignored.
+func JoinStart(patterns ...string) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.startMatchRegex = append(opts.startMatchRegex, compilePatterns(patterns)...)
+	}
+}
+
+// JoinContinuation specifies patterns that match the lines after the first line of a message, like indented stack frames.
+// Lines that match any of them are appended to the message before them, and all other lines start a new message.
+// Continuation patterns take precedence over JoinStart patterns if both are given. Patterns that fail to compile are ignored.
+func JoinContinuation(patterns ...string) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.contMatchRegex = append(opts.contMatchRegex, compilePatterns(patterns)...)
+	}
+}
+
+// JoinGroupBy joins lines separately for each value of a field, like a thread ID, so output interleaved from multiple threads isn't joined together.
+// Entries without the field are joined together as their own group.
+func JoinGroupBy(field string) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.groupBy = field
+	}
+}
+
+// JoinIdleTimeout emits a message once no lines have been appended to it for the timeout, instead of waiting for the next message to start.
+// This keeps the last message from a quiet source - like the stack trace from a crash - from being held indefinitely.
+func JoinIdleTimeout(timeout time.Duration) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.idle = timeout
+	}
+}
+
+// JoinMaxLines limits the number of lines joined into one message. A line that would exceed the limit starts a new message.
+func JoinMaxLines(lines int) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.maxLines = lines
+	}
 }
 
-func (s *joinOpts) WithStartRegex(patterns ...string) *joinOpts {
+// JoinMaxBytes limits the length of a joined message. A line that would exceed the limit starts a new message, so a single line longer than the limit isn't truncated.
+func JoinMaxBytes(bytes int) JoinOpt {
+	return func(opts *joinOpts) {
+		opts.maxBytes = bytes
+	}
+}
+
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	var compiled []*regexp.Regexp
 	for _, p := range patterns {
 		r, err := regexp.Compile(p)
 		if err == nil {
-			s.startMatchRegex = append(s.startMatchRegex, r)
+			compiled = append(compiled, r)
 		}
 	}
-	return s
+	return compiled
+}
+
+// JoinerWith is like Joiner, but with options to change how lines are joined.
+// Without JoinIdleTimeout, messages are joined as they're read, and each keeps the index of its first line.
+// With JoinIdleTimeout, messages are joined in a new goroutine so they can be emitted while waiting for the next line.
+func JoinerWith(iter Iterator, opt ...JoinOpt) Iterator {
+	opts := new(joinOpts)
+	for _, o := range opt {
+		o(opts)
+	}
+	j := &joinerState{
+		iter:   Mutable(iter),
+		opts:   opts,
+		groups: map[string]*joinGroup{},
+	}
+	if opts.idle > 0 {
+		return Generate(context.Background(), j.run, iter)
+	}
+	return Wrap(iter, j.nextFunc)
+}
+
+type joinGroup struct {
+	key      string
+	start    entries.LogEntry
+	msg      string
+	idx      int
+	lines    int
+	lastSeen time.Time
 }
 
 type joinerState struct {
-	start entries.LogEntry
-	opts  *joinOpts
-	msg   string
-	idx   int
-	iter  Iterator
+	opts   *joinOpts
+	iter   Iterator
+	groups map[string]*joinGroup
+	ready  []entries.LogEntry
+	idxs   []int
 }
 
-func (j *joinerState) isStart(entry entries.LogEntry) bool {
+func (j *joinerState) isContinuation(entry entries.LogEntry) bool {
 	msg, ok := entry.AsString(entries.StandardMessageField)
-	if !ok {
+	if len(j.opts.contMatchRegex) > 0 {
+		if !ok {
+			return false
+		}
+		for _, r := range j.opts.contMatchRegex {
+			if r.MatchString(msg) {
+				return true
+			}
+		}
 		return false
 	}
-
+	if !ok {
+		return true
+	}
 	for _, r := range j.opts.startMatchRegex {
 		if r.MatchString(msg) {
-			return true
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-func (j *joinerState) setStart(entry entries.LogEntry, idx int) {
-	j.start, j.idx = entry, idx
-	msg, ok := entry.AsString(entries.StandardMessageField)
-	if ok {
-		j.msg = msg
+func (j *joinerState) groupKey(entry entries.LogEntry) string {
+	if len(j.opts.groupBy) == 0 {
+		return ""
 	}
+	key, _ := entry.AsString(j.opts.groupBy)
+	return key
 }
 
-func (j *joinerState) appendMessage(entry entries.LogEntry) {
-	_msg, ok := entry.AsString(entries.StandardMessageField)
+// fits reports whether a line may be appended to a group without exceeding the configured limits.
+func (j *joinerState) fits(g *joinGroup, entry entries.LogEntry) bool {
+	if j.opts.maxLines > 0 && g.lines >= j.opts.maxLines {
+		return false
+	}
+	if j.opts.maxBytes > 0 {
+		if msg, ok := entry.AsString(entries.StandardMessageField); ok && len(g.msg)+1+len(msg) > j.opts.maxBytes {
+			return false
+		}
+	}
+	return true
+}
+
+// add joins an entry into its group, queuing any message that was completed by it.
+func (j *joinerState) add(entry entries.LogEntry, idx int, now time.Time) {
+	key := j.groupKey(entry)
+	g, ok := j.groups[key]
+	if ok && j.isContinuation(entry) && j.fits(g, entry) {
+		if msg, ok := entry.AsString(entries.StandardMessageField); ok {
+			g.msg += "\n" + msg
+		}
+		g.lines++
+		g.lastSeen = now
+		return
+	}
 	if ok {
-		j.msg += "\n" + _msg
+		j.finalize(g)
+	}
+	g = &joinGroup{key: key, start: entry, idx: idx, lines: 1, lastSeen: now}
+	if msg, ok := entry.AsString(entries.StandardMessageField); ok {
+		g.msg = msg
+	}
+	j.groups[key] = g
+}
+
+func (j *joinerState) finalize(g *joinGroup) {
+	delete(j.groups, g.key)
+	g.start[entries.StandardMessageField] = g.msg
+	j.ready = append(j.ready, g.start)
+	j.idxs = append(j.idxs, g.idx)
+}
+
+// flush finalizes groups that match, in the order they started.
+func (j *joinerState) flush(match func(g *joinGroup) bool) {
+	var groups []*joinGroup
+	for _, g := range j.groups {
+		if match(g) {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(a, b int) bool {
+		return groups[a].idx < groups[b].idx
+	})
+	for _, g := range groups {
+		j.finalize(g)
 	}
 }
 
-func (j *joinerState) startDefined() bool {
-	return j.start != nil
+func (j *joinerState) flushAll() {
+	j.flush(func(*joinGroup) bool {
+		return true
+	})
 }
 
-func (j *joinerState) finalizeEntry() (entries.LogEntry, int) {
-	_start, _idx := j.start, j.idx
-	_start[entries.StandardMessageField] = j.msg
-	j.start, j.idx = nil, -1
-	j.msg = ""
-	return _start, _idx
+func (j *joinerState) pop() (entries.LogEntry, int) {
+	entry, idx := j.ready[0], j.idxs[0]
+	j.ready[0] = nil
+	j.ready, j.idxs = j.ready[1:], j.idxs[1:]
+	return entry, idx
 }
 
-func (j *joinerState) nextFunc() (entries.LogEntry, int, error) {
+func (j *joinerState) nextFunc(ctx context.Context) (entries.LogEntry, int, error) {
 	for {
-		entry, i, err := j.iter.Next()
-		switch {
-		case err != nil:
-			if j.startDefined() {
-				final, i := j.finalizeEntry()
-				return final, i, nil
+		if len(j.ready) > 0 {
+			entry, idx := j.pop()
+			return entry, idx, nil
+		}
+		entry, i, err := j.iter.Next(ctx)
+		if err != nil {
+			j.flushAll()
+			if len(j.ready) > 0 {
+				continue
 			}
 			return nil, -1, err
-		case j.startDefined():
-			if j.isStart(entry) {
-				final, _i := j.finalizeEntry()
-				j.setStart(entry, i)
-				return final, _i, nil
+		}
+		j.add(entry, i, time.Time{})
+	}
+}
+
+func (j *joinerState) run(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+	reads, stop := readAsync(ctx, j.iter)
+	defer stop()
+	timer := time.NewTimer(j.opts.idle)
+	defer timer.Stop()
+	emitReady := func() bool {
+		for len(j.ready) > 0 {
+			entry, _ := j.pop()
+			if !emit(entry) {
+				return false
+			}
+		}
+		return true
+	}
+	nextIdle := func() time.Time {
+		var next time.Time
+		for _, g := range j.groups {
+			if next.IsZero() || g.lastSeen.Before(next) {
+				next = g.lastSeen
+			}
+		}
+		if next.IsZero() {
+			return next
+		}
+		return next.Add(j.opts.idle)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-timer.C:
+			j.flush(func(g *joinGroup) bool {
+				return !g.lastSeen.Add(j.opts.idle).After(now)
+			})
+			if !emitReady() {
+				return nil
+			}
+			resetTimer(timer, nextIdle())
+		case res, ok := <-reads:
+			if !ok {
+				return nil
+			}
+			if res.err != nil {
+				j.flushAll()
+				if !emitReady() {
+					return nil
+				}
+				if !IsEnd(res.err) && ctx.Err() == nil {
+					return res.err
+				}
+				return nil
+			}
+			j.add(res.entry, res.idx, time.Now())
+			if !emitReady() {
+				return nil
 			}
-			j.appendMessage(entry)
-			continue
-		default:
-			j.setStart(entry, i)
+			resetTimer(timer, nextIdle())
 		}
 	}
 }
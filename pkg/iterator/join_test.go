@@ -1,12 +1,16 @@
 package iterator
 
 import (
+	"context"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestJoiner(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice([]entries.LogEntry{
 		entries.FromString("start entry"),
 		entries.FromString("another entry"),
@@ -14,43 +18,133 @@ func TestJoiner(t *testing.T) {
 	})
 	iter = Joiner(iter, `^start`)
 
-	first, _, err := iter.Next()
+	first, _, err := iter.Next(ctx)
 	msg, ok := first.AsString(entries.StandardMessageField)
 	assert.NoError(t, err)
 	assert.True(t, ok, "Message should be defined on first log event")
 	assert.Equal(t, "start entry\nanother entry", msg)
 
-	second, _, err := iter.Next()
+	second, _, err := iter.Next(ctx)
 	msg, ok = second.AsString(entries.StandardMessageField)
 	assert.NoError(t, err)
 	assert.True(t, ok, "Message should be defined on second log event")
 	assert.Equal(t, "start complete", msg)
 
-	_, _, err = iter.Next()
+	_, _, err = iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 }
 
 func TestJoiner_Midstream_read(t *testing.T) {
+	ctx := context.Background()
 	iter := FromSlice([]entries.LogEntry{
 		entries.FromString("another entry"),
 		entries.FromString("start complete"),
 	})
 	iter = Joiner(iter, `^start`)
 
-	first, _, err := iter.Next()
+	first, _, err := iter.Next(ctx)
 	msg, ok := first.AsString(entries.StandardMessageField)
 	assert.NoError(t, err)
 	assert.True(t, ok, "Message should be defined on first log event")
 	assert.Equal(t, "another entry", msg)
 
-	second, _, err := iter.Next()
+	second, _, err := iter.Next(ctx)
 	msg, ok = second.AsString(entries.StandardMessageField)
 	assert.NoError(t, err)
 	assert.True(t, ok, "Message should be defined on second log event")
 	assert.Equal(t, "start complete", msg)
 
-	_, _, err = iter.Next()
+	_, _, err = iter.Next(ctx)
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, ErrAtEnd)
 }
+
+func TestJoiner_Close(t *testing.T) {
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	iter := Joiner(base, `^start`)
+	assert.NoError(t, iter.Close())
+	assert.True(t, baseClosed(), "Upstream should be closed")
+}
+
+func TestJoinerWith(t *testing.T) {
+	tests := map[string]struct {
+		input    []entries.LogEntry
+		opts     []JoinOpt
+		expected []string
+	}{
+		"Continuation": {
+			input: []entries.LogEntry{
+				entries.FromString("Exception in thread main"),
+				entries.FromString("\tat com.example.Main"),
+				entries.FromString("\tat com.example.App"),
+				entries.FromString("Shutting down"),
+			},
+			opts:     []JoinOpt{JoinContinuation(`^\s+at `)},
+			expected: []string{"Exception in thread main\n\tat com.example.Main\n\tat com.example.App", "Shutting down"},
+		},
+		"Group by": {
+			input: []entries.LogEntry{
+				{"@message": "start a", "thread": "1"},
+				{"@message": "start b", "thread": "2"},
+				{"@message": "more a", "thread": "1"},
+				{"@message": "more b", "thread": "2"},
+				{"@message": "start c", "thread": "1"},
+			},
+			opts:     []JoinOpt{JoinStart(`^start`), JoinGroupBy("thread")},
+			expected: []string{"start a\nmore a", "start b\nmore b", "start c"},
+		},
+		"Max lines": {
+			input: []entries.LogEntry{
+				entries.FromString("start"),
+				entries.FromString("1"),
+				entries.FromString("2"),
+				entries.FromString("3"),
+			},
+			opts:     []JoinOpt{JoinStart(`^start`), JoinMaxLines(2)},
+			expected: []string{"start\n1", "2\n3"},
+		},
+		"Max bytes": {
+			input: []entries.LogEntry{
+				entries.FromString("start"),
+				entries.FromString("12345"),
+				entries.FromString("67890"),
+			},
+			opts:     []JoinOpt{JoinStart(`^start`), JoinMaxBytes(12)},
+			expected: []string{"start\n12345", "67890"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var result []string
+			err := JoinerWith(FromSlice(tc.input), tc.opts...).Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+				msg, _ := entry.AsString(entries.StandardMessageField)
+				result = append(result, msg)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestJoinerWith_IdleTimeout(t *testing.T) {
+	ch := make(chan entries.LogEntry)
+	iter := JoinerWith(FromChannel(ch), JoinStart(`^panic`), JoinIdleTimeout(50*time.Millisecond))
+	defer func() {
+		_ = iter.Close()
+	}()
+	go func() {
+		ch <- entries.FromString("panic: oops")
+		ch <- entries.FromString("goroutine 1 [running]:")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entry, _, err := iter.Next(ctx)
+	require.NoError(t, err, "The message should be emitted once the source is idle, without waiting for the next start line")
+	msg, _ := entry.AsString(entries.StandardMessageField)
+	assert.Equal(t, "panic: oops\ngoroutine 1 [running]:", msg)
+}
@@ -0,0 +1,18 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// LevelNormalizer runs entries.NormalizeLevel on each entry that passes through the Iterator.
+func LevelNormalizer(iter Iterator, opt ...entries.LevelOpt) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.NormalizeLevel(entry, opt...)
+		return entry, i, nil
+	})
+}
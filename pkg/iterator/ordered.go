@@ -0,0 +1,285 @@
+package iterator
+
+import (
+	"container/heap"
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"sort"
+	"time"
+)
+
+// OrderMode specifies how OrderedMerge puts entries in order.
+type OrderMode int
+
+const (
+	// OrderStrict performs a k-way merge that assumes each input is already in order, like most log files.
+	// An entry is only emitted once every open input has an entry waiting, so an idle input will stall the merge.
+	OrderStrict OrderMode = iota
+	// OrderWatermark buffers entries to reorder them within a bounded out-of-order tolerance, which is better suited to live sources like file.Tail.
+	OrderWatermark
+	// OrderSort reads all inputs to the end before emitting anything, and sorts all entries. This only works for finite sources.
+	OrderSort
+)
+
+type orderedOpts struct {
+	field     string
+	mode      OrderMode
+	tolerance time.Duration
+	timeOpts  []entries.TimestampOpt
+}
+
+// OrderedOpt represents a functional option for OrderedMerge.
+type OrderedOpt func(opts *orderedOpts)
+
+// OrderField specifies the field that holds each entry's time. Defaults to entries.StandardTimestampField.
+// Values are interpreted with entries.ParseTimestamp, using any given options.
+func OrderField(field string, opt ...entries.TimestampOpt) OrderedOpt {
+	return func(opts *orderedOpts) {
+		opts.field = field
+		opts.timeOpts = opt
+	}
+}
+
+// OrderTolerance selects OrderWatermark mode, where entries may arrive up to the given duration out of order.
+// An entry is emitted once an entry at least tolerance later has been read from any input, or once it's been buffered for tolerance, so idle inputs don't hold entries back.
+func OrderTolerance(tolerance time.Duration) OrderedOpt {
+	return func(opts *orderedOpts) {
+		opts.mode = OrderWatermark
+		opts.tolerance = tolerance
+	}
+}
+
+// OrderSorted selects OrderSort mode.
+func OrderSorted() OrderedOpt {
+	return func(opts *orderedOpts) {
+		opts.mode = OrderSort
+	}
+}
+
+type orderedItem struct {
+	entry   entries.LogEntry
+	time    time.Time
+	seq     int
+	arrived time.Time
+	emitted bool
+}
+
+// orderedHeap is a min-heap of entries by time, with ties broken by the order they were read.
+type orderedHeap []*orderedItem
+
+func (h orderedHeap) Len() int { return len(h) }
+func (h orderedHeap) Less(i, j int) bool {
+	if h[i].time.Equal(h[j].time) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].time.Before(h[j].time)
+}
+func (h orderedHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *orderedHeap) Push(x any)   { *h = append(*h, x.(*orderedItem)) }
+func (h *orderedHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// OrderedMerge merges the inputs into a single Iterator ordered by time, instead of the order entries happen to be read like with MergeAll.
+// The mode defaults to OrderStrict, and may be changed with OrderTolerance or OrderSorted.
+// Entries without a time in the order field can't be ordered, so they're emitted as soon as they're read.
+// Closing the merged Iterator will close all inputs.
+func OrderedMerge(iters []Iterator, opt ...OrderedOpt) Iterator {
+	opts := &orderedOpts{
+		field: entries.StandardTimestampField,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if len(iters) == 0 {
+		return Empty()
+	}
+	timeOf := func(entry entries.LogEntry) (time.Time, bool) {
+		val, ok := entry.Get(opts.field)
+		if !ok {
+			return time.Time{}, false
+		}
+		return entries.ParseTimestamp(val, opts.timeOpts...)
+	}
+
+	switch opts.mode {
+	case OrderWatermark:
+		merged := MergeAll(iters...)
+		return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+			return watermarkMerge(ctx, merged, opts.tolerance, timeOf, emit)
+		}, merged)
+	case OrderSort:
+		merged := MergeAll(iters...)
+		return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+			return sortMerge(ctx, merged, timeOf, emit)
+		}, merged)
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		return strictMerge(ctx, iters, timeOf, emit)
+	}, iters...)
+}
+
+func strictMerge(ctx context.Context, iters []Iterator, timeOf func(entries.LogEntry) (time.Time, bool), emit func(entries.LogEntry) bool) error {
+	var (
+		heads = make([]*orderedItem, len(iters))
+		ended = make([]bool, len(iters))
+	)
+	for {
+		for i, iter := range iters {
+			for heads[i] == nil && !ended[i] {
+				entry, _, err := iter.Next(ctx)
+				if err != nil {
+					if !IsEnd(err) && ctx.Err() == nil {
+						return err
+					}
+					if ctx.Err() != nil {
+						return nil
+					}
+					ended[i] = true
+					break
+				}
+				t, ok := timeOf(entry)
+				if !ok {
+					if !emit(entry) {
+						return nil
+					}
+					continue
+				}
+				heads[i] = &orderedItem{entry: entry, time: t}
+			}
+		}
+		next := -1
+		for i, head := range heads {
+			// Earlier inputs win ties, to keep the merge stable.
+			if head != nil && (next < 0 || head.time.Before(heads[next].time)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		if !emit(heads[next].entry) {
+			return nil
+		}
+		heads[next] = nil
+	}
+}
+
+func sortMerge(ctx context.Context, merged Iterator, timeOf func(entries.LogEntry) (time.Time, bool), emit func(entries.LogEntry) bool) error {
+	var items []*orderedItem
+	for {
+		entry, _, err := merged.Next(ctx)
+		if err != nil {
+			if !IsEnd(err) && ctx.Err() == nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			break
+		}
+		t, ok := timeOf(entry)
+		if !ok {
+			if !emit(entry) {
+				return nil
+			}
+			continue
+		}
+		items = append(items, &orderedItem{entry: entry, time: t, seq: len(items)})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].time.Before(items[j].time)
+	})
+	for _, item := range items {
+		if !emit(item.entry) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func watermarkMerge(ctx context.Context, merged Iterator, tolerance time.Duration, timeOf func(entries.LogEntry) (time.Time, bool), emit func(entries.LogEntry) bool) error {
+	reads, stop := readAsync(ctx, merged)
+	defer stop()
+	var (
+		buffered orderedHeap
+		// arrivals holds buffered items in the order they were read, to find the one that's been waiting the longest.
+		arrivals    []*orderedItem
+		maxSeen     time.Time
+		lastEmitted time.Time
+		seq         int
+		timer       = time.NewTimer(tolerance)
+	)
+	defer timer.Stop()
+
+	// flush emits buffered entries up to and including the given time.
+	flush := func(until time.Time, all bool) bool {
+		for buffered.Len() > 0 && (all || !buffered[0].time.After(until)) {
+			item := heap.Pop(&buffered).(*orderedItem)
+			item.emitted = true
+			lastEmitted = item.time
+			if !emit(item.entry) {
+				return false
+			}
+		}
+		for len(arrivals) > 0 && arrivals[0].emitted {
+			arrivals[0] = nil
+			arrivals = arrivals[1:]
+		}
+		return true
+	}
+	nextTimeout := func() time.Time {
+		if len(arrivals) == 0 {
+			return time.Time{}
+		}
+		return arrivals[0].arrived.Add(tolerance)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			// The oldest buffered entry has waited long enough, so it and everything before it are released.
+			if len(arrivals) > 0 && !flush(arrivals[0].time, false) {
+				return nil
+			}
+			resetTimer(timer, nextTimeout())
+		case res, ok := <-reads:
+			if !ok {
+				return nil
+			}
+			if res.err != nil {
+				if !IsEnd(res.err) && ctx.Err() == nil {
+					flush(time.Time{}, true)
+					return res.err
+				}
+				flush(time.Time{}, true)
+				return nil
+			}
+			t, timed := timeOf(res.entry)
+			if !timed || t.Before(lastEmitted) {
+				// Entries without a time, or that are later than the tolerance allows, can't be put in order.
+				if !emit(res.entry) {
+					return nil
+				}
+				continue
+			}
+			item := &orderedItem{entry: res.entry, time: t, seq: seq, arrived: time.Now()}
+			seq++
+			heap.Push(&buffered, item)
+			arrivals = append(arrivals, item)
+			if t.After(maxSeen) {
+				maxSeen = t
+			}
+			if !flush(maxSeen.Add(-tolerance), false) {
+				return nil
+			}
+			resetTimer(timer, nextTimeout())
+		}
+	}
+}
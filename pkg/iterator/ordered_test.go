@@ -0,0 +1,122 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestOrderedMerge(t *testing.T) {
+	app := FromSlice([]entries.LogEntry{
+		{"src": "app", "@timestamp": "2023-01-01T00:00:01Z"},
+		{"src": "app", "@timestamp": "2023-01-01T00:00:03Z"},
+		{"src": "app", "@timestamp": "2023-01-01T00:00:05Z"},
+	})
+	proxy := FromSlice([]entries.LogEntry{
+		{"src": "proxy", "@timestamp": "2023-01-01T00:00:02Z"},
+		{"src": "proxy"},
+		{"src": "proxy", "@timestamp": "2023-01-01T00:00:03Z"},
+		{"src": "proxy", "@timestamp": "2023-01-01T00:00:04Z"},
+	})
+	result := _orderedResult(t, OrderedMerge([]Iterator{app, proxy}))
+	assert.Equal(t, []string{
+		"app 2023-01-01T00:00:01Z",
+		"proxy 2023-01-01T00:00:02Z",
+		"proxy ",
+		"app 2023-01-01T00:00:03Z",
+		"proxy 2023-01-01T00:00:03Z",
+		"proxy 2023-01-01T00:00:04Z",
+		"app 2023-01-01T00:00:05Z",
+	}, result)
+}
+
+func TestOrderedMerge_Sorted(t *testing.T) {
+	a := FromSlice([]entries.LogEntry{
+		{"src": "a", "ts": 1672531203},
+		{"src": "a", "ts": 1672531201},
+	})
+	b := FromSlice([]entries.LogEntry{
+		{"src": "b", "ts": 1672531202},
+		{"src": "b"},
+	})
+	var result []any
+	err := OrderedMerge([]Iterator{a, b}, OrderSorted(), OrderField("ts")).Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		result = append(result, entry["ts"])
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil, 1672531201, 1672531202, 1672531203}, result, "Entries without a time should be emitted as they're read, before sorted entries")
+}
+
+func TestOrderedMerge_Watermark(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) entries.LogEntry {
+		return entries.LogEntry{"@timestamp": base.Add(time.Duration(sec) * time.Second).Format(time.RFC3339)}
+	}
+	ch := make(chan entries.LogEntry)
+	merged := OrderedMerge([]Iterator{FromChannel(ch)}, OrderTolerance(5*time.Second))
+	defer func() {
+		_ = merged.Close()
+	}()
+	go func() {
+		for _, sec := range []int{3, 1, 2, 10} {
+			ch <- at(sec)
+		}
+	}()
+
+	// Entries at least 5 seconds before the latest are released in order once 10 is read.
+	for _, sec := range []int{1, 2, 3} {
+		entry, _, err := merged.Next(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, at(sec)["@timestamp"], entry["@timestamp"])
+	}
+
+	// The latest entry is held until a later entry is read, or it's been buffered for the tolerance.
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, _, err := merged.Next(cctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "The latest entry should be held until the tolerance has passed")
+}
+
+func TestOrderedMerge_WatermarkIdle(t *testing.T) {
+	ctx := context.Background()
+	ch := make(chan entries.LogEntry)
+	merged := OrderedMerge([]Iterator{FromChannel(ch)}, OrderTolerance(50*time.Millisecond))
+	defer func() {
+		_ = merged.Close()
+	}()
+	go func() {
+		ch <- entries.LogEntry{"@timestamp": "2023-01-01T00:00:00Z"}
+	}()
+
+	cctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	entry, _, err := merged.Next(cctx)
+	require.NoError(t, err, "An idle input shouldn't hold entries back longer than the tolerance")
+	assert.Equal(t, "2023-01-01T00:00:00Z", entry["@timestamp"])
+}
+
+func TestOrderedMerge_Close(t *testing.T) {
+	a, aClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	b, bClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	merged := OrderedMerge([]Iterator{a, b}, OrderTolerance(time.Second))
+	assert.NoError(t, merged.Close())
+	assert.True(t, aClosed())
+	assert.True(t, bClosed())
+}
+
+func _orderedResult(t *testing.T, iter Iterator) []string {
+	var result []string
+	err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		src, _ := entry.AsString("src")
+		ts, _ := entry.AsString("@timestamp")
+		result = append(result, src+" "+ts)
+		return nil
+	})
+	require.NoError(t, err)
+	return result
+}
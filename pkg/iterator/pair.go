@@ -0,0 +1,308 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PairMode determines which entries are emitted by Pair when they have no match in the other stream.
+type PairMode int
+
+const (
+	// PairInner only emits entries that were paired.
+	PairInner PairMode = iota
+	// PairLeft also emits entries from the left stream that weren't paired within the window.
+	PairLeft
+	// PairFull also emits entries from either stream that weren't paired within the window.
+	PairFull
+)
+
+var pairModeNames = [...]string{"inner", "left", "full"}
+
+func (m PairMode) String() string {
+	if m < PairInner || int(m) >= len(pairModeNames) {
+		return fmt.Sprintf("PairMode(%d)", int(m))
+	}
+	return pairModeNames[m]
+}
+
+// ParsePairMode returns the PairMode with the given name: inner, left, or full.
+func ParsePairMode(name string) (PairMode, bool) {
+	for i, n := range pairModeNames {
+		if n == strings.ToLower(name) {
+			return PairMode(i), true
+		}
+	}
+	return PairInner, false
+}
+
+var ErrInvalidPair = errors.New("invalid pair")
+
+// DefaultPairRightPrefix is prepended to fields from the right stream that are also in the left stream, unless PairPrefix is given.
+// This nests them in a "right" object.
+const DefaultPairRightPrefix = "right."
+
+type pairOpts struct {
+	rightKey    string
+	window      time.Duration
+	mode        PairMode
+	leftPrefix  string
+	rightPrefix string
+}
+
+// PairOpt represents a functional option for Pair.
+type PairOpt func(opts *pairOpts)
+
+// PairWindow specifies how long an entry may wait for a match from the other stream after it's read. This is required.
+func PairWindow(window time.Duration) PairOpt {
+	return func(opts *pairOpts) {
+		opts.window = window
+	}
+}
+
+// PairRightKey specifies the key field in the right stream, if it's different from the left stream.
+func PairRightKey(field string) PairOpt {
+	return func(opts *pairOpts) {
+		opts.rightKey = field
+	}
+}
+
+// PairWith specifies the PairMode. Defaults to PairInner.
+func PairWith(mode PairMode) PairOpt {
+	return func(opts *pairOpts) {
+		opts.mode = mode
+	}
+}
+
+// PairPrefix specifies the prefixes prepended to fields that are in both paired entries.
+// By default, the left stream's fields keep their names, and the right stream's are prefixed with DefaultPairRightPrefix.
+// Prefixed names are field paths, so a prefix ending with a dot - like "app." - nests the fields in an object.
+// An empty prefix keeps that stream's field names, so the prefixes must be different.
+func PairPrefix(left, right string) PairOpt {
+	return func(opts *pairOpts) {
+		opts.leftPrefix = left
+		opts.rightPrefix = right
+	}
+}
+
+type pairItem struct {
+	entry   entries.LogEntry
+	key     string
+	expires time.Time
+	matched bool
+}
+
+type pairSide struct {
+	keyField string
+	emit     bool
+	// queue holds buffered entries in the order they were read, which is also the order they expire.
+	queue []*pairItem
+	byKey map[string][]*pairItem
+	done  bool
+}
+
+func (s *pairSide) remove(item *pairItem) {
+	items := s.byKey[item.key]
+	for i, it := range items {
+		if it == item {
+			items = append(items[:i], items[i+1:]...)
+			break
+		}
+	}
+	if len(items) == 0 {
+		delete(s.byKey, item.key)
+		return
+	}
+	s.byKey[item.key] = items
+}
+
+type pairer struct {
+	opts  *pairOpts
+	sides [2]*pairSide
+}
+
+// Pair joins entries from two streams that have the same value in a key field, like enriching proxy access logs with the application log line for each request ID.
+// Entries are buffered for the PairWindow after they're read, and each entry is paired with every entry in the other stream's buffer with the same key.
+// A pair is emitted as one entry as soon as its second entry is read, with fields from both entries. Fields that are in both are prefixed as described in PairPrefix,
+// except for a top level key field if both streams use the same name. With PairLeft or PairFull, entries that weren't paired are emitted on their own when they leave the buffer,
+// or as soon as they're read if they don't have the key field. Both upstream iterators are closed when the returned Iterator is closed.
+func Pair(left, right Iterator, key string, opt ...PairOpt) (Iterator, error) {
+	opts := &pairOpts{
+		rightKey:    key,
+		rightPrefix: DefaultPairRightPrefix,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.window <= 0 {
+		return nil, fmt.Errorf("%w: a positive window is required", ErrInvalidWindow)
+	}
+	if opts.leftPrefix == opts.rightPrefix {
+		return nil, fmt.Errorf("%w: prefixes for colliding fields must be different", ErrInvalidPair)
+	}
+	p := &pairer{
+		opts: opts,
+		sides: [2]*pairSide{
+			{keyField: key, emit: opts.mode != PairInner, byKey: map[string][]*pairItem{}},
+			{keyField: opts.rightKey, emit: opts.mode == PairFull, byKey: map[string][]*pairItem{}},
+		},
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		return p.run(ctx, [2]Iterator{left, right}, emit)
+	}, left, right), nil
+}
+
+func (p *pairer) run(ctx context.Context, iters [2]Iterator, emit func(entry entries.LogEntry) bool) error {
+	var reads [2]<-chan readResult
+	for side, iter := range iters {
+		ch, stop := readAsync(ctx, iter)
+		defer stop()
+		reads[side] = ch
+	}
+	timer := time.NewTimer(p.opts.window)
+	defer timer.Stop()
+	nextExpiry := func() time.Time {
+		var next time.Time
+		for _, s := range p.sides {
+			if len(s.queue) > 0 && (next.IsZero() || s.queue[0].expires.Before(next)) {
+				next = s.queue[0].expires
+			}
+		}
+		return next
+	}
+	read := func(side int, res readResult, ok bool) (bool, error) {
+		if !ok {
+			return false, nil
+		}
+		if res.err != nil {
+			if !IsEnd(res.err) && ctx.Err() == nil {
+				return false, res.err
+			}
+			// A nil channel is never selected, so reading continues from the other side.
+			p.sides[side].done, reads[side] = true, nil
+			if p.sides[0].done && p.sides[1].done {
+				p.expire(time.Time{}, emit)
+				return false, nil
+			}
+			return true, nil
+		}
+		now := time.Now()
+		if !p.expire(now, emit) || !p.add(side, res.entry, now, emit) {
+			return false, nil
+		}
+		resetTimer(timer, nextExpiry())
+		return true, nil
+	}
+
+	for {
+		var (
+			more bool
+			err  error
+		)
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-timer.C:
+			if !p.expire(now, emit) {
+				return nil
+			}
+			resetTimer(timer, nextExpiry())
+			continue
+		case res, ok := <-reads[0]:
+			more, err = read(0, res, ok)
+		case res, ok := <-reads[1]:
+			more, err = read(1, res, ok)
+		}
+		if !more {
+			return err
+		}
+	}
+}
+
+// add pairs an entry with buffered entries from the other stream, then buffers it.
+func (p *pairer) add(side int, entry entries.LogEntry, now time.Time, emit func(entry entries.LogEntry) bool) bool {
+	s, other := p.sides[side], p.sides[1-side]
+	if _, ok := entry.Get(s.keyField); !ok {
+		if s.emit {
+			return emit(entry)
+		}
+		return true
+	}
+	key, _ := entry.AsString(s.keyField)
+	item := &pairItem{entry: entry, key: key, expires: now.Add(p.opts.window)}
+	for _, match := range other.byKey[key] {
+		item.matched, match.matched = true, true
+		var paired entries.LogEntry
+		if side == 0 {
+			paired = p.pair(entry, match.entry)
+		} else {
+			paired = p.pair(match.entry, entry)
+		}
+		if !emit(paired) {
+			return false
+		}
+	}
+	s.queue = append(s.queue, item)
+	s.byKey[key] = append(s.byKey[key], item)
+	return true
+}
+
+// expire removes entries that have been buffered for the window by now, emitting them if they weren't paired and the mode allows.
+// A zero time expires all entries.
+func (p *pairer) expire(now time.Time, emit func(entry entries.LogEntry) bool) bool {
+	for _, s := range p.sides {
+		for len(s.queue) > 0 {
+			item := s.queue[0]
+			if !now.IsZero() && item.expires.After(now) {
+				break
+			}
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.remove(item)
+			if s.emit && !item.matched && !emit(item.entry) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pair combines the fields of two entries into a new entry.
+func (p *pairer) pair(left, right entries.LogEntry) entries.LogEntry {
+	left, right = left.Clone(), right.Clone()
+	var (
+		paired    = make(entries.LogEntry, len(left)+len(right))
+		collided  []string
+		sharedKey = p.sides[0].keyField == p.sides[1].keyField
+	)
+	for k, v := range left {
+		if _, ok := right[k]; ok && !(sharedKey && k == p.sides[0].keyField) {
+			collided = append(collided, k)
+			continue
+		}
+		paired[k] = v
+	}
+	for k, v := range right {
+		if _, ok := left[k]; !ok {
+			paired[k] = v
+		}
+	}
+	// Prefixed fields are set last, so a prefix like "right." nests them in an object without being overwritten.
+	sort.Strings(collided)
+	for _, k := range collided {
+		p.setPrefixed(paired, p.opts.leftPrefix, k, left[k])
+		p.setPrefixed(paired, p.opts.rightPrefix, k, right[k])
+	}
+	return paired
+}
+
+func (p *pairer) setPrefixed(entry entries.LogEntry, prefix, key string, val any) {
+	if err := entry.Set(prefix+entries.EscapePathKey(key), val); err != nil {
+		entry[prefix+key] = val
+	}
+}
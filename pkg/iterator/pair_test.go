@@ -0,0 +1,128 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPair(t *testing.T) {
+	tests := map[string]struct {
+		mode     PairMode
+		expected []string
+	}{
+		"Inner": {
+			mode:     PairInner,
+			expected: []string{"r1 GET /a + handled a"},
+		},
+		"Left": {
+			mode:     PairLeft,
+			expected: []string{"r1 GET /a + handled a", "r2 GET /b", "no key"},
+		},
+		"Full": {
+			mode:     PairFull,
+			expected: []string{"r1 GET /a + handled a", "r2 GET /b", "r3 handled c", "no key"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			left := FromSlice([]entries.LogEntry{
+				{"request_id": "r1", "@message": "GET /a"},
+				{"request_id": "r2", "@message": "GET /b"},
+				{"@message": "no key"},
+			})
+			right := FromSlice([]entries.LogEntry{
+				{"request_id": "r1", "@message": "handled a"},
+				{"request_id": "r3", "@message": "handled c"},
+			})
+			iter, err := Pair(left, right, "request_id", PairWindow(time.Minute), PairWith(tc.mode))
+			require.NoError(t, err)
+
+			var result []string
+			for _, entry := range _aggregateResult(t, iter) {
+				s := entry.Format("%s %s", "request_id", "@message")
+				if msg, ok := entry.AsString("right.@message"); ok {
+					s += " + " + msg
+				}
+				if !entry.HasField("request_id") {
+					s, _ = entry.AsString("@message")
+				}
+				result = append(result, s)
+			}
+			sort.Strings(result)
+			sort.Strings(tc.expected)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestPair_Fields(t *testing.T) {
+	left := FromSlice([]entries.LogEntry{
+		{"request_id": "r1", "@message": "GET /a", "status": 200},
+	})
+	right := FromSlice([]entries.LogEntry{
+		{"req": "r1", "@message": "handled a", "user": "bob"},
+		{"req": "r1", "@message": "queried db"},
+	})
+	iter, err := Pair(left, right, "request_id", PairWindow(time.Minute), PairRightKey("req"), PairPrefix("proxy_", "app."))
+	require.NoError(t, err)
+
+	result := _aggregateResult(t, iter)
+	require.Len(t, result, 2, "An entry should be paired with every matching entry")
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := result[i].AsString("app.@message")
+		b, _ := result[j].AsString("app.@message")
+		return a < b
+	})
+	assert.Equal(t, entries.LogEntry{
+		"request_id": "r1", "req": "r1", "status": 200, "user": "bob",
+		"proxy_@message": "GET /a", "app": map[string]any{"@message": "handled a"},
+	}, result[0])
+	msg, _ := result[1].AsString("app.@message")
+	assert.Equal(t, "queried db", msg)
+}
+
+func TestPair_Window(t *testing.T) {
+	left, right := make(chan entries.LogEntry), make(chan entries.LogEntry)
+	iter, err := Pair(FromChannel(left), FromChannel(right), "id", PairWindow(50*time.Millisecond), PairWith(PairLeft))
+	require.NoError(t, err)
+	defer func() {
+		_ = iter.Close()
+	}()
+	left <- entries.LogEntry{"id": 1, "@message": "waiting"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	entry, _, err := iter.Next(ctx)
+	require.NoError(t, err, "Unpaired entries should be emitted once the window ends")
+	assert.Equal(t, entries.LogEntry{"id": 1, "@message": "waiting"}, entry)
+
+	left <- entries.LogEntry{"id": 2, "@message": "left"}
+	right <- entries.LogEntry{"id": 2, "@message": "right"}
+	entry, _, err = iter.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"@message": "right"}, entry["right"])
+}
+
+func TestPair_Invalid(t *testing.T) {
+	_, err := Pair(Empty(), Empty(), "id")
+	assert.ErrorIs(t, err, ErrInvalidWindow)
+	_, err = Pair(Empty(), Empty(), "id", PairWindow(time.Minute), PairPrefix("x_", "x_"))
+	assert.ErrorIs(t, err, ErrInvalidPair)
+}
+
+func TestPair_Close(t *testing.T) {
+	left, leftClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	right, rightClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	iter, err := Pair(left, right, "id", PairWindow(time.Minute))
+	require.NoError(t, err)
+	assert.NoError(t, iter.Close())
+	assert.True(t, leftClosed())
+	assert.True(t, rightClosed())
+}
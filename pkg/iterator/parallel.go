@@ -0,0 +1,130 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"runtime"
+	"sync"
+)
+
+// Stage is an operator that wraps an Iterator, like Cutter or Transformer.
+type Stage func(iter Iterator) Iterator
+
+type parallelJob struct {
+	entry   entries.LogEntry
+	results []entries.LogEntry
+	err     error
+	done    chan struct{}
+}
+
+// ParallelMap runs fn on each entry from the input Iterator using a pool of worker goroutines, while keeping entries in the order they were read.
+// If fn returns entries.ErrSkipEntry, then the entry is dropped. Any other error is returned from Next after the entries before it.
+// The number of workers defaults to the number of CPUs if it's less than 1, and fn must be safe to call from multiple goroutines.
+func ParallelMap(iter Iterator, workers int, fn func(entry entries.LogEntry) (entries.LogEntry, error)) Iterator {
+	return parallel(iter, workers, func(_ context.Context, entry entries.LogEntry) ([]entries.LogEntry, error) {
+		entry, err := fn(entry)
+		if err != nil {
+			if errors.Is(err, entries.ErrSkipEntry) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []entries.LogEntry{entry}, nil
+	})
+}
+
+// Parallel runs a Stage on each entry from the input Iterator using a pool of worker goroutines, while keeping entries in the order they were read.
+// This allows existing operators to be run in parallel, like:
+//
+//	Parallel(iter, 4, func(iter Iterator) Iterator {
+//		return Cutter(iter, opts...)
+//	})
+//
+// The Stage is applied to each entry separately, so it may drop an entry or return many, but it must not depend on state from other entries.
+// For example, Templates or a CSV Parser that reads its header from the first line will not work as expected.
+func Parallel(iter Iterator, workers int, stage Stage) Iterator {
+	return parallel(iter, workers, func(ctx context.Context, entry entries.LogEntry) ([]entries.LogEntry, error) {
+		var results []entries.LogEntry
+		err := stage(FromSlice([]entries.LogEntry{entry})).Iterate(ctx, func(entry entries.LogEntry, _ int) error {
+			results = append(results, entry)
+			return nil
+		})
+		return results, err
+	})
+}
+
+func parallel(iter Iterator, workers int, fn func(ctx context.Context, entry entries.LogEntry) ([]entries.LogEntry, error)) Iterator {
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	return Generate(context.Background(), func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		ctx, cancel := context.WithCancel(ctx)
+		var (
+			jobs = make(chan *parallelJob)
+			// pending holds jobs in the order they were read, which limits how far ahead of the oldest job the workers may get.
+			pending  = make(chan *parallelJob, workers)
+			readDone = make(chan struct{})
+			readErr  error
+			wg       sync.WaitGroup
+		)
+		// Workers and the reader must stop before the input Iterator is closed by Generate.
+		defer func() {
+			cancel()
+			<-readDone
+			wg.Wait()
+		}()
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for job := range jobs {
+					job.results, job.err = fn(ctx, job.entry)
+					close(job.done)
+				}
+			}()
+		}
+		go func() {
+			defer close(readDone)
+			defer close(pending)
+			defer close(jobs)
+			for {
+				entry, _, err := iter.Next(ctx)
+				if err != nil {
+					if !IsEnd(err) && ctx.Err() == nil {
+						readErr = err
+					}
+					return
+				}
+				job := &parallelJob{entry: entry, done: make(chan struct{})}
+				select {
+				case pending <- job:
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case jobs <- job:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		for job := range pending {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				return nil
+			}
+			if job.err != nil {
+				return job.err
+			}
+			for _, entry := range job.results {
+				if !emit(entry) {
+					return nil
+				}
+			}
+		}
+		return readErr
+	}, Mutable(iter))
+}
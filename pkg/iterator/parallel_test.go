@@ -0,0 +1,101 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMap_Order(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 0; i < 200; i++ {
+		input = append(input, entries.LogEntry{"seq": i})
+	}
+	var (
+		active    int32
+		maxActive int32
+	)
+	iter := ParallelMap(FromSlice(input), 4, func(entry entries.LogEntry) (entries.LogEntry, error) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for {
+			cur := atomic.LoadInt32(&maxActive)
+			if n <= cur || atomic.CompareAndSwapInt32(&maxActive, cur, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		if entry["seq"].(int)%10 == 0 {
+			return nil, entries.ErrSkipEntry
+		}
+		entry["double"] = entry["seq"].(int) * 2
+		return entry, nil
+	})
+
+	var seqs []int
+	err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		seqs = append(seqs, entry["seq"].(int))
+		assert.Equal(t, entry["seq"].(int)*2, entry["double"])
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, seqs, 180)
+	for i := 1; i < len(seqs); i++ {
+		assert.Less(t, seqs[i-1], seqs[i], "Entries should stay in the order they were read")
+	}
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxActive), int32(4))
+	assert.Greater(t, atomic.LoadInt32(&maxActive), int32(1), "Entries should be processed in parallel")
+}
+
+func TestParallel_Stage(t *testing.T) {
+	iter := Parallel(FromSlice([]entries.LogEntry{
+		{"@message": "a b", "list": []any{1, 2}},
+		{"@message": "c d"},
+	}), 2, func(iter Iterator) Iterator {
+		return Exploder(Cutter(iter, entries.CutDelim(" ")), "list")
+	})
+	var result []entries.LogEntry
+	err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		result = append(result, entry)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []entries.LogEntry{
+		{"@message": "", "0": "a", "1": "b", "list": 1},
+		{"@message": "", "0": "a", "1": "b", "list": 2},
+		{"@message": "", "0": "c", "1": "d"},
+	}, result)
+}
+
+func TestParallelMap_Error(t *testing.T) {
+	errTest := errors.New("test error")
+	iter := ParallelMap(FromSlice(_testEntries()), 2, func(entry entries.LogEntry) (entries.LogEntry, error) {
+		if msg, _ := entry.AsString("message"); msg == "B" {
+			return nil, errTest
+		}
+		return entry, nil
+	})
+	var msgs []string
+	err := iter.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+		msg, _ := entry.AsString("message")
+		msgs = append(msgs, msg)
+		return nil
+	})
+	assert.ErrorIs(t, err, errTest)
+	assert.Equal(t, []string{"A"}, msgs, "Entries before the error should be returned")
+}
+
+func TestParallelMap_Close(t *testing.T) {
+	base, baseClosed := _closeTracker(FromChannel(make(chan entries.LogEntry)))
+	iter := ParallelMap(base, 2, func(entry entries.LogEntry) (entries.LogEntry, error) {
+		return entry, nil
+	})
+	assert.NoError(t, iter.Close())
+	assert.True(t, baseClosed())
+}
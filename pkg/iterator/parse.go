@@ -0,0 +1,28 @@
+package iterator
+
+import (
+	"context"
+	"errors"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// Parser runs entries.Parse on each entry that passes through the Iterator.
+// Entries that the entries.Parser indicates should be skipped will not be returned.
+func Parser(iter Iterator, parser entries.Parser, opt ...entries.ParseOpt) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		for {
+			entry, i, err := iter.Next(ctx)
+			if err != nil {
+				return Err(err)
+			}
+			entry, err = entries.Parse(entry, parser, opt...)
+			if err != nil {
+				if errors.Is(err, entries.ErrSkipEntry) {
+					continue
+				}
+				return Err(err)
+			}
+			return entry, i, nil
+		}
+	})
+}
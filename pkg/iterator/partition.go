@@ -0,0 +1,187 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// partitionBuffer is the number of entries that may be queued for each output of a partitioned Fanout.
+// This lets a slow output fall behind a little without holding up the others, and is what FanoutLeastLoaded measures.
+const partitionBuffer = 16
+
+// partitionFunc picks the output for an entry, given whether each output is open and how many entries are queued for it.
+// Returning -1 drops the entry.
+type partitionFunc func(entry entries.LogEntry, open func(i int) bool, load func(i int) int) int
+
+type fanoutOpts struct {
+	strategy func(n int) partitionFunc
+}
+
+// FanoutOpt represents a functional option for Fanout.
+type FanoutOpt func(opts *fanoutOpts)
+
+// FanoutHash sends entries with the same value in the given field to the same output, using a consistent hash of the value.
+// Entries missing the field are all sent to the same output.
+// If the output for a value has been closed, then entries with that value are dropped rather than sent elsewhere, so an output never sees part of another output's values.
+func FanoutHash(field string) FanoutOpt {
+	return func(opts *fanoutOpts) {
+		opts.strategy = func(n int) partitionFunc {
+			return func(entry entries.LogEntry, open func(i int) bool, _ func(i int) int) int {
+				key, _ := entry.AsString(field)
+				h := fnv.New64a()
+				_, _ = h.Write([]byte(key))
+				i := jumpHash(h.Sum64(), n)
+				if !open(i) {
+					return -1
+				}
+				return i
+			}
+		}
+	}
+}
+
+// FanoutRoundRobin sends entries to each open output in turn.
+func FanoutRoundRobin() FanoutOpt {
+	return func(opts *fanoutOpts) {
+		opts.strategy = func(n int) partitionFunc {
+			var next int
+			return func(_ entries.LogEntry, open func(i int) bool, _ func(i int) int) int {
+				for tries := 0; tries < n; tries++ {
+					i := next
+					next = (next + 1) % n
+					if open(i) {
+						return i
+					}
+				}
+				return -1
+			}
+		}
+	}
+}
+
+// FanoutLeastLoaded sends each entry to the open output with the fewest entries waiting to be read.
+// Ties are broken in turn, so outputs that keep up share entries evenly.
+func FanoutLeastLoaded() FanoutOpt {
+	return func(opts *fanoutOpts) {
+		opts.strategy = func(n int) partitionFunc {
+			var start int
+			return func(_ entries.LogEntry, open func(i int) bool, load func(i int) int) int {
+				least := -1
+				for offset := 0; offset < n; offset++ {
+					i := (start + offset) % n
+					if open(i) && (least < 0 || load(i) < load(least)) {
+						least = i
+					}
+				}
+				start = (start + 1) % n
+				return least
+			}
+		}
+	}
+}
+
+// jumpHash is the jump consistent hash from Lamping and Veach, which maps a key to one of n buckets.
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// partition sends each entry from the input Iterator to the output picked by the strategy in opts.
+func partition(iter Iterator, n int, opts *fanoutOpts) []Iterator {
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		pick      = opts.strategy(n)
+		chans     = make([]chan entries.LogEntry, n)
+		closed    = make([]chan struct{}, n)
+		done      = make(chan struct{})
+		remaining = int32(n)
+		srcErr    error
+	)
+	for i := range chans {
+		chans[i] = make(chan entries.LogEntry, partitionBuffer)
+		closed[i] = make(chan struct{})
+	}
+	open := func(i int) bool {
+		select {
+		case <-closed[i]:
+			return false
+		default:
+			return true
+		}
+	}
+	load := func(i int) int {
+		return len(chans[i])
+	}
+	go func() {
+		defer close(done)
+		defer func() {
+			for _, ch := range chans {
+				close(ch)
+			}
+		}()
+		for {
+			entry, _, err := iter.Next(ctx)
+			if err != nil {
+				if !IsEnd(err) && ctx.Err() == nil {
+					srcErr = err
+				}
+				return
+			}
+		send:
+			for {
+				i := pick(entry, open, load)
+				if i < 0 {
+					break
+				}
+				select {
+				case chans[i] <- entry:
+					break send
+				case <-closed[i]:
+					// The output was closed while waiting, so pick again from those that are still open.
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	closeSource := func() error {
+		cancel()
+		<-done
+		return iter.Close()
+	}
+	branches := make([]Iterator, n)
+	for i := range branches {
+		i := i
+		var next int
+		branches[i] = withUpstream(New(func(ctx context.Context) (entries.LogEntry, int, error) {
+			select {
+			case entry, ok := <-chans[i]:
+				if !ok {
+					if srcErr != nil {
+						return Err(srcErr)
+					}
+					return End()
+				}
+				cur := next
+				next++
+				return entry, cur, nil
+			case <-ctx.Done():
+				return Err(ctx.Err())
+			}
+		}, func() error {
+			close(closed[i])
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				return closeSource()
+			}
+			return nil
+		}), iter)
+	}
+	return branches
+}
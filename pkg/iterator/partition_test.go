@@ -0,0 +1,138 @@
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFanoutN_Hash(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 0; i < 100; i++ {
+		input = append(input, entries.LogEntry{"service": fmt.Sprintf("svc-%d", i%7), "seq": i})
+	}
+	outputs := _partitionResult(t, FanoutN(FromSlice(input), 3, FanoutHash("service")))
+
+	owner := map[string]int{}
+	total := 0
+	for i, out := range outputs {
+		for _, entry := range out {
+			svc, _ := entry.AsString("service")
+			if prev, ok := owner[svc]; ok {
+				assert.Equal(t, prev, i, "Entries for %s should all go to the same output", svc)
+			}
+			owner[svc] = i
+			total++
+		}
+	}
+	assert.Equal(t, 100, total)
+	assert.Len(t, owner, 7)
+}
+
+func TestJumpHash(t *testing.T) {
+	moved := 0
+	for key := uint64(0); key < 1000; key++ {
+		a, b := jumpHash(key, 4), jumpHash(key, 5)
+		assert.True(t, a >= 0 && a < 4)
+		assert.True(t, b >= 0 && b < 5)
+		if a != b {
+			assert.Equal(t, 4, b, "Keys should only move to the new bucket")
+			moved++
+		}
+	}
+	assert.Less(t, moved, 400, "Only about a fifth of keys should move when adding a bucket")
+}
+
+func TestFanoutN_RoundRobin(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 0; i < 9; i++ {
+		input = append(input, entries.LogEntry{"seq": i})
+	}
+	outputs := _partitionResult(t, FanoutN(FromSlice(input), 3, FanoutRoundRobin()))
+	for i, out := range outputs {
+		var seqs []any
+		for _, entry := range out {
+			seqs = append(seqs, entry["seq"])
+		}
+		assert.Equal(t, []any{i, i + 3, i + 6}, seqs)
+	}
+}
+
+func TestFanoutN_LeastLoaded(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 0; i < 2*partitionBuffer; i++ {
+		input = append(input, entries.LogEntry{"seq": i})
+	}
+	ctx := context.Background()
+	slow, fast := Fanout(FromSlice(input), FanoutLeastLoaded())
+
+	// Only the fast output is read until the input ends, so everything that doesn't fit in the slow output's queue goes to the fast one.
+	count := 0
+	for count < len(input)-partitionBuffer {
+		_, _, err := fast.Next(ctx)
+		require.NoError(t, err)
+		count++
+	}
+	cctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	for {
+		_, _, err := fast.Next(cctx)
+		if err != nil {
+			assert.True(t, IsEnd(err))
+			break
+		}
+		count++
+	}
+	assert.NoError(t, fast.Close())
+	slowCount := 0
+	assert.NoError(t, slow.Iterate(ctx, func(entries.LogEntry, int) error {
+		slowCount++
+		return nil
+	}))
+	assert.Equal(t, len(input), count+slowCount)
+	assert.LessOrEqual(t, slowCount, partitionBuffer)
+}
+
+func TestFanoutN_PartitionClose(t *testing.T) {
+	var input []entries.LogEntry
+	for i := 0; i < 10; i++ {
+		input = append(input, entries.LogEntry{"seq": i})
+	}
+	base, baseClosed := _closeTracker(FromSlice(input))
+	a, b := Fanout(base, FanoutRoundRobin())
+	assert.NoError(t, a.Close())
+
+	count := 0
+	assert.NoError(t, b.Iterate(context.Background(), func(entries.LogEntry, int) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 10, count, "Round robin should skip closed outputs")
+	assert.True(t, baseClosed(), "Source should be closed after all outputs are closed")
+}
+
+func _partitionResult(t *testing.T, outputs []Iterator) [][]entries.LogEntry {
+	var (
+		wg     sync.WaitGroup
+		result = make([][]entries.LogEntry, len(outputs))
+	)
+	for i, out := range outputs {
+		i, out := i, out
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := out.Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+				result[i] = append(result[i], entry)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	return result
+}
@@ -0,0 +1,30 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// Dropper runs entries.Drop on each entry that passes through the Iterator.
+func Dropper(iter Iterator, fields ...string) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.Drop(entry, fields...)
+		return entry, i, nil
+	})
+}
+
+// Keeper runs entries.Keep on each entry that passes through the Iterator.
+func Keeper(iter Iterator, fields ...string) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.Keep(entry, fields...)
+		return entry, i, nil
+	})
+}
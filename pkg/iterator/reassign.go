@@ -1,11 +1,14 @@
 package iterator
 
-import "github.com/saylorsolutions/nomlog/pkg/entries"
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
 
 // Reassigner runs entries.Reassign on each entry that passes through the Iterator.
 func Reassigner(iter Iterator, spec entries.ReassignSpec) Iterator {
-	return Func(func() (entries.LogEntry, int, error) {
-		entry, i, err := iter.Next()
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
 		if err != nil {
 			return Err(err)
 		}
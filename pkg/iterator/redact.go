@@ -0,0 +1,18 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// Redactor runs entries.Redactor.Redact on each entry that passes through the Iterator.
+func Redactor(iter Iterator, redactor *entries.Redactor) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = redactor.Redact(entry)
+		return entry, i, nil
+	})
+}
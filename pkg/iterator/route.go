@@ -0,0 +1,96 @@
+package iterator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+var (
+	ErrEmptyRouteName     = errors.New("route name must not be empty")
+	ErrDuplicateRouteName = errors.New("duplicate route name")
+)
+
+// Route is a named output of Router, which receives entries that Match.
+type Route struct {
+	Name  string
+	Match func(entry entries.LogEntry) bool
+}
+
+type routerOpts struct {
+	allMatches  bool
+	defaultName string
+}
+
+// RouterOpt represents a functional option for Router.
+type RouterOpt func(opts *routerOpts)
+
+// RouteAllMatches sends each entry to every Route that matches it, rather than only the first.
+// Each Route gets its own copy of an entry that matches more than one, as described in Dupe.
+func RouteAllMatches() RouterOpt {
+	return func(opts *routerOpts) {
+		opts.allMatches = true
+	}
+}
+
+// RouteDefault adds an output with the given name for entries that don't match any Route.
+// Without a default output, entries that don't match are dropped.
+func RouteDefault(name string) RouterOpt {
+	return func(opts *routerOpts) {
+		opts.defaultName = name
+	}
+}
+
+// Router takes control of the input Iterator, and sends each entry to the outputs of the routes it matches.
+// Routes are checked in order, and by default an entry is only sent to the first matching Route.
+// The returned map contains an output for each Route by name, and for the default output if RouteDefault was given.
+//
+// Like Dupe, each output must be read or closed, otherwise the other outputs will stop receiving entries when an entry is routed to it.
+// An entry sent to more than one output is shared or copied as described in Dupe.
+// The input Iterator is closed once all outputs have been closed.
+func Router(iter Iterator, routes []Route, opt ...RouterOpt) (map[string]Iterator, error) {
+	opts := new(routerOpts)
+	for _, o := range opt {
+		o(opts)
+	}
+	names := make([]string, 0, len(routes)+1)
+	for _, r := range routes {
+		names = append(names, r.Name)
+	}
+	defaultIdx := -1
+	if len(opts.defaultName) > 0 {
+		defaultIdx = len(names)
+		names = append(names, opts.defaultName)
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if len(name) == 0 {
+			return nil, ErrEmptyRouteName
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateRouteName, name)
+		}
+		seen[name] = true
+	}
+
+	outputs := split(iter, len(names), func(entry entries.LogEntry, dst []int) []int {
+		for i, r := range routes {
+			if !r.Match(entry) {
+				continue
+			}
+			dst = append(dst, i)
+			if !opts.allMatches {
+				break
+			}
+		}
+		if len(dst) == 0 && defaultIdx >= 0 {
+			dst = append(dst, defaultIdx)
+		}
+		return dst
+	})
+	routed := make(map[string]Iterator, len(names))
+	for i, name := range names {
+		routed[name] = outputs[i]
+	}
+	return routed, nil
+}
@@ -0,0 +1,115 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	tests := map[string]struct {
+		opts     []RouterOpt
+		expected map[string][]string
+	}{
+		"First match": {
+			opts: []RouterOpt{RouteDefault("other")},
+			expected: map[string][]string{
+				"errors": {"disk full", "denied"},
+				"access": {"GET /"},
+				"other":  {"started"},
+			},
+		},
+		"All matches": {
+			opts: []RouterOpt{RouteAllMatches(), RouteDefault("other")},
+			expected: map[string][]string{
+				"errors": {"disk full", "denied"},
+				"access": {"GET /", "denied"},
+				"other":  {"started"},
+			},
+		},
+		"No default": {
+			expected: map[string][]string{
+				"errors": {"disk full", "denied"},
+				"access": {"GET /"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			iter := FromSlice([]entries.LogEntry{
+				{"@message": "started", "@level": "info"},
+				{"@message": "disk full", "@level": "error"},
+				{"@message": "GET /", "@tag": "access"},
+				{"@message": "denied", "@level": "error", "@tag": "access"},
+			})
+			outputs, err := Router(iter, []Route{
+				{Name: "errors", Match: func(entry entries.LogEntry) bool {
+					lvl, _ := entry.AsString("@level")
+					return lvl == "error"
+				}},
+				{Name: "access", Match: func(entry entries.LogEntry) bool {
+					return entry.HasTag("access")
+				}},
+			}, tc.opts...)
+			require.NoError(t, err)
+			require.Len(t, outputs, len(tc.expected))
+
+			var (
+				wg     sync.WaitGroup
+				mux    sync.Mutex
+				result = map[string][]string{}
+			)
+			for name, out := range outputs {
+				name, out := name, out
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					// Tagging changes entries in place, so the output is marked to get its own copies.
+					err := Mutable(out).Iterate(context.Background(), func(entry entries.LogEntry, _ int) error {
+						msg, _ := entry.AsString("@message")
+						entry.Tag(name)
+						mux.Lock()
+						result[name] = append(result[name], msg)
+						mux.Unlock()
+						return nil
+					})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestRouter_Close(t *testing.T) {
+	base, baseClosed := _closeTracker(FromSlice(_testEntries()))
+	outputs, err := Router(base, []Route{
+		{Name: "all", Match: func(entries.LogEntry) bool { return true }},
+	}, RouteDefault("other"))
+	require.NoError(t, err)
+
+	count := 0
+	err = outputs["all"].Iterate(context.Background(), func(entries.LogEntry, int) error {
+		count++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.False(t, baseClosed(), "Source should be open while the default output is open")
+	assert.NoError(t, outputs["other"].Close())
+	assert.True(t, baseClosed(), "Source should be closed after all outputs are closed")
+}
+
+func TestRouter_Names(t *testing.T) {
+	match := func(entries.LogEntry) bool { return true }
+	_, err := Router(Empty(), []Route{{Name: "a", Match: match}}, RouteDefault("a"))
+	assert.ErrorIs(t, err, ErrDuplicateRouteName)
+	_, err = Router(Empty(), []Route{{Match: match}})
+	assert.ErrorIs(t, err, ErrEmptyRouteName)
+}
@@ -1,6 +1,7 @@
 package iterator
 
 import (
+	"context"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 )
 
@@ -8,8 +9,8 @@ import (
 // If the standard tag field already exists, then the parameter will be appended with a period separator.
 // A Tag is intended to classify the log information in some way to make it easier to filter for later.
 func Tag(iter Iterator, tag string) Iterator {
-	return Func(func() (entries.LogEntry, int, error) {
-		entry, i, err := iter.Next()
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
 		if err != nil {
 			return Err(err)
 		}
@@ -0,0 +1,273 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TemplateIDField     = "@template_id"     // TemplateIDField identifies the template that a LogEntry's message was clustered into
+	TemplateField       = "@template"        // TemplateField is the template that a LogEntry's message was clustered into, with variable tokens replaced by TemplateWildcard
+	TemplateParamsField = "@template_params" // TemplateParamsField contains the tokens of a LogEntry's message that matched wildcards in its template
+
+	TemplateWildcard = "<*>" // TemplateWildcard replaces tokens that vary between messages in a template
+)
+
+type templateOpts struct {
+	field       string
+	depth       int
+	similarity  float64
+	maxChildren int
+	summary     func(templates []Template)
+}
+
+// TemplateOpt represents a functional option for NewTemplateMiner.
+type TemplateOpt func(opts *templateOpts)
+
+// TemplateFrom specifies the field to mine templates from. Defaults to entries.StandardMessageField.
+func TemplateFrom(field string) TemplateOpt {
+	return func(opts *templateOpts) {
+		opts.field = field
+	}
+}
+
+// TemplateDepth specifies the depth of the parse tree, including the root and message length layers. Defaults to 4.
+// A deeper tree uses more leading tokens to separate messages before they're compared for similarity.
+func TemplateDepth(depth int) TemplateOpt {
+	return func(opts *templateOpts) {
+		opts.depth = depth
+	}
+}
+
+// TemplateSimilarity specifies the fraction of tokens - from 0 to 1 - that must match a template for a message to be clustered into it. Defaults to 0.4.
+func TemplateSimilarity(similarity float64) TemplateOpt {
+	return func(opts *templateOpts) {
+		opts.similarity = similarity
+	}
+}
+
+// TemplateMaxChildren limits the number of children of each node in the parse tree, after which tokens are grouped under a wildcard. Defaults to 100.
+func TemplateMaxChildren(maxChildren int) TemplateOpt {
+	return func(opts *templateOpts) {
+		opts.maxChildren = maxChildren
+	}
+}
+
+// TemplateSummary specifies a function that will be called with all templates, as returned by TemplateMiner.Templates, when a stream passed to Templates ends.
+func TemplateSummary(summary func(templates []Template)) TemplateOpt {
+	return func(opts *templateOpts) {
+		opts.summary = summary
+	}
+}
+
+// Template is a summary of a cluster of similar messages.
+type Template struct {
+	ID       int64  `json:"id"`
+	Template string `json:"template"`
+	Count    int64  `json:"count"`
+}
+
+type templateCluster struct {
+	id     int64
+	tokens []string
+	count  int64
+}
+
+type templateNode struct {
+	children map[string]*templateNode
+	clusters []*templateCluster
+}
+
+func newTemplateNode() *templateNode {
+	return &templateNode{children: map[string]*templateNode{}}
+}
+
+// TemplateMiner clusters log messages into templates as they're seen, using the Drain algorithm.
+// Messages are split into whitespace separated tokens, and routed through a fixed depth parse tree by token count and leading tokens.
+// Tokens containing digits are assumed to be variable while routing.
+// Each message is then clustered with the most similar template at that leaf, and tokens that differ are replaced with TemplateWildcard.
+// A TemplateMiner is safe for concurrent use.
+type TemplateMiner struct {
+	opts     *templateOpts
+	mux      sync.Mutex
+	root     *templateNode
+	clusters []*templateCluster
+}
+
+// NewTemplateMiner creates a TemplateMiner.
+func NewTemplateMiner(opt ...TemplateOpt) *TemplateMiner {
+	opts := &templateOpts{
+		field:       entries.StandardMessageField,
+		depth:       4,
+		similarity:  0.4,
+		maxChildren: 100,
+	}
+	for _, o := range opt {
+		o(opts)
+	}
+	if opts.depth < 3 {
+		opts.depth = 3
+	}
+	if opts.maxChildren < 2 {
+		opts.maxChildren = 2
+	}
+	return &TemplateMiner{
+		opts: opts,
+		root: newTemplateNode(),
+	}
+}
+
+// Mine clusters a message, returning its template ID, the template, and the tokens of the message that matched wildcards in the template.
+// Note that a template may become more general as more messages are mined, so messages earlier in a stream may have been given a more specific version of the same template.
+func (m *TemplateMiner) Mine(msg string) (id int64, template string, params []string) {
+	tokens := strings.Fields(msg)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	leaf := m.leaf(tokens)
+	cluster := m.bestMatch(leaf, tokens)
+	if cluster == nil {
+		cluster = &templateCluster{
+			id:     int64(len(m.clusters) + 1),
+			tokens: append([]string{}, tokens...),
+		}
+		leaf.clusters = append(leaf.clusters, cluster)
+		m.clusters = append(m.clusters, cluster)
+	} else {
+		for i, tok := range tokens {
+			if cluster.tokens[i] != tok {
+				cluster.tokens[i] = TemplateWildcard
+			}
+		}
+	}
+	cluster.count++
+
+	for i, tok := range cluster.tokens {
+		if tok == TemplateWildcard {
+			params = append(params, tokens[i])
+		}
+	}
+	return cluster.id, strings.Join(cluster.tokens, " "), params
+}
+
+// leaf finds or creates the leaf node of the parse tree for the tokens.
+func (m *TemplateMiner) leaf(tokens []string) *templateNode {
+	node := m.child(m.root, strconv.Itoa(len(tokens)), false)
+	// The root and length layers are part of the depth.
+	for i := 0; i < m.opts.depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if hasDigit(key) {
+			key = TemplateWildcard
+		}
+		node = m.child(node, key, true)
+	}
+	return node
+}
+
+func (m *TemplateMiner) child(node *templateNode, key string, limited bool) *templateNode {
+	if c, ok := node.children[key]; ok {
+		return c
+	}
+	if limited && len(node.children) >= m.opts.maxChildren-1 && key != TemplateWildcard {
+		key = TemplateWildcard
+		if c, ok := node.children[key]; ok {
+			return c
+		}
+	}
+	c := newTemplateNode()
+	node.children[key] = c
+	return c
+}
+
+// bestMatch finds the cluster in the leaf that is most similar to the tokens, if any are similar enough.
+// Ties are broken by the number of wildcards in the template, preferring more specific templates.
+func (m *TemplateMiner) bestMatch(leaf *templateNode, tokens []string) *templateCluster {
+	var (
+		best          *templateCluster
+		bestSim       = -1.0
+		bestWildcards int
+	)
+	for _, c := range leaf.clusters {
+		var same, wildcards int
+		for i, tok := range c.tokens {
+			switch {
+			case tok == TemplateWildcard:
+				wildcards++
+			case tok == tokens[i]:
+				same++
+			}
+		}
+		sim := 1.0
+		if len(tokens) > 0 {
+			sim = float64(same) / float64(len(tokens))
+		}
+		if sim > bestSim || (sim == bestSim && wildcards < bestWildcards) {
+			best, bestSim, bestWildcards = c, sim, wildcards
+		}
+	}
+	if best == nil || bestSim < m.opts.similarity {
+		return nil
+	}
+	return best
+}
+
+// Templates returns a summary of all templates mined so far, ordered by descending count.
+func (m *TemplateMiner) Templates() []Template {
+	m.mux.Lock()
+	templates := make([]Template, len(m.clusters))
+	for i, c := range m.clusters {
+		templates[i] = Template{
+			ID:       c.id,
+			Template: strings.Join(c.tokens, " "),
+			Count:    c.count,
+		}
+	}
+	m.mux.Unlock()
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Count > templates[j].Count
+	})
+	return templates
+}
+
+func hasDigit(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// Templates annotates each entry that passes through the Iterator with the template mined from its message, setting TemplateIDField, TemplateField, and TemplateParamsField.
+// Entries without the message field are passed through unchanged.
+// If TemplateSummary was given to the TemplateMiner, then it will be called once when the end of the stream is reached.
+func Templates(iter Iterator, miner *TemplateMiner) Iterator {
+	var summarized bool
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			if IsEnd(err) && !summarized && miner.opts.summary != nil {
+				summarized = true
+				miner.opts.summary(miner.Templates())
+			}
+			return Err(err)
+		}
+		msg, ok := entry.AsString(miner.opts.field)
+		if !ok {
+			return entry, i, nil
+		}
+		id, template, params := miner.Mine(msg)
+		entry[TemplateIDField] = id
+		entry[TemplateField] = template
+		vals := make([]any, len(params))
+		for idx, p := range params {
+			vals[idx] = p
+		}
+		entry[TemplateParamsField] = vals
+		return entry, i, nil
+	})
+}
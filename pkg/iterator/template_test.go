@@ -0,0 +1,77 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTemplateMiner_Mine(t *testing.T) {
+	m := NewTemplateMiner()
+	id, template, params := m.Mine("Connected to 10.0.0.1 in 5ms")
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "Connected to 10.0.0.1 in 5ms", template)
+	assert.Empty(t, params)
+
+	id, template, params = m.Mine("Connected to 10.0.0.2 in 12ms")
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "Connected to <*> in <*>", template)
+	assert.Equal(t, []string{"10.0.0.2", "12ms"}, params)
+
+	id, template, _ = m.Mine("Session closed for bob")
+	assert.Equal(t, int64(2), id)
+	assert.Equal(t, "Session closed for bob", template)
+	id, template, params = m.Mine("Session closed for alice")
+	assert.Equal(t, int64(2), id)
+	assert.Equal(t, "Session closed for <*>", template)
+	assert.Equal(t, []string{"alice"}, params)
+
+	id, _, _ = m.Mine("Disk quota exceeded for volume")
+	assert.Equal(t, int64(3), id, "Messages that aren't similar should have their own template")
+
+	id, template, params = m.Mine("Connected to db-1 in 7ms")
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "Connected to <*> in <*>", template)
+	assert.Equal(t, []string{"db-1", "7ms"}, params)
+
+	assert.Equal(t, []Template{
+		{ID: 1, Template: "Connected to <*> in <*>", Count: 3},
+		{ID: 2, Template: "Session closed for <*>", Count: 2},
+		{ID: 3, Template: "Disk quota exceeded for volume", Count: 1},
+	}, m.Templates())
+}
+
+func TestTemplates(t *testing.T) {
+	ctx := context.Background()
+	var summary []Template
+	iter := FromSlice([]entries.LogEntry{
+		entries.FromString("request 1 took 5ms"),
+		{"msg": "no message field"},
+		entries.FromString("request 2 took 9ms"),
+	})
+	iter = Templates(iter, NewTemplateMiner(TemplateSummary(func(templates []Template) {
+		summary = templates
+	})))
+
+	first, _, err := iter.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), first[TemplateIDField])
+	assert.Equal(t, "request 1 took 5ms", first[TemplateField])
+
+	second, _, err := iter.Next(ctx)
+	require.NoError(t, err)
+	assert.False(t, second.HasField(TemplateIDField))
+
+	third, _, err := iter.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), third[TemplateIDField])
+	assert.Equal(t, "request <*> took <*>", third[TemplateField])
+	assert.Equal(t, []any{"2", "9ms"}, third[TemplateParamsField])
+	assert.Nil(t, summary, "Summary should not be given until the stream ends")
+
+	_, _, err = iter.Next(ctx)
+	assert.ErrorIs(t, err, ErrAtEnd)
+	assert.Equal(t, []Template{{ID: 1, Template: "request <*> took <*>", Count: 2}}, summary)
+}
@@ -0,0 +1,18 @@
+package iterator
+
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
+
+// TimestampNormalizer runs entries.NormalizeTimestamp on each entry that passes through the Iterator.
+func TimestampNormalizer(iter Iterator, opt ...entries.TimestampOpt) Iterator {
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
+		if err != nil {
+			return Err(err)
+		}
+		entry = entries.NormalizeTimestamp(entry, opt...)
+		return entry, i, nil
+	})
+}
@@ -1,11 +1,14 @@
 package iterator
 
-import "github.com/saylorsolutions/nomlog/pkg/entries"
+import (
+	"context"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+)
 
 // Transformer adds entries.Transform logic to this Iterator.
 func Transformer(iter Iterator, spec entries.TransformSpec) Iterator {
-	return Func(func() (entries.LogEntry, int, error) {
-		entry, i, err := iter.Next()
+	return Wrap(Mutable(iter), func(ctx context.Context) (entries.LogEntry, int, error) {
+		entry, i, err := iter.Next(ctx)
 		if err != nil {
 			return Err(err)
 		}
@@ -11,11 +11,6 @@ import (
 	"time"
 )
 
-const (
-	readTimeField = "@read_timestamp"
-	readLineField = "@read_line_number"
-)
-
 // TailSource behaves the same as CtxTailSource, except that it will use context.Background as the context.
 // This means that the goroutine will be tailing the file for the entire life of the program.
 func TailSource(filename string) (iterator.Iterator, error) {
@@ -41,26 +36,27 @@ func ctxTailSource(ctx context.Context, filename string) (*tail.Tail, iterator.I
 		return nil, nil, err
 	}
 
-	ch := make(chan entries.LogEntry)
-	go func() {
-		defer close(ch)
+	return t, iterator.Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		defer func() {
+			_ = t.Stop()
+		}()
 		for {
 			select {
 			case <-ctx.Done():
-				_ = t.Stop()
-				return
+				return nil
 			case l, ok := <-t.Lines:
 				if !ok {
-					return
+					return nil
 				}
 				entry := entries.FromString(l.Text)
-				entry[readTimeField] = l.Time.UTC().Format(time.RFC3339)
-				entry[readLineField] = l.Num
-				ch <- entry
+				entry[entries.ReadTimestampField] = l.Time.UTC().Format(time.RFC3339)
+				entry[entries.ReadLineNumberField] = l.Num
+				if !emit(entry) {
+					return nil
+				}
 			}
 		}
-	}()
-	return t, iterator.FromChannel(ch), nil
+	}), nil
 }
 
 // Source operates the same as CtxSource, except that it will use the background context for cancellation.
@@ -70,59 +66,52 @@ func Source(filename string) (iterator.Iterator, error) {
 
 // CtxSource will create an iterator.Iterator from all lines in the specified file in a new goroutine.
 // If there is an error opening the file, then it will be reported from this method.
-// If the given context is cancelled while the goroutine is reading, then it will stop and close the file and iterator.
+// If the given context is cancelled or the iterator.Iterator is closed while the goroutine is reading, then it will stop and close the file.
 func CtxSource(ctx context.Context, filename string) (iterator.Iterator, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	ch := make(chan entries.LogEntry)
-	go func() {
+	return iterator.Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
 		defer func() {
-			close(ch)
 			_ = f.Close()
 		}()
 		scanner := bufio.NewScanner(f)
-
-		var (
-			hasClosed bool
-			num       int
-		)
-		go func() {
-			<-ctx.Done()
-			hasClosed = true
-		}()
-
+		var num int
 		for scanner.Scan() {
-			if hasClosed {
-				return
-			}
 			line := scanner.Text()
 			entry := entries.FromString(line)
-			entry[readTimeField] = time.Now().UTC().Format(time.RFC3339)
-			entry[readLineField] = num
+			entry[entries.ReadTimestampField] = time.Now().UTC().Format(time.RFC3339)
+			entry[entries.ReadLineNumberField] = num
 			num++
-			ch <- entry
+			if !emit(entry) {
+				return nil
+			}
 		}
-	}()
-	return iterator.FromChannel(ch), nil
+		return scanner.Err()
+	}), nil
 }
 
 // Sink will append each entry in the iterator.Iterator to the specified file, creating it if necessary.
 // If Sink is called asynchronously, it's recommended to wait until it returns to close down the application.
 // This can be done with CtxTailSource by cancelling the provided context and waiting on the goroutine calling Sink to exit.
-// In case of an error, Sink will drain the iterator.Iterator to prevent upstream blocking.
+// The iterator.Iterator is closed when Sink returns, which stops upstream producers in case of an error.
 func Sink(iter iterator.Iterator, filename string, perms os.FileMode) error {
+	return CtxSink(context.Background(), iter, filename, perms)
+}
+
+// CtxSink operates the same as Sink, except that it will stop writing entries when the given context is cancelled.
+func CtxSink(ctx context.Context, iter iterator.Iterator, filename string, perms os.FileMode) error {
 	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perms)
 	if err != nil {
-		iterator.Drain(iter)
+		_ = iter.Close()
 		return err
 	}
 	defer func() {
 		_ = f.Close()
 	}()
-	err = iter.Iterate(func(entry entries.LogEntry, _ int) error {
+	err = iter.Iterate(ctx, func(entry entries.LogEntry, _ int) error {
 		data, err := json.Marshal(entry)
 		if err != nil {
 			// Shouldn't ever happen, given the data type.
@@ -136,8 +125,7 @@ func Sink(iter iterator.Iterator, filename string, perms os.FileMode) error {
 		}
 		return nil
 	})
-	if err != nil {
-		iterator.Drain(iter)
+	if err != nil && !iterator.IsEnd(err) {
 		return err
 	}
 	return nil
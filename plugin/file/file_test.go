@@ -9,17 +9,19 @@ import (
 	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestSource_Structured(t *testing.T) {
+	ctx := context.Background()
 	_tail, iter, err := ctxTailSource(context.Background(), "structured.log")
 	require.NoError(t, err)
 	require.NotNil(t, _tail)
 	require.NotNil(t, iter)
 
 	count := 0
-	err = iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err = iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count++
 		assert.True(t, entry.HasField(entries.StandardMessageField), "Entry should have '@message' field")
 		assert.True(t, entry.HasField(entries.StandardTimestampField), "Entry should have '@timestamp' field")
@@ -48,13 +50,14 @@ func TestSource_Structured(t *testing.T) {
 }
 
 func TestSource_Unstructured(t *testing.T) {
+	ctx := context.Background()
 	_tail, iter, err := ctxTailSource(context.Background(), "unstructured.log")
 	require.NoError(t, err)
 	require.NotNil(t, _tail)
 	require.NotNil(t, iter)
 
 	count := 0
-	err = iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err = iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count++
 		assert.True(t, entry.HasField(entries.StandardMessageField), "Entry should have '@message' field")
 		assert.True(t, entry.HasField("@read_timestamp"), "Entry should have '@read_timestamp' field")
@@ -113,6 +116,7 @@ func TestSink(t *testing.T) {
 }
 
 func TestSource(t *testing.T) {
+	ctx := context.Background()
 	text := `abc
 def
 ghi
@@ -137,7 +141,7 @@ ghi
 	assert.NotNil(t, iter)
 
 	var result string
-	err = iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err = iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		msg, ok := entry.AsString(entries.StandardMessageField)
 		if !ok {
 			t.Errorf("Entry %d should have a '%s' field", i, entries.StandardMessageField)
@@ -149,6 +153,39 @@ ghi
 	assert.Equal(t, "abcdefghi", result)
 }
 
+func TestCtxSource_Cancelled(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "TestCtxSource_Cancelled-*")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	testFile := filepath.Join(tmp, "test.txt")
+	err = os.WriteFile(testFile, []byte(strings.Repeat("abc\n", 1000)), 0600)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	iter, err := CtxSource(ctx, testFile)
+	require.NoError(t, err)
+
+	_, _, err = iter.Next(context.Background())
+	assert.NoError(t, err)
+	cancel()
+
+	// Entries being sent when the context was cancelled may still be received, but the source must end before the file does.
+	var read int
+	for {
+		_, _, err = iter.Next(context.Background())
+		if err != nil {
+			break
+		}
+		read++
+	}
+	assert.ErrorIs(t, err, iterator.ErrAtEnd)
+	assert.Less(t, read, 999, "Source should stop reading when cancelled")
+	assert.NoError(t, iter.Close())
+}
+
 func TestSource_DoesNotExist(t *testing.T) {
 	iter, err := Source("blurbadurben.text.log.yaml.log")
 	assert.Error(t, err)
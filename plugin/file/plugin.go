@@ -46,7 +46,7 @@ Just like the file.File source, structured or unstructured data may be read.`)
 This source will read each line of the file specified by FILE_NAME, emitting a log entry for each one.
 If the line represents a valid JSON document, then it will be emitted as-is except with additional fields specifying read timing.
 Otherwise, the line is added as-is to a log entry with a field "@message" containing the original line.`)
-	reg.RegisterSink("file", "File", func(_ context.Context, src iterator.Iterator, args ...*dsl.Arg) error {
+	reg.RegisterSink("file", "File", func(ctx context.Context, src iterator.Iterator, args ...*dsl.Arg) error {
 		if len(args) < 1 {
 			return fmt.Errorf("%w: requires 1 or 2 arguments", plugin.ErrArgs)
 		}
@@ -56,9 +56,9 @@ Otherwise, the line is added as-is to a log entry with a field "@message" contai
 			if err != nil {
 				return fmt.Errorf("%w: invalid file permission argument", plugin.ErrArgs)
 			}
-			return Sink(src, args[0].String, os.FileMode(perms))
+			return CtxSink(ctx, src, args[0].String, os.FileMode(perms))
 		}
-		return Sink(src, args[0].String, 0600)
+		return CtxSink(ctx, src, args[0].String, 0600)
 	})
 	reg.DocumentSink("file", "File", `file.File FILE_NAME [FILE_MODE]
 
@@ -67,7 +67,7 @@ func (t *testPlugin) Register(reg *Registration) {
 
 Returns test data.`)
 	reg.RegisterSink("test", "Sink", func(ctx context.Context, src iterator.Iterator, args ...*dsl.Arg) error {
-		return src.Iterate(func(entry entries.LogEntry, i int) error {
+		return src.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 			t.t.Log("Entry", i)
 			t.t.Log(entry)
 			return nil
@@ -44,30 +44,38 @@ func (s *stdplugin) Stopping() error {
 	return nil
 }
 
+// SourceIn reads lines from STDIN until it's exhausted, the context is cancelled, or the iterator.Iterator is closed.
+// A read from STDIN can't be interrupted, so the reading goroutine will exit after the next line is read or STDIN is closed.
 func SourceIn(ctx context.Context, _ ...*dsl.Arg) (iterator.Iterator, error) {
-	ch := make(chan entries.LogEntry)
-	go func() {
-		defer func() {
-			close(ch)
-		}()
-		scanner := bufio.NewScanner(os.Stdin)
-
-		var hasClosed bool
+	return iterator.Generate(ctx, func(ctx context.Context, emit func(entry entries.LogEntry) bool) error {
+		lines := make(chan string)
+		scanErr := make(chan error, 1)
 		go func() {
-			<-ctx.Done()
-			hasClosed = true
+			defer close(lines)
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				select {
+				case lines <- scanner.Text():
+				case <-ctx.Done():
+					return
+				}
+			}
+			scanErr <- scanner.Err()
 		}()
-
-		for scanner.Scan() {
-			if hasClosed {
-				return
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case line, ok := <-lines:
+				if !ok {
+					return <-scanErr
+				}
+				if !emit(entries.FromString(line)) {
+					return nil
+				}
 			}
-			line := scanner.Text()
-			entry := entries.FromString(line)
-			ch <- entry
 		}
-	}()
-	return iterator.FromChannel(ch), nil
+	}), nil
 }
 
 func jsonify(entry entries.LogEntry) (string, error) {
@@ -79,15 +87,7 @@ func jsonify(entry entries.LogEntry) (string, error) {
 }
 
 func SinkOut(ctx context.Context, src iterator.Iterator, _ ...*dsl.Arg) error {
-	var hasCancelled bool
-	go func() {
-		<-ctx.Done()
-		hasCancelled = true
-	}()
-	err := src.Iterate(func(entry entries.LogEntry, i int) error {
-		if hasCancelled {
-			return iterator.ErrAtEnd
-		}
+	err := src.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		str, err := jsonify(entry)
 		if err != nil {
 			return err
@@ -98,22 +98,13 @@ func SinkOut(ctx context.Context, src iterator.Iterator, _ ...*dsl.Arg) error {
 		}
 		return nil
 	})
-	if err != nil {
-		iterator.Drain(src)
+	if err != nil && !iterator.IsEnd(err) {
 		return err
 	}
 	return nil
 }
 func SinkErr(ctx context.Context, src iterator.Iterator, _ ...*dsl.Arg) error {
-	var hasCancelled bool
-	go func() {
-		<-ctx.Done()
-		hasCancelled = true
-	}()
-	err := src.Iterate(func(entry entries.LogEntry, i int) error {
-		if hasCancelled {
-			return iterator.ErrAtEnd
-		}
+	err := src.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		str, err := jsonify(entry)
 		if err != nil {
 			return err
@@ -124,8 +115,7 @@ func SinkErr(ctx context.Context, src iterator.Iterator, _ ...*dsl.Arg) error {
 		}
 		return nil
 	})
-	if err != nil {
-		iterator.Drain(src)
+	if err != nil && !iterator.IsEnd(err) {
 		return err
 	}
 	return nil
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -29,15 +30,20 @@ func newQueryIterator(log hclog.Logger, rows *sql.Rows) (iterator.Iterator, erro
 	var rowNum int
 
 	if len(cols) == 0 {
-		return iterator.Func(func() (entries.LogEntry, int, error) {
-			return nil, -1, iterator.ErrAtEnd
-		}), nil
+		_ = rows.Close()
+		return iterator.Empty(), nil
 	}
 
-	return iterator.Func(func() (entries.LogEntry, int, error) {
+	// Closing the iterator releases the rows, whether or not they've all been read.
+	return iterator.New(func(ctx context.Context) (entries.LogEntry, int, error) {
+		if err := ctx.Err(); err != nil {
+			return iterator.Err(err)
+		}
 		if !rows.Next() {
-			_ = rows.Close()
-			return nil, -1, iterator.ErrAtEnd
+			if err := rows.Err(); err != nil {
+				return iterator.Err(err)
+			}
+			return iterator.End()
 		}
 		rowNum++
 		var rowID int
@@ -53,7 +59,6 @@ func newQueryIterator(log hclog.Logger, rows *sql.Rows) (iterator.Iterator, erro
 			}
 		}
 		if err := rows.Scan(vals...); err != nil {
-			_ = rows.Close()
 			return nil, -1, err
 		}
 
@@ -73,5 +78,5 @@ func newQueryIterator(log hclog.Logger, rows *sql.Rows) (iterator.Iterator, erro
 			}
 		}
 		return entry, rowNum, nil
-	}), nil
+	}, rows.Close), nil
 }
@@ -59,23 +59,25 @@ var (
 
 func (s *SqliteStore) CtxSink(ctx context.Context, iter iterator.Iterator, table string) error {
 	if !tablePattern.MatchString(table) {
+		_ = iter.Close()
 		return fmt.Errorf("%w: %s", ErrBadTable, table)
 	}
 	s.log.Debug("Establishing connection")
 	conn, err := s.db.Conn(ctx)
 	if err != nil {
+		_ = iter.Close()
 		return err
 	}
 	s.log.Debug("Ensuring the specified table is present")
 	if err := s.ensureTable(ctx, conn, table); err != nil {
-		iterator.Drain(iter)
+		_ = iter.Close()
 		_ = conn.Close()
 		return err
 	}
 	s.log.Debug("Getting table columns")
 	cols, err := s.getTableColumns(ctx, conn, table)
 	if err != nil {
-		iterator.Drain(iter)
+		_ = iter.Close()
 		_ = conn.Close()
 		return err
 	}
@@ -126,24 +128,14 @@ func (s *SqliteStore) getTableColumns(ctx context.Context, conn *sql.Conn, table
 
 func (s *SqliteStore) sink(ctx context.Context, conn *sql.Conn, table string, iter iterator.Iterator, colMap map[string]bool) {
 	log := s.log.With("table", table).Named("sink")
-	cancelled := false
 
 	defer func() {
 		_ = conn.Close()
 		log.Debug("DB connection closed")
 	}()
 
-	go func() {
-		<-ctx.Done()
-		log.Debug("Context cancelled")
-		cancelled = true
-	}()
-
-	err := iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err := iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		log.Debug("Received log entry", "entry", entry)
-		if cancelled {
-			return iterator.ErrAtEnd
-		}
 
 		var intoFields []string
 		for k := range entry {
@@ -196,11 +188,13 @@ func (s *SqliteStore) sink(ctx context.Context, conn *sql.Conn, table string, it
 		}
 		return nil
 	})
-	if err != nil {
-		log.Error("Error sinking to DB, draining iterator", "error", err)
-		iterator.Drain(iter)
+	if err != nil && !iterator.IsEnd(err) {
+		log.Error("Error sinking to DB", "error", err)
 		return
 	}
+	if ctx.Err() != nil {
+		log.Debug("Context cancelled")
+	}
 }
 
 func (s *SqliteStore) addColumn(ctx context.Context, conn *sql.Conn, table string, colName string) error {
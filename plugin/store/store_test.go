@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"github.com/hashicorp/go-hclog"
 	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"github.com/saylorsolutions/nomlog/pkg/iterator"
@@ -36,6 +37,7 @@ func TestSqliteStore_Sink(t *testing.T) {
 }
 
 func TestSqliteStore_QueryEntries(t *testing.T) {
+	ctx := context.Background()
 	iter := iterator.FromSlice([]entries.LogEntry{
 		{
 			"A":           "A",
@@ -61,7 +63,7 @@ func TestSqliteStore_QueryEntries(t *testing.T) {
 	count := 0
 	iter, err = store.QueryEntries("test")
 	assert.NoError(t, err)
-	err = iter.Iterate(func(entry entries.LogEntry, i int) error {
+	err = iter.Iterate(ctx, func(entry entries.LogEntry, i int) error {
 		count++
 		t.Log(entry)
 		return nil
@@ -3,9 +3,11 @@ package dsl
 import (
 	"errors"
 	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -14,6 +16,20 @@ var (
 	ErrAlreadyDefined      = errors.New("identifier is already defined")
 	ErrAlreadyConsumed     = errors.New("iterator is no longer consumable")
 	ErrInvalidJoinPattern  = errors.New("invalid join pattern")
+	ErrUnknownLevelMapping = errors.New("unknown level mapping")
+	ErrUnknownLevel        = errors.New("unknown level")
+	ErrEmptySeparator      = errors.New("separator must not be empty")
+	ErrUnknownDetector     = errors.New("unknown redaction detector")
+	ErrUnknownMaskMode     = errors.New("unknown redaction mode")
+	ErrInvalidClusterOpt   = errors.New("invalid cluster option")
+	ErrInvalidDuration     = errors.New("invalid duration")
+	ErrInvalidWorkers      = errors.New("invalid worker count")
+	ErrInvalidAggregate    = errors.New("invalid aggregate")
+	ErrInvalidDedupLimit   = errors.New("invalid dedup limit")
+	ErrInvalidJoinLimit    = errors.New("invalid join limit")
+	ErrInvalidGroupSize    = errors.New("invalid group size")
+	ErrInvalidPairPrefix   = errors.New("invalid pair prefix")
+	ErrDuplicateRename     = errors.New("duplicate rename")
 	errNotAMatch           = errors.New("not a match")
 )
 
@@ -46,6 +62,25 @@ const (
 	FANOUT
 	TAG
 	JOIN
+	FILTER
+	TRANSFORM
+	RENAME
+	DROP
+	KEEP
+	PARSE
+	NORMALIZE_TIMESTAMP
+	NORMALIZE_LEVEL
+	FLATTEN
+	UNFLATTEN
+	EXPLODE
+	REDACT
+	CLUSTER
+	ROUTE
+	PARALLEL
+	AGGREGATE
+	DEDUP
+	CORRELATE
+	PAIR
 )
 
 func ParseString(s string) ([]AstNode, error) {
@@ -152,12 +187,134 @@ func (p *parser) parse() ([]AstNode, error) {
 				return nil, err
 			}
 			nodes = append(nodes, join)
+		case tFilter:
+			filter, err := p.parseFilter(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, filter)
+		case tTransform:
+			transform, err := p.parseTransform(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, transform)
+		case tRename:
+			rename, err := p.parseRename(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, rename)
+		case tParse:
+			parse, err := p.parseParse(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, parse)
+		case tNormalize:
+			normalize, err := p.parseNormalize(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, normalize)
+		case tFlatten:
+			flatten, err := p.parseFlatten(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, flatten)
+		case tUnflatten:
+			unflatten, err := p.parseUnflatten(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, unflatten)
+		case tExplode:
+			explode, err := p.parseExplode(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, explode)
+		case tRedact:
+			redact, err := p.parseRedact(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, redact)
+		case tCluster:
+			cluster, err := p.parseCluster(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, cluster)
+		case tParallel:
+			parallel, err := p.parseParallel(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, parallel)
+		case tAggregate:
+			aggregate, err := p.parseAggregate(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, aggregate)
+		case tDedup:
+			dedup, err := p.parseDedup(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, dedup)
+		case tCorrelate:
+			correlate, err := p.parseCorrelate(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, correlate)
+		case tPair:
+			pair, err := p.parsePair(str)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, pair)
+		case tIdentifier:
+			node, err := p.parseWordStatement(str, t)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, node)
 		default:
-			return nil, unexpected(str.next(), "EOL", "EOF", "source", "sink", "merge", "dupe", "append", "cut", "fanout")
+			return nil, unexpected(str.next(), statementStarts...)
 		}
 	}
 }
 
+// Words that only act as keywords in their own statements, so they may still be used as source and field names elsewhere.
+const (
+	kwDrop   = "drop"
+	kwKeep   = "keep"
+	kwRoute  = "route"
+	kwFields = "fields"
+	kwFrom   = "from"
+	kwIn     = "in"
+)
+
+var statementStarts = []string{"EOL", "EOF", "source", "sink", "merge", "dupe", "append", "cut", "fanout", "tag", "join", "filter", "transform", "rename", "drop", "keep", "parse", "normalize", "flatten", "unflatten", "explode", "redact", "cluster", "route", "parallel", "aggregate", "dedup", "correlate", "pair"}
+
+// parseWordStatement parses a statement that starts with a contextual keyword.
+func (p *parser) parseWordStatement(str *tokenStream, t token) (AstNode, error) {
+	switch t.Text {
+	case kwDrop:
+		return p.parseDrop(str)
+	case kwKeep:
+		return p.parseKeep(str)
+	case kwRoute:
+		return p.parseRoute(str)
+	default:
+		return nil, unexpected(str.next(), statementStarts...)
+	}
+}
+
 func unexpected(t token, expected ...string) error {
 	expect := "one of " + strings.Join(expected, ", ")
 	return fmt.Errorf("%w: expected %s at line %d position %d", ErrUnexpectedToken, expect, t.Line, t.Pos)
@@ -496,6 +653,7 @@ func (p *parser) parseSink(str *tokenStream) (*Sink, error) {
 	asyncTo := str.next()
 	if asyncTo.Type == tAsync {
 		sink.AstType = ASYNC_SINK
+		sink.Async = true
 		sink.appendSpace(asyncTo)
 		as := str.next()
 		if as.Type != tAs {
@@ -554,11 +712,25 @@ func (p *parser) parseSink(str *tokenStream) (*Sink, error) {
 	return sink, nil
 }
 
+const (
+	mergeOrdered = "ordered"
+	mergeBy      = "by"
+	mergeWithin  = "within"
+	mergeSorted  = "sorted"
+)
+
 type Merge struct {
 	ast
-	SourceA string `json:"sourceA"`
-	SourceB string `json:"sourceB"`
-	ID      string `json:"id"`
+	Sources []string `json:"sources"`
+	ID      string   `json:"id"`
+	// Ordered merges sources by time instead of as entries are read.
+	Ordered bool `json:"ordered"`
+	// OrderField is the field holding each entry's time, if not the default.
+	OrderField string `json:"orderField"`
+	// Tolerance is how far out of order entries may arrive, when merging live sources.
+	Tolerance time.Duration `json:"tolerance"`
+	// Sorted reads all sources before sorting their entries, which is only suitable for finite sources.
+	Sorted bool `json:"sorted"`
 }
 
 func (p *parser) parseMerge(str *tokenStream) (*Merge, error) {
@@ -570,39 +742,11 @@ func (p *parser) parseMerge(str *tokenStream) (*Merge, error) {
 	}
 	merge.setVals(m, MERGE)
 
-	a := str.next()
-	if a.Type != tIdentifier {
-		return nil, unexpected(a, "source identifier")
-	}
-	if !p.sources[a.Text] {
-		return nil, semantic(a, errUndefined(a.Text))
-	}
-	if p.consumed[a.Text] {
-		return nil, semantic(a, errAlreadyConsumed(a.Text))
-	}
-	p.consumed[a.Text] = true
-	merge.SourceA = a.Text
-	merge.appendSpace(a)
-
-	and := str.next()
-	if and.Type != tAnd {
-		return nil, unexpected(and, "and")
-	}
-	merge.appendSpace(and)
-
-	b := str.next()
-	if b.Type != tIdentifier {
-		return nil, unexpected(b, "source identifier")
-	}
-	if !p.sources[b.Text] {
-		return nil, semantic(b, errUndefined(b.Text))
-	}
-	if p.consumed[b.Text] {
-		return nil, semantic(b, errAlreadyConsumed(b.Text))
+	sources, err := p.parseSourceList(str, &merge.ast)
+	if err != nil {
+		return nil, err
 	}
-	p.consumed[b.Text] = true
-	merge.SourceB = b.Text
-	merge.appendSpace(b)
+	merge.Sources = sources
 
 	as := str.next()
 	if as.Type != tAs {
@@ -621,18 +765,61 @@ func (p *parser) parseMerge(str *tokenStream) (*Merge, error) {
 	p.sources[id.Text] = true
 	merge.appendSpace(id)
 
-	_, err := p.parseRequiredEol(str)
+	if err := p.parseMergeOrder(str, merge); err != nil {
+		return nil, err
+	}
+
+	_, err = p.parseRequiredEol(str)
 	if err != nil {
 		return nil, err
 	}
 	return merge, nil
 }
 
+// parseMergeOrder parses the optional ordering clause of a merge, like: ordered by ts within "5s".
+func (p *parser) parseMergeOrder(str *tokenStream, merge *Merge) error {
+	ordered := str.next()
+	if ordered.Type != tIdentifier || ordered.Text != mergeOrdered {
+		str.pushBack(ordered)
+		return nil
+	}
+	merge.Ordered = true
+	merge.appendSpace(ordered)
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == mergeBy {
+		merge.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		merge.OrderField = field.Text
+		merge.appendSpace(field)
+		next = str.next()
+	}
+	switch {
+	case next.Type == tIdentifier && next.Text == mergeWithin:
+		merge.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return err
+		}
+		merge.Tolerance = dur
+		merge.appendSpace(tok)
+	case next.Type == tIdentifier && next.Text == mergeSorted:
+		merge.Sorted = true
+		merge.appendSpace(next)
+	default:
+		str.pushBack(next)
+	}
+	return nil
+}
+
 type Dupe struct {
 	ast
-	Source  string `json:"source"`
-	TargetA string `json:"targetA"`
-	TargetB string `json:"targetB"`
+	Source  string   `json:"source"`
+	Targets []string `json:"targets"`
 }
 
 func (p *parser) parseDupe(str *tokenStream) (*Dupe, error) {
@@ -664,35 +851,13 @@ func (p *parser) parseDupe(str *tokenStream) (*Dupe, error) {
 	}
 	dupe.appendSpace(as)
 
-	a := str.next()
-	if a.Type != tIdentifier {
-		return nil, unexpected(a, "target identifier")
-	}
-	if p.sources[a.Text] {
-		return nil, semantic(a, errAlreadyDefined(a.Text))
-	}
-	dupe.TargetA = a.Text
-	p.sources[a.Text] = true
-	dupe.appendSpace(a)
-
-	and := str.next()
-	if and.Type != tAnd {
-		return nil, unexpected(and, "and")
-	}
-	dupe.appendSpace(and)
-
-	b := str.next()
-	if b.Type != tIdentifier {
-		return nil, unexpected(b, "target identifier")
-	}
-	if p.sources[b.Text] {
-		return nil, semantic(b, errAlreadyDefined(b.Text))
+	targets, err := p.parseTargetList(str, &dupe.ast)
+	if err != nil {
+		return nil, err
 	}
-	dupe.TargetB = b.Text
-	p.sources[b.Text] = true
-	dupe.appendSpace(b)
+	dupe.Targets = targets
 
-	_, err := p.parseRequiredEol(str)
+	_, err = p.parseRequiredEol(str)
 	if err != nil {
 		return nil, err
 	}
@@ -824,6 +989,7 @@ loop:
 			}
 			return nil, unexpected(id, "field set identifier")
 		}
+		id = fieldPath(str, id)
 
 		eq := str.next()
 		if eq.Type != tEq {
@@ -855,11 +1021,25 @@ loop:
 	return cut, nil
 }
 
+const (
+	fanoutBy = "by"
+
+	// FanoutHash sends entries with the same field value to the same output.
+	FanoutHash = "hash"
+	// FanoutRoundRobin sends entries to each output in turn.
+	FanoutRoundRobin = "roundrobin"
+	// FanoutLeastLoaded sends entries to the output with the fewest entries waiting.
+	FanoutLeastLoaded = "leastloaded"
+)
+
 type Fanout struct {
 	ast
-	Source  string `json:"source"`
-	TargetA string `json:"targetA"`
-	TargetB string `json:"targetB"`
+	Source  string   `json:"source"`
+	Targets []string `json:"targets"`
+	// Strategy is how entries are partitioned between targets, or empty to send each entry to whichever target is ready first.
+	Strategy string `json:"strategy"`
+	// Field is the field hashed with the FanoutHash strategy.
+	Field string `json:"field"`
 }
 
 func (p *parser) parseFanout(str *tokenStream) (*Fanout, error) {
@@ -891,35 +1071,32 @@ func (p *parser) parseFanout(str *tokenStream) (*Fanout, error) {
 	}
 	fanout.appendSpace(as)
 
-	a := str.next()
-	if a.Type != tIdentifier {
-		return nil, unexpected(a, "target identifier")
-	}
-	if p.sources[a.Text] {
-		return nil, semantic(a, errAlreadyDefined(a.Text))
-	}
-	fanout.TargetA = a.Text
-	p.sources[a.Text] = true
-	fanout.appendSpace(a)
-
-	and := str.next()
-	if and.Type != tAnd {
-		return nil, unexpected(and, "and")
+	targets, err := p.parseTargetList(str, &fanout.ast)
+	if err != nil {
+		return nil, err
 	}
-	fanout.appendSpace(and)
+	fanout.Targets = targets
 
-	b := str.next()
-	if b.Type != tIdentifier {
-		return nil, unexpected(b, "target identifier")
-	}
-	if p.sources[b.Text] {
-		return nil, semantic(b, errAlreadyDefined(b.Text))
+	next := str.next()
+	switch {
+	case next.Type == tIdentifier && next.Text == fanoutBy:
+		fanout.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		fanout.Strategy = FanoutHash
+		fanout.Field = field.Text
+		fanout.appendSpace(field)
+	case next.Type == tIdentifier && (next.Text == FanoutRoundRobin || next.Text == FanoutLeastLoaded):
+		fanout.Strategy = next.Text
+		fanout.appendSpace(next)
+	default:
+		str.pushBack(next)
 	}
-	fanout.TargetB = b.Text
-	p.sources[b.Text] = true
-	fanout.appendSpace(b)
 
-	_, err := p.parseRequiredEol(str)
+	_, err = p.parseRequiredEol(str)
 	if err != nil {
 		return nil, err
 	}
@@ -974,10 +1151,29 @@ func (p *parser) parseTag(str *tokenStream) (*Tag, error) {
 	return t, nil
 }
 
+const (
+	joinContinuation = "continuation"
+	joinBy           = "by"
+	joinIdle         = "idle"
+	joinMax          = "max"
+	joinLines        = "lines"
+	joinBytes        = "bytes"
+)
+
 type Join struct {
 	ast
 	Source   string
 	Patterns []string
+	// Continuation is true if Patterns match continuation lines, rather than start lines.
+	Continuation bool
+	// GroupBy is a field used to join lines separately for each value, like a thread ID, if given.
+	GroupBy string
+	// Idle is how long to wait for more lines before emitting a message, or zero to wait for the next message.
+	Idle time.Duration
+	// MaxLines is the most lines joined into one message, or zero for no limit.
+	MaxLines int
+	// MaxBytes is the longest message that lines will be joined into, or zero for no limit.
+	MaxBytes int
 }
 
 func (p *parser) parseJoin(str *tokenStream) (*Join, error) {
@@ -1008,6 +1204,13 @@ func (p *parser) parseJoin(str *tokenStream) (*Join, error) {
 	}
 	j.appendSpace(with)
 
+	if next := str.next(); next.Type == tIdentifier && next.Text == joinContinuation {
+		j.Continuation = true
+		j.appendSpace(next)
+	} else {
+		str.pushBack(next)
+	}
+
 	patterns, err := p.parseJoinPatterns(str)
 	if err != nil {
 		return nil, err
@@ -1019,6 +1222,53 @@ func (p *parser) parseJoin(str *tokenStream) (*Join, error) {
 		j.Patterns[i] = escapeString(pattern)
 	}
 
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == joinBy {
+		j.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		j.GroupBy = field.Text
+		j.appendSpace(field)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == joinIdle {
+		j.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		j.Idle = dur
+		j.appendSpace(tok)
+		next = str.next()
+	}
+	for next.Type == tIdentifier && next.Text == joinMax {
+		j.appendSpace(next)
+		limit := str.next()
+		if limit.Type != tInt {
+			return nil, unexpected(limit, "limit integer")
+		}
+		n, err := strconv.Atoi(limit.Text)
+		if err != nil || n < 1 {
+			return nil, semantic(limit, fmt.Errorf("%w: must be at least 1", ErrInvalidJoinLimit))
+		}
+		j.appendSpace(limit)
+		unit := str.next()
+		switch {
+		case unit.Type == tIdentifier && unit.Text == joinLines && j.MaxLines == 0:
+			j.MaxLines = n
+		case unit.Type == tIdentifier && unit.Text == joinBytes && j.MaxBytes == 0:
+			j.MaxBytes = n
+		default:
+			return nil, unexpected(unit, joinLines, joinBytes)
+		}
+		j.appendSpace(unit)
+		next = str.next()
+	}
+	str.pushBack(next)
+
 	_, err = p.parseRequiredEol(str)
 	if err != nil {
 		return nil, err
@@ -1049,3 +1299,1853 @@ func (p *parser) parseJoinPatterns(str *tokenStream) ([]string, error) {
 		patterns = append(patterns, pattern.Text)
 	}
 }
+
+type Filter struct {
+	ast
+	Source string `json:"source"`
+	Expr   Expr   `json:"expr"`
+}
+
+func (p *parser) parseFilter(str *tokenStream) (*Filter, error) {
+	f := new(Filter)
+
+	filterKw := str.next()
+	if filterKw.Type != tFilter {
+		return nil, errNotAMatch
+	}
+	f.setVals(filterKw, FILTER)
+
+	src := str.next()
+	if src.Type != tIdentifier {
+		return nil, unexpected(src, "source identifier")
+	}
+	if !p.sources[src.Text] {
+		return nil, semantic(src, errUndefined(src.Text))
+	}
+	if p.consumed[src.Text] {
+		return nil, semantic(src, errAlreadyConsumed(src.Text))
+	}
+	f.Source = src.Text
+	f.appendSpace(src)
+
+	where := str.next()
+	if where.Type != tWhere {
+		return nil, unexpected(where, "where")
+	}
+	f.appendSpace(where)
+
+	expr, err := p.parseExpr(str)
+	if err != nil {
+		return nil, err
+	}
+	f.Expr = expr
+	f.appendTextSpace(expr.String())
+
+	_, err = p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Assignment sets Field to the result of Expr.
+type Assignment struct {
+	Field string `json:"field"`
+	Expr  Expr   `json:"expr"`
+}
+
+type Transform struct {
+	ast
+	Source      string        `json:"source"`
+	Assignments []*Assignment `json:"assignments"`
+}
+
+func (p *parser) parseTransform(str *tokenStream) (*Transform, error) {
+	tr := new(Transform)
+
+	trKw := str.next()
+	if trKw.Type != tTransform {
+		return nil, errNotAMatch
+	}
+	tr.setVals(trKw, TRANSFORM)
+
+	src := str.next()
+	if src.Type != tIdentifier {
+		return nil, unexpected(src, "source identifier")
+	}
+	if !p.sources[src.Text] {
+		return nil, semantic(src, errUndefined(src.Text))
+	}
+	if p.consumed[src.Text] {
+		return nil, semantic(src, errAlreadyConsumed(src.Text))
+	}
+	tr.Source = src.Text
+	tr.appendSpace(src)
+
+	set := str.next()
+	if set.Type != tSet {
+		return nil, unexpected(set, "set")
+	}
+	tr.appendSpace(set)
+
+	lp := str.next()
+	if lp.Type != tLpar {
+		return nil, unexpected(lp, "(")
+	}
+	tr.append(lp)
+
+	for {
+		id := str.next()
+		if id.Type != tIdentifier {
+			return nil, unexpected(id, "field identifier")
+		}
+		id = fieldPath(str, id)
+		eq := str.next()
+		if eq.Type != tEq {
+			return nil, unexpected(eq, "=")
+		}
+		expr, err := p.parseExpr(str)
+		if err != nil {
+			return nil, err
+		}
+		tr.Assignments = append(tr.Assignments, &Assignment{Field: id.Text, Expr: expr})
+		if len(tr.Assignments) > 1 {
+			tr.appendText(", ")
+		}
+		tr.appendText(id.Text + " = " + expr.String())
+
+		commaParen := str.next()
+		if commaParen.Type == tRpar {
+			tr.append(commaParen)
+			break
+		}
+		if commaParen.Type != tComma {
+			return nil, unexpected(commaParen, ",", ")")
+		}
+	}
+
+	_, err := p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// FieldRename moves the value of From to To.
+type FieldRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Rename struct {
+	ast
+	Source  string         `json:"source"`
+	Renames []*FieldRename `json:"renames"`
+}
+
+func (p *parser) parseRename(str *tokenStream) (*Rename, error) {
+	var (
+		r     = &Rename{}
+		froms = map[string]bool{}
+		tos   = map[string]bool{}
+	)
+
+	renameKw := str.next()
+	if renameKw.Type != tRename {
+		return nil, errNotAMatch
+	}
+	r.setVals(renameKw, RENAME)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	r.Source = src.Text
+	r.appendSpace(src)
+
+	set := str.next()
+	if set.Type != tSet {
+		return nil, unexpected(set, "set")
+	}
+	r.appendSpace(set)
+
+	lp := str.next()
+	if lp.Type != tLpar {
+		return nil, unexpected(lp, "(")
+	}
+	r.append(lp)
+
+	for {
+		from := str.next()
+		if from.Type != tIdentifier && from.Type != tInt {
+			return nil, unexpected(from, "field identifier", "field number")
+		}
+		if from.Type == tIdentifier {
+			from = fieldPath(str, from)
+		}
+		eq := str.next()
+		if eq.Type != tEq {
+			return nil, unexpected(eq, "=")
+		}
+		to := str.next()
+		if to.Type != tIdentifier {
+			return nil, unexpected(to, "field identifier")
+		}
+		to = fieldPath(str, to)
+		if froms[from.Text] {
+			return nil, semantic(from, fmt.Errorf("%w: '%s' is renamed more than once", ErrDuplicateRename, from.Text))
+		}
+		if tos[to.Text] {
+			return nil, semantic(to, fmt.Errorf("%w: more than one field is renamed to '%s'", ErrDuplicateRename, to.Text))
+		}
+		froms[from.Text], tos[to.Text] = true, true
+		if len(r.Renames) > 0 {
+			r.appendText(", ")
+		}
+		r.Renames = append(r.Renames, &FieldRename{From: from.Text, To: to.Text})
+		r.appendText(from.Text + "=" + to.Text)
+
+		commaParen := str.next()
+		if commaParen.Type == tRpar {
+			r.append(commaParen)
+			break
+		}
+		if commaParen.Type != tComma {
+			return nil, unexpected(commaParen, ",", ")")
+		}
+	}
+
+	_, err = p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type Drop struct {
+	ast
+	Source string   `json:"source"`
+	Fields []string `json:"fields"`
+}
+
+func (p *parser) parseDrop(str *tokenStream) (*Drop, error) {
+	d := new(Drop)
+
+	dropKw := str.next()
+	if dropKw.Type != tIdentifier || dropKw.Text != kwDrop {
+		return nil, errNotAMatch
+	}
+	d.setVals(dropKw, DROP)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	d.Source = src.Text
+	d.appendSpace(src)
+
+	fields, err := p.parseFieldList(str)
+	if err != nil {
+		return nil, err
+	}
+	d.Fields = fields
+	d.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+
+	_, err = p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type Keep struct {
+	ast
+	Source string   `json:"source"`
+	Fields []string `json:"fields"`
+}
+
+func (p *parser) parseKeep(str *tokenStream) (*Keep, error) {
+	k := new(Keep)
+
+	keepKw := str.next()
+	if keepKw.Type != tIdentifier || keepKw.Text != kwKeep {
+		return nil, errNotAMatch
+	}
+	k.setVals(keepKw, KEEP)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	k.Source = src.Text
+	k.appendSpace(src)
+
+	fields, err := p.parseFieldList(str)
+	if err != nil {
+		return nil, err
+	}
+	k.Fields = fields
+	k.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+
+	_, err = p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+type Parse struct {
+	ast
+	Source string   `json:"source"`
+	Format string   `json:"format"`
+	Args   []string `json:"args"`
+	Field  string   `json:"field"`
+}
+
+func (p *parser) parseParse(str *tokenStream) (*Parse, error) {
+	ps := &Parse{Field: entries.StandardMessageField}
+
+	parseKw := str.next()
+	if parseKw.Type != tParse {
+		return nil, errNotAMatch
+	}
+	ps.setVals(parseKw, PARSE)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	ps.Source = src.Text
+	ps.appendSpace(src)
+
+	as := str.next()
+	if as.Type != tAs {
+		return nil, unexpected(as, "as")
+	}
+	ps.appendSpace(as)
+
+	format := str.next()
+	if format.Type != tIdentifier {
+		return nil, unexpected(format, "parser format")
+	}
+	ps.Format = format.Text
+	ps.appendSpace(format)
+
+	next := str.next()
+	for next.Type == tString {
+		if len(ps.Args) > 0 {
+			ps.appendText(",")
+		}
+		ps.Args = append(ps.Args, escapeString(next.Text))
+		ps.appendSpace(next)
+		next = str.next()
+		if next.Type != tComma {
+			break
+		}
+		next = str.next()
+		if next.Type != tString {
+			return nil, unexpected(next, "parser argument string")
+		}
+	}
+	if _, err := entries.NewParser(ps.Format, ps.Args...); err != nil {
+		return nil, semantic(format, err)
+	}
+
+	if next.Type == tIdentifier && next.Text == kwFrom {
+		ps.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		ps.Field = field.Text
+		ps.appendSpace(field)
+	} else {
+		str.pushBack(next)
+	}
+
+	_, err = p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+const (
+	normalizeTimestamp = "timestamp"
+	normalizeLevel     = "level"
+)
+
+// parseNormalize reads the common prefix of normalize statements, and dispatches to the parser for the normalization target.
+func (p *parser) parseNormalize(str *tokenStream) (AstNode, error) {
+	normalizeKw := str.next()
+	if normalizeKw.Type != tNormalize {
+		return nil, errNotAMatch
+	}
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+
+	target := str.next()
+	if target.Type != tIdentifier {
+		return nil, unexpected(target, normalizeTimestamp, normalizeLevel)
+	}
+	switch target.Text {
+	case normalizeTimestamp:
+		return p.parseNormalizeTimestamp(str, normalizeKw, src, target)
+	case normalizeLevel:
+		return p.parseNormalizeLevel(str, normalizeKw, src, target)
+	default:
+		return nil, unexpected(target, normalizeTimestamp, normalizeLevel)
+	}
+}
+
+type NormalizeTimestamp struct {
+	ast
+	Source  string   `json:"source"`
+	Fields  []string `json:"fields"`
+	Layouts []string `json:"layouts"`
+	Zone    string   `json:"zone"`
+}
+
+func (p *parser) parseNormalizeTimestamp(str *tokenStream, normalizeKw, src, target token) (*NormalizeTimestamp, error) {
+	n := new(NormalizeTimestamp)
+	n.setVals(normalizeKw, NORMALIZE_TIMESTAMP)
+	n.Source = src.Text
+	n.appendSpace(src)
+	n.appendSpace(target)
+
+	next := str.peek()
+	if next.Type == tIdentifier && next.Text == kwFields {
+		fields, err := p.parseFieldList(str)
+		if err != nil {
+			return nil, err
+		}
+		n.Fields = fields
+		n.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+		next = str.peek()
+	}
+
+	if next.Type == tWith {
+		n.appendSpace(str.next())
+		for {
+			layout := str.next()
+			if layout.Type != tString {
+				return nil, unexpected(layout, "layout string")
+			}
+			n.Layouts = append(n.Layouts, escapeString(layout.Text))
+			n.appendSpace(layout)
+			comma := str.next()
+			if comma.Type != tComma {
+				str.pushBack(comma)
+				break
+			}
+			n.append(comma)
+		}
+		next = str.peek()
+	}
+
+	if next.Type == tIdentifier && next.Text == kwIn {
+		n.appendSpace(str.next())
+		zone := str.next()
+		if zone.Type != tString {
+			return nil, unexpected(zone, "time zone string")
+		}
+		n.Zone = escapeString(zone.Text)
+		if _, err := time.LoadLocation(n.Zone); err != nil {
+			return nil, semantic(zone, err)
+		}
+		n.appendSpace(zone)
+	}
+
+	_, err := p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+type NormalizeLevel struct {
+	ast
+	Source   string            `json:"source"`
+	Fields   []string          `json:"fields"`
+	Mappings []string          `json:"mappings"`
+	Custom   map[string]string `json:"custom"`
+}
+
+func (p *parser) parseNormalizeLevel(str *tokenStream, normalizeKw, src, target token) (*NormalizeLevel, error) {
+	n := &NormalizeLevel{Custom: map[string]string{}}
+	n.setVals(normalizeKw, NORMALIZE_LEVEL)
+	n.Source = src.Text
+	n.appendSpace(src)
+	n.appendSpace(target)
+
+	next := str.peek()
+	if next.Type == tIdentifier && next.Text == kwFields {
+		fields, err := p.parseFieldList(str)
+		if err != nil {
+			return nil, err
+		}
+		n.Fields = fields
+		n.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+		next = str.peek()
+	}
+
+	if next.Type == tWith {
+		n.appendSpace(str.next())
+		for {
+			mapping := str.next()
+			if mapping.Type != tString {
+				return nil, unexpected(mapping, "level mapping string")
+			}
+			name := escapeString(mapping.Text)
+			if _, ok := entries.NamedLevelMapping(name); !ok {
+				return nil, semantic(mapping, fmt.Errorf("%w '%s'", ErrUnknownLevelMapping, name))
+			}
+			n.Mappings = append(n.Mappings, name)
+			n.appendSpace(mapping)
+			comma := str.next()
+			if comma.Type != tComma {
+				str.pushBack(comma)
+				break
+			}
+			n.append(comma)
+		}
+		next = str.peek()
+	}
+
+	if next.Type == tSet {
+		n.appendSpace(str.next())
+		lp := str.next()
+		if lp.Type != tLpar {
+			return nil, unexpected(lp, "(")
+		}
+		n.append(lp)
+		for {
+			raw := str.next()
+			var key string
+			switch raw.Type {
+			case tString:
+				key = escapeString(raw.Text)
+			case tInt, tIdentifier:
+				key = raw.Text
+			default:
+				return nil, unexpected(raw, "raw level string", "raw level number", "raw level identifier")
+			}
+			eq := str.next()
+			if eq.Type != tEq {
+				return nil, unexpected(eq, "=")
+			}
+			level := str.next()
+			if level.Type != tIdentifier {
+				return nil, unexpected(level, "level name")
+			}
+			if _, ok := entries.ParseLevelName(level.Text); !ok {
+				return nil, semantic(level, fmt.Errorf("%w '%s'", ErrUnknownLevel, level.Text))
+			}
+			if len(n.Custom) > 0 {
+				n.appendText(", ")
+			}
+			n.Custom[key] = level.Text
+			n.appendText(raw.Text + "=" + level.Text)
+
+			commaParen := str.next()
+			if commaParen.Type == tRpar {
+				n.append(commaParen)
+				break
+			}
+			if commaParen.Type != tComma {
+				return nil, unexpected(commaParen, ",", ")")
+			}
+		}
+	}
+
+	_, err := p.parseRequiredEol(str)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+const (
+	flattenArrays = "arrays"
+	explodeIndex  = "index"
+)
+
+type Flatten struct {
+	ast
+	Source    string `json:"source"`
+	Separator string `json:"separator"`
+	Arrays    bool   `json:"arrays"`
+}
+
+func (p *parser) parseFlatten(str *tokenStream) (*Flatten, error) {
+	f := new(Flatten)
+
+	flattenKw := str.next()
+	if flattenKw.Type != tFlatten {
+		return nil, errNotAMatch
+	}
+	f.setVals(flattenKw, FLATTEN)
+
+	src, sep, arrays, err := p.parseFlattenOpts(str, &f.ast)
+	if err != nil {
+		return nil, err
+	}
+	f.Source, f.Separator, f.Arrays = src, sep, arrays
+	return f, nil
+}
+
+type Unflatten struct {
+	ast
+	Source    string `json:"source"`
+	Separator string `json:"separator"`
+	Arrays    bool   `json:"arrays"`
+}
+
+func (p *parser) parseUnflatten(str *tokenStream) (*Unflatten, error) {
+	u := new(Unflatten)
+
+	unflattenKw := str.next()
+	if unflattenKw.Type != tUnflatten {
+		return nil, errNotAMatch
+	}
+	u.setVals(unflattenKw, UNFLATTEN)
+
+	src, sep, arrays, err := p.parseFlattenOpts(str, &u.ast)
+	if err != nil {
+		return nil, err
+	}
+	u.Source, u.Separator, u.Arrays = src, sep, arrays
+	return u, nil
+}
+
+// parseFlattenOpts reads the remainder of a flatten or unflatten statement, in the form "IDENTIFIER [with STRING] [arrays]".
+func (p *parser) parseFlattenOpts(str *tokenStream, node *ast) (src string, sep string, arrays bool, err error) {
+	srcTok, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return "", "", false, err
+	}
+	src = srcTok.Text
+	node.appendSpace(srcTok)
+
+	next := str.next()
+	if next.Type == tWith {
+		node.appendSpace(next)
+		s := str.next()
+		if s.Type != tString {
+			return "", "", false, unexpected(s, "separator string")
+		}
+		sep = escapeString(s.Text)
+		if len(sep) == 0 {
+			return "", "", false, semantic(s, ErrEmptySeparator)
+		}
+		node.appendSpace(s)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == flattenArrays {
+		arrays = true
+		node.appendSpace(next)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return "", "", false, err
+	}
+	return src, sep, arrays, nil
+}
+
+type Explode struct {
+	ast
+	Source     string `json:"source"`
+	Field      string `json:"field"`
+	IndexField string `json:"index_field"`
+}
+
+func (p *parser) parseExplode(str *tokenStream) (*Explode, error) {
+	e := new(Explode)
+
+	explodeKw := str.next()
+	if explodeKw.Type != tExplode {
+		return nil, errNotAMatch
+	}
+	e.setVals(explodeKw, EXPLODE)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	e.Source = src.Text
+	e.appendSpace(src)
+
+	field := str.next()
+	if field.Type != tIdentifier {
+		return nil, unexpected(field, "field identifier")
+	}
+	field = fieldPath(str, field)
+	e.Field = field.Text
+	e.appendSpace(field)
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == explodeIndex {
+		e.appendSpace(next)
+		idx := str.next()
+		if idx.Type != tIdentifier {
+			return nil, unexpected(idx, "index field identifier")
+		}
+		idx = fieldPath(str, idx)
+		e.IndexField = idx.Text
+		e.appendSpace(idx)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+const (
+	redactMatch = "match"
+	redactHash  = "hash"
+)
+
+type Redact struct {
+	ast
+	Source    string   `json:"source"`
+	Fields    []string `json:"fields"`
+	Detectors []string `json:"detectors"`
+	Patterns  []string `json:"patterns"`
+	Mode      string   `json:"mode"`
+	Key       string   `json:"key"`
+}
+
+func (p *parser) parseRedact(str *tokenStream) (*Redact, error) {
+	r := &Redact{Mode: entries.MaskFull.String()}
+
+	redactKw := str.next()
+	if redactKw.Type != tRedact {
+		return nil, errNotAMatch
+	}
+	r.setVals(redactKw, REDACT)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	r.Source = src.Text
+	r.appendSpace(src)
+
+	next := str.peek()
+	if next.Type == tIdentifier && next.Text == kwFields {
+		fields, err := p.parseFieldList(str)
+		if err != nil {
+			return nil, err
+		}
+		r.Fields = fields
+		r.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+		next = str.peek()
+	}
+
+	if next.Type == tWith {
+		r.appendSpace(str.next())
+		for {
+			detector := str.next()
+			if detector.Type != tString {
+				return nil, unexpected(detector, "detector name string")
+			}
+			name := escapeString(detector.Text)
+			if _, ok := entries.NamedDetector(name); !ok {
+				return nil, semantic(detector, fmt.Errorf("%w '%s'", ErrUnknownDetector, name))
+			}
+			r.Detectors = append(r.Detectors, name)
+			r.appendSpace(detector)
+			comma := str.next()
+			if comma.Type != tComma {
+				str.pushBack(comma)
+				break
+			}
+			r.append(comma)
+		}
+		next = str.peek()
+	}
+
+	if next.Type == tIdentifier && next.Text == redactMatch {
+		r.appendSpace(str.next())
+		for {
+			pattern := str.next()
+			if pattern.Type != tString {
+				return nil, unexpected(pattern, "regex pattern string")
+			}
+			pat := escapeString(pattern.Text)
+			if _, err := entries.NewRegexDetector("", pat); err != nil {
+				return nil, semantic(pattern, err)
+			}
+			r.Patterns = append(r.Patterns, pat)
+			r.appendSpace(pattern)
+			comma := str.next()
+			if comma.Type != tComma {
+				str.pushBack(comma)
+				break
+			}
+			r.append(comma)
+		}
+		next = str.peek()
+	}
+
+	if next.Type == tAs {
+		r.appendSpace(str.next())
+		mode := str.next()
+		if mode.Type != tIdentifier {
+			return nil, unexpected(mode, "full", "partial", redactHash)
+		}
+		m, ok := entries.ParseMaskMode(mode.Text)
+		if !ok {
+			return nil, semantic(mode, fmt.Errorf("%w '%s'", ErrUnknownMaskMode, mode.Text))
+		}
+		r.Mode = m.String()
+		r.appendSpace(mode)
+		if m == entries.MaskHMAC {
+			key := str.next()
+			if key.Type != tString {
+				return nil, unexpected(key, "key string")
+			}
+			r.Key = escapeString(key.Text)
+			if len(r.Key) == 0 {
+				return nil, semantic(key, entries.ErrMissingRedactKey)
+			}
+			r.appendSpace(key)
+		}
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+const (
+	clusterDepth      = "depth"
+	clusterSimilarity = "similarity"
+	clusterSummary    = "summary"
+)
+
+type Cluster struct {
+	ast
+	Source     string  `json:"source"`
+	Field      string  `json:"field"`
+	Depth      int     `json:"depth"`
+	Similarity float64 `json:"similarity"`
+	Summary    bool    `json:"summary"`
+}
+
+func (p *parser) parseCluster(str *tokenStream) (*Cluster, error) {
+	c := new(Cluster)
+
+	clusterKw := str.next()
+	if clusterKw.Type != tCluster {
+		return nil, errNotAMatch
+	}
+	c.setVals(clusterKw, CLUSTER)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	c.Source = src.Text
+	c.appendSpace(src)
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == kwFrom {
+		c.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		c.Field = field.Text
+		c.appendSpace(field)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == clusterDepth {
+		c.appendSpace(next)
+		depth := str.next()
+		if depth.Type != tInt {
+			return nil, unexpected(depth, "depth integer")
+		}
+		c.Depth, err = strconv.Atoi(depth.Text)
+		if err != nil || c.Depth < 3 {
+			return nil, semantic(depth, fmt.Errorf("%w: depth must be at least 3", ErrInvalidClusterOpt))
+		}
+		c.appendSpace(depth)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == clusterSimilarity {
+		c.appendSpace(next)
+		sim := str.next()
+		if sim.Type != tNumber && sim.Type != tInt {
+			return nil, unexpected(sim, "similarity number")
+		}
+		c.Similarity, err = strconv.ParseFloat(sim.Text, 64)
+		if err != nil || c.Similarity <= 0 || c.Similarity > 1 {
+			return nil, semantic(sim, fmt.Errorf("%w: similarity must be greater than 0, and at most 1", ErrInvalidClusterOpt))
+		}
+		c.appendSpace(sim)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == clusterSummary {
+		c.Summary = true
+		c.appendSpace(next)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+const (
+	routeAll     = "all"
+	routeDefault = "default"
+)
+
+// RouteBranch is a named output of a Route, which receives entries matching Expr.
+type RouteBranch struct {
+	ID   string `json:"id"`
+	Expr Expr   `json:"expr"`
+}
+
+type Route struct {
+	ast
+	Source string `json:"source"`
+	// All sends entries to every matching branch, instead of only the first.
+	All      bool           `json:"all"`
+	Branches []*RouteBranch `json:"branches"`
+	// Default is the identifier that receives entries matching no branch, if any.
+	Default string `json:"default"`
+}
+
+func (p *parser) parseRoute(str *tokenStream) (*Route, error) {
+	r := new(Route)
+
+	routeKw := str.next()
+	if routeKw.Type != tIdentifier || routeKw.Text != kwRoute {
+		return nil, errNotAMatch
+	}
+	r.setVals(routeKw, ROUTE)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	p.consumed[src.Text] = true
+	r.Source = src.Text
+	r.appendSpace(src)
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == routeAll {
+		r.All = true
+		r.appendSpace(next)
+		next = str.next()
+	}
+	if next.Type != tTo {
+		return nil, unexpected(next, routeAll, "to")
+	}
+	r.appendSpace(next)
+
+	for {
+		id, err := p.parseNewRouteID(str)
+		if err != nil {
+			return nil, err
+		}
+		r.appendSpace(id)
+
+		where := str.next()
+		if where.Type != tWhere {
+			return nil, unexpected(where, "where")
+		}
+		r.appendSpace(where)
+
+		expr, err := p.parseExpr(str)
+		if err != nil {
+			return nil, err
+		}
+		r.Branches = append(r.Branches, &RouteBranch{ID: id.Text, Expr: expr})
+		r.appendTextSpace(expr.String())
+
+		comma := str.next()
+		if comma.Type != tComma {
+			str.pushBack(comma)
+			break
+		}
+		r.append(comma)
+	}
+
+	next = str.next()
+	if next.Type == tIdentifier && next.Text == routeDefault {
+		r.appendSpace(next)
+		id, err := p.parseNewRouteID(str)
+		if err != nil {
+			return nil, err
+		}
+		r.Default = id.Text
+		r.appendSpace(id)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parseNewRouteID reads the identifier of a route output, and defines it as a source.
+func (p *parser) parseNewRouteID(str *tokenStream) (token, error) {
+	id := str.next()
+	if id.Type != tIdentifier {
+		return id, unexpected(id, "route identifier")
+	}
+	if p.sources[id.Text] {
+		return id, semantic(id, errAlreadyDefined(id.Text))
+	}
+	p.sources[id.Text] = true
+	return id, nil
+}
+
+// Parallel sets the number of workers used to run stateless operations on a stream, like cut, parse, and transform.
+type Parallel struct {
+	ast
+	Source  string `json:"source"`
+	Workers int    `json:"workers"`
+}
+
+func (p *parser) parseParallel(str *tokenStream) (*Parallel, error) {
+	par := new(Parallel)
+
+	parKw := str.next()
+	if parKw.Type != tParallel {
+		return nil, errNotAMatch
+	}
+	par.setVals(parKw, PARALLEL)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	par.Source = src.Text
+	par.appendSpace(src)
+
+	with := str.next()
+	if with.Type != tWith {
+		return nil, unexpected(with, "with")
+	}
+	par.appendSpace(with)
+
+	workers := str.next()
+	if workers.Type != tInt {
+		return nil, unexpected(workers, "worker count")
+	}
+	par.Workers, err = strconv.Atoi(workers.Text)
+	if err != nil || par.Workers < 1 {
+		return nil, semantic(workers, fmt.Errorf("%w: must be at least 1", ErrInvalidWorkers))
+	}
+	par.appendSpace(workers)
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return par, nil
+}
+
+const (
+	aggregateBy      = "by"
+	aggregateEvery   = "every"
+	aggregateSlide   = "slide"
+	aggregateOn      = "on"
+	aggregateArrival = "arrival"
+	aggregateLate    = "late"
+
+	aggregateCount      = "count"
+	aggregatePercentile = "percentile"
+)
+
+var aggregateFuncs = map[string]bool{
+	aggregateCount:      true,
+	"sum":               true,
+	"min":               true,
+	"max":               true,
+	"avg":               true,
+	"distinct":          true,
+	aggregatePercentile: true,
+}
+
+// AggregateValue is a value computed for each group in each window of an Aggregate, like count or p95(latency).
+type AggregateValue struct {
+	Func       string  `json:"func"`
+	Field      string  `json:"field"`
+	Percentile float64 `json:"percentile"`
+	As         string  `json:"as"`
+}
+
+type Aggregate struct {
+	ast
+	Source  string            `json:"source"`
+	ID      string            `json:"id"`
+	Values  []*AggregateValue `json:"values"`
+	GroupBy []string          `json:"groupBy"`
+	Window  time.Duration     `json:"window"`
+	// Slide is how often a new window starts for sliding windows, or zero for tumbling windows.
+	Slide time.Duration `json:"slide"`
+	// TimeField is the field holding each entry's time, if not the default.
+	TimeField string `json:"timeField"`
+	// Arrival windows entries by the time they're read instead of a time field.
+	Arrival bool `json:"arrival"`
+	// Lateness is how long to wait for out of order entries before a window is emitted.
+	Lateness time.Duration `json:"lateness"`
+}
+
+func (p *parser) parseAggregate(str *tokenStream) (*Aggregate, error) {
+	a := new(Aggregate)
+
+	aggKw := str.next()
+	if aggKw.Type != tAggregate {
+		return nil, errNotAMatch
+	}
+	a.setVals(aggKw, AGGREGATE)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	p.consumed[src.Text] = true
+	a.Source = src.Text
+	a.appendSpace(src)
+
+	as := str.next()
+	if as.Type != tAs {
+		return nil, unexpected(as, "as")
+	}
+	a.appendSpace(as)
+
+	id := str.next()
+	if id.Type != tIdentifier {
+		return nil, unexpected(id, "aggregate identifier")
+	}
+	if p.sources[id.Text] {
+		return nil, semantic(id, errAlreadyDefined(id.Text))
+	}
+	p.sources[id.Text] = true
+	a.ID = id.Text
+	a.appendSpace(id)
+
+	for {
+		val, err := p.parseAggregateValue(str, a)
+		if err != nil {
+			return nil, err
+		}
+		a.Values = append(a.Values, val)
+
+		comma := str.next()
+		if comma.Type != tComma {
+			str.pushBack(comma)
+			break
+		}
+		a.append(comma)
+	}
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == aggregateBy {
+		a.appendSpace(next)
+		for {
+			field := str.next()
+			if field.Type != tIdentifier {
+				return nil, unexpected(field, "field identifier")
+			}
+			field = fieldPath(str, field)
+			a.GroupBy = append(a.GroupBy, field.Text)
+			a.appendSpace(field)
+
+			comma := str.next()
+			if comma.Type != tComma {
+				str.pushBack(comma)
+				break
+			}
+			a.append(comma)
+		}
+		next = str.next()
+	}
+
+	if next.Type != tIdentifier || next.Text != aggregateEvery {
+		return nil, unexpected(next, aggregateBy, aggregateEvery)
+	}
+	a.appendSpace(next)
+	tok, dur, err := parseDuration(str)
+	if err != nil {
+		return nil, err
+	}
+	a.Window = dur
+	a.appendSpace(tok)
+
+	next = str.next()
+	if next.Type == tIdentifier && next.Text == aggregateSlide {
+		a.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		if dur > a.Window {
+			return nil, semantic(tok, fmt.Errorf("%w: slide must not be longer than the window", ErrInvalidDuration))
+		}
+		a.Slide = dur
+		a.appendSpace(tok)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == aggregateOn {
+		a.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, aggregateArrival, "field identifier")
+		}
+		field = fieldPath(str, field)
+		if field.Text == aggregateArrival {
+			a.Arrival = true
+		} else {
+			a.TimeField = field.Text
+		}
+		a.appendSpace(field)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == aggregateLate {
+		a.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		a.Lateness = dur
+		a.appendSpace(tok)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// parseAggregateValue parses an aggregate function, like count, sum(bytes), p95(latency), or percentile(latency, 99.9), with an optional alias.
+func (p *parser) parseAggregateValue(str *tokenStream, a *Aggregate) (*AggregateValue, error) {
+	name := str.next()
+	if name.Type != tIdentifier {
+		return nil, unexpected(name, "aggregate function")
+	}
+	val := &AggregateValue{Func: strings.ToLower(name.Text)}
+	named := val.Func == aggregatePercentile
+	if !aggregateFuncs[val.Func] {
+		pct, err := strconv.ParseFloat(val.Func[1:], 64)
+		if val.Func[0] != 'p' || err != nil {
+			return nil, semantic(name, fmt.Errorf("%w: unknown function '%s'", ErrInvalidAggregate, name.Text))
+		}
+		val.Func = aggregatePercentile
+		val.Percentile = pct
+		if !(pct >= 0 && pct <= 100) {
+			return nil, semantic(name, fmt.Errorf("%w: percentile must be from 0 to 100", ErrInvalidAggregate))
+		}
+	}
+	a.appendSpace(name)
+
+	lp := str.next()
+	if lp.Type != tLpar {
+		if val.Func != aggregateCount {
+			return nil, unexpected(lp, "(")
+		}
+		str.pushBack(lp)
+	} else {
+		a.append(lp)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		field = fieldPath(str, field)
+		val.Field = field.Text
+		a.append(field)
+
+		if named {
+			// The percentile is given as an argument, like percentile(latency, 99.9).
+			comma := str.next()
+			if comma.Type != tComma {
+				return nil, unexpected(comma, ",")
+			}
+			a.append(comma)
+			pct := str.next()
+			if pct.Type != tNumber && pct.Type != tInt {
+				return nil, unexpected(pct, "percentile number")
+			}
+			val.Percentile, _ = strconv.ParseFloat(pct.Text, 64)
+			if !(val.Percentile >= 0 && val.Percentile <= 100) {
+				return nil, semantic(pct, fmt.Errorf("%w: percentile must be from 0 to 100", ErrInvalidAggregate))
+			}
+			a.appendSpace(pct)
+		}
+		rp := str.next()
+		if rp.Type != tRpar {
+			return nil, unexpected(rp, ")")
+		}
+		a.append(rp)
+	}
+
+	as := str.next()
+	if as.Type != tAs {
+		str.pushBack(as)
+		return val, nil
+	}
+	a.appendSpace(as)
+	alias := str.next()
+	if alias.Type != tIdentifier {
+		return nil, unexpected(alias, "field identifier")
+	}
+	alias = fieldPath(str, alias)
+	val.As = alias.Text
+	a.appendSpace(alias)
+	return val, nil
+}
+
+const (
+	dedupIgnore = "ignore"
+	dedupWithin = "within"
+	dedupLimit  = "limit"
+)
+
+type Dedup struct {
+	ast
+	Source string `json:"source"`
+	// Fields are compared instead of the whole entry, if given.
+	Fields []string `json:"fields"`
+	// Ignore are fields that aren't compared when comparing whole entries, in addition to read metadata.
+	Ignore []string `json:"ignore"`
+	// Window is how long duplicates are suppressed, if not the default.
+	Window time.Duration `json:"window"`
+	// Limit is the most duplicates suppressed before a summary is emitted, or zero for no limit.
+	Limit int `json:"limit"`
+}
+
+func (p *parser) parseDedup(str *tokenStream) (*Dedup, error) {
+	d := new(Dedup)
+
+	dedupKw := str.next()
+	if dedupKw.Type != tDedup {
+		return nil, errNotAMatch
+	}
+	d.setVals(dedupKw, DEDUP)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	d.Source = src.Text
+	d.appendSpace(src)
+
+	next := str.peek()
+	switch {
+	case next.Type == tIdentifier && next.Text == kwFields:
+		fields, err := p.parseFieldList(str)
+		if err != nil {
+			return nil, err
+		}
+		d.Fields = fields
+		d.appendTextSpace("fields(" + strings.Join(fields, ", ") + ")")
+	case next.Type == tIdentifier && next.Text == dedupIgnore:
+		d.appendSpace(str.next())
+		fields, err := p.parseParenFields(str)
+		if err != nil {
+			return nil, err
+		}
+		d.Ignore = fields
+		d.appendText("(" + strings.Join(fields, ", ") + ")")
+	}
+
+	next = str.next()
+	if next.Type == tIdentifier && next.Text == dedupWithin {
+		d.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		d.Window = dur
+		d.appendSpace(tok)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == dedupLimit {
+		d.appendSpace(next)
+		limit := str.next()
+		if limit.Type != tInt {
+			return nil, unexpected(limit, "limit integer")
+		}
+		d.Limit, err = strconv.Atoi(limit.Text)
+		if err != nil || d.Limit < 1 {
+			return nil, semantic(limit, fmt.Errorf("%w: must be at least 1", ErrInvalidDedupLimit))
+		}
+		d.appendSpace(limit)
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+const (
+	correlateBy      = "by"
+	correlateOn      = "on"
+	correlateTimeout = "timeout"
+	correlateMax     = "max"
+	correlateEnd     = "end"
+)
+
+type Correlate struct {
+	ast
+	Source string `json:"source"`
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	// TimeField is the field holding each entry's time, if not the default.
+	TimeField string `json:"timeField"`
+	// Timeout is how long a group waits for another entry before it's closed, if not the default.
+	Timeout time.Duration `json:"timeout"`
+	// MaxSize is the most entries in a group before it's closed, or zero for no limit.
+	MaxSize int `json:"maxSize"`
+	// End matches the entry that closes a group, if given.
+	End Expr `json:"end"`
+}
+
+func (p *parser) parseCorrelate(str *tokenStream) (*Correlate, error) {
+	c := new(Correlate)
+
+	corrKw := str.next()
+	if corrKw.Type != tCorrelate {
+		return nil, errNotAMatch
+	}
+	c.setVals(corrKw, CORRELATE)
+
+	src, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	p.consumed[src.Text] = true
+	c.Source = src.Text
+	c.appendSpace(src)
+
+	as := str.next()
+	if as.Type != tAs {
+		return nil, unexpected(as, "as")
+	}
+	c.appendSpace(as)
+
+	id := str.next()
+	if id.Type != tIdentifier {
+		return nil, unexpected(id, "correlate identifier")
+	}
+	if p.sources[id.Text] {
+		return nil, semantic(id, errAlreadyDefined(id.Text))
+	}
+	p.sources[id.Text] = true
+	c.ID = id.Text
+	c.appendSpace(id)
+
+	by := str.next()
+	if by.Type != tIdentifier || by.Text != correlateBy {
+		return nil, unexpected(by, correlateBy)
+	}
+	c.appendSpace(by)
+	key := str.next()
+	if key.Type != tIdentifier {
+		return nil, unexpected(key, "key field identifier")
+	}
+	key = fieldPath(str, key)
+	c.Key = key.Text
+	c.appendSpace(key)
+
+	next := str.next()
+	if next.Type == tIdentifier && next.Text == correlateOn {
+		c.appendSpace(next)
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "time field identifier")
+		}
+		field = fieldPath(str, field)
+		c.TimeField = field.Text
+		c.appendSpace(field)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == correlateTimeout {
+		c.appendSpace(next)
+		tok, dur, err := parseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		c.Timeout = dur
+		c.appendSpace(tok)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == correlateMax {
+		c.appendSpace(next)
+		size := str.next()
+		if size.Type != tInt {
+			return nil, unexpected(size, "max size integer")
+		}
+		c.MaxSize, err = strconv.Atoi(size.Text)
+		if err != nil || c.MaxSize < 1 {
+			return nil, semantic(size, fmt.Errorf("%w: must be at least 1", ErrInvalidGroupSize))
+		}
+		c.appendSpace(size)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == correlateEnd {
+		c.appendSpace(next)
+		where := str.next()
+		if where.Type != tWhere {
+			return nil, unexpected(where, "where")
+		}
+		c.appendSpace(where)
+		expr, err := p.parseExpr(str)
+		if err != nil {
+			return nil, err
+		}
+		c.End = expr
+		c.appendTextSpace(expr.String())
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+const (
+	pairOn     = "on"
+	pairWithin = "within"
+	pairPrefix = "prefix"
+
+	// PairInner only emits paired events.
+	PairInner = "inner"
+	// PairLeft also emits events from the left source that weren't paired.
+	PairLeft = "left"
+	// PairFull also emits events from either source that weren't paired.
+	PairFull = "full"
+)
+
+type Pair struct {
+	ast
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	// RightKey is the key field in the right source, if it's different from Key.
+	RightKey string        `json:"rightKey"`
+	Window   time.Duration `json:"window"`
+	// Mode is one of PairInner, PairLeft, or PairFull.
+	Mode string `json:"mode"`
+	// Prefixed is true if LeftPrefix and RightPrefix were given.
+	Prefixed    bool   `json:"prefixed"`
+	LeftPrefix  string `json:"leftPrefix"`
+	RightPrefix string `json:"rightPrefix"`
+}
+
+func (p *parser) parsePair(str *tokenStream) (*Pair, error) {
+	pr := &Pair{Mode: PairInner}
+
+	pairKw := str.next()
+	if pairKw.Type != tPair {
+		return nil, errNotAMatch
+	}
+	pr.setVals(pairKw, PAIR)
+
+	left, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	p.consumed[left.Text] = true
+	pr.Left = left.Text
+	pr.appendSpace(left)
+	if _, err := p.parseListSeparator(str, &pr.ast, 1); err != nil {
+		return nil, err
+	}
+	right, err := p.parseUnconsumedSource(str)
+	if err != nil {
+		return nil, err
+	}
+	p.consumed[right.Text] = true
+	pr.Right = right.Text
+	pr.appendSpace(right)
+
+	as := str.next()
+	if as.Type != tAs {
+		return nil, unexpected(as, "as")
+	}
+	pr.appendSpace(as)
+
+	id := str.next()
+	if id.Type != tIdentifier {
+		return nil, unexpected(id, "pair identifier")
+	}
+	if p.sources[id.Text] {
+		return nil, semantic(id, errAlreadyDefined(id.Text))
+	}
+	p.sources[id.Text] = true
+	pr.ID = id.Text
+	pr.appendSpace(id)
+
+	on := str.next()
+	if on.Type != tIdentifier || on.Text != pairOn {
+		return nil, unexpected(on, pairOn)
+	}
+	pr.appendSpace(on)
+	key := str.next()
+	if key.Type != tIdentifier {
+		return nil, unexpected(key, "key field identifier")
+	}
+	key = fieldPath(str, key)
+	pr.Key = key.Text
+	pr.appendSpace(key)
+	if eq := str.next(); eq.Type == tEq {
+		pr.appendSpace(eq)
+		rightKey := str.next()
+		if rightKey.Type != tIdentifier {
+			return nil, unexpected(rightKey, "right key field identifier")
+		}
+		rightKey = fieldPath(str, rightKey)
+		pr.RightKey = rightKey.Text
+		pr.appendSpace(rightKey)
+	} else {
+		str.pushBack(eq)
+	}
+
+	within := str.next()
+	if within.Type != tIdentifier || within.Text != pairWithin {
+		return nil, unexpected(within, pairWithin)
+	}
+	pr.appendSpace(within)
+	tok, dur, err := parseDuration(str)
+	if err != nil {
+		return nil, err
+	}
+	pr.Window = dur
+	pr.appendSpace(tok)
+
+	next := str.next()
+	if next.Type == tIdentifier && (next.Text == PairInner || next.Text == PairLeft || next.Text == PairFull) {
+		pr.Mode = next.Text
+		pr.appendSpace(next)
+		next = str.next()
+	}
+	if next.Type == tIdentifier && next.Text == pairPrefix {
+		pr.appendSpace(next)
+		leftPrefix := str.next()
+		if leftPrefix.Type != tString {
+			return nil, unexpected(leftPrefix, "left prefix string")
+		}
+		pr.appendSpace(leftPrefix)
+		comma := str.next()
+		if comma.Type != tComma {
+			return nil, unexpected(comma, ",")
+		}
+		pr.append(comma)
+		rightPrefix := str.next()
+		if rightPrefix.Type != tString {
+			return nil, unexpected(rightPrefix, "right prefix string")
+		}
+		pr.appendSpace(rightPrefix)
+		pr.Prefixed = true
+		pr.LeftPrefix = escapeString(leftPrefix.Text)
+		pr.RightPrefix = escapeString(rightPrefix.Text)
+		if pr.LeftPrefix == pr.RightPrefix {
+			return nil, semantic(rightPrefix, fmt.Errorf("%w: prefixes must be different", ErrInvalidPairPrefix))
+		}
+	} else {
+		str.pushBack(next)
+	}
+
+	if _, err := p.parseRequiredEol(str); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// parseDuration reads a string duration like "5s" or "1m30s", which must be positive.
+func parseDuration(str *tokenStream) (token, time.Duration, error) {
+	tok := str.next()
+	if tok.Type != tString {
+		return tok, 0, unexpected(tok, "duration string")
+	}
+	dur, err := time.ParseDuration(escapeString(tok.Text))
+	if err != nil {
+		return tok, 0, semantic(tok, fmt.Errorf("%w: %v", ErrInvalidDuration, err))
+	}
+	if dur <= 0 {
+		return tok, 0, semantic(tok, fmt.Errorf("%w: must be positive", ErrInvalidDuration))
+	}
+	return tok, dur, nil
+}
+
+// parseUnconsumedSource reads a source identifier that must be defined and not yet consumed.
+func (p *parser) parseUnconsumedSource(str *tokenStream) (token, error) {
+	src := str.next()
+	if src.Type != tIdentifier {
+		return src, unexpected(src, "source identifier")
+	}
+	if !p.sources[src.Text] {
+		return src, semantic(src, errUndefined(src.Text))
+	}
+	if p.consumed[src.Text] {
+		return src, semantic(src, errAlreadyConsumed(src.Text))
+	}
+	return src, nil
+}
+
+// parseSourceList parses two or more existing, unconsumed source identifiers separated by commas or "and", and marks them as consumed.
+func (p *parser) parseSourceList(str *tokenStream, node *ast) ([]string, error) {
+	var ids []string
+	for {
+		src, err := p.parseUnconsumedSource(str)
+		if err != nil {
+			return nil, err
+		}
+		p.consumed[src.Text] = true
+		ids = append(ids, src.Text)
+		node.appendSpace(src)
+
+		more, err := p.parseListSeparator(str, node, len(ids))
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return ids, nil
+		}
+	}
+}
+
+// parseTargetList parses two or more new identifiers separated by commas or "and", and defines them as sources.
+func (p *parser) parseTargetList(str *tokenStream, node *ast) ([]string, error) {
+	var ids []string
+	for {
+		id := str.next()
+		if id.Type != tIdentifier {
+			return nil, unexpected(id, "target identifier")
+		}
+		if p.sources[id.Text] {
+			return nil, semantic(id, errAlreadyDefined(id.Text))
+		}
+		p.sources[id.Text] = true
+		ids = append(ids, id.Text)
+		node.appendSpace(id)
+
+		more, err := p.parseListSeparator(str, node, len(ids))
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return ids, nil
+		}
+	}
+}
+
+// parseListSeparator consumes a comma or "and" between identifiers in a list, returning whether another identifier is expected.
+// A list must have at least two identifiers, so the separator is required after the first one.
+func (p *parser) parseListSeparator(str *tokenStream, node *ast, count int) (bool, error) {
+	sep := str.next()
+	switch sep.Type {
+	case tComma:
+		node.append(sep)
+		return true, nil
+	case tAnd:
+		node.appendSpace(sep)
+		return true, nil
+	}
+	if count < 2 {
+		return false, unexpected(sep, ",", "and")
+	}
+	str.pushBack(sep)
+	return false, nil
+}
+
+// fieldPath extends a field identifier with any directly adjacent ".name" segments, so nested field paths like http.status may be used wherever a field is expected.
+// Segments must not be separated by whitespace, and may be keywords or numbers, like request.from or items.0.
+func fieldPath(str *tokenStream, id token) token {
+	for {
+		dot := str.next()
+		if dot.Type != tDot || !adjacent(id, dot) {
+			str.pushBack(dot)
+			return id
+		}
+		seg := str.next()
+		if !adjacent(dot, seg) || !isPathSegment(seg) {
+			str.pushBack(seg, dot)
+			return id
+		}
+		id.Text += dot.Text + seg.Text
+	}
+}
+
+func adjacent(a, b token) bool {
+	return a.Line == b.Line && a.Pos+len([]rune(a.Text)) == b.Pos
+}
+
+func isPathSegment(t token) bool {
+	if t.Type == tIdentifier {
+		return true
+	}
+	if len(t.Text) == 0 {
+		return false
+	}
+	for _, r := range t.Text {
+		if !strings.ContainsRune(idRemainder, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFieldList reads a list of field identifiers in the form "fields(a, b, c)".
+func (p *parser) parseFieldList(str *tokenStream) ([]string, error) {
+	kw := str.next()
+	if kw.Type != tIdentifier || kw.Text != kwFields {
+		return nil, unexpected(kw, "fields")
+	}
+	return p.parseParenFields(str)
+}
+
+// parseParenFields reads a parenthesized list of field identifiers in the form "(a, b, c)".
+func (p *parser) parseParenFields(str *tokenStream) ([]string, error) {
+	lp := str.next()
+	if lp.Type != tLpar {
+		return nil, unexpected(lp, "(")
+	}
+
+	var fields []string
+	for {
+		id := str.next()
+		if id.Type != tIdentifier {
+			return nil, unexpected(id, "field identifier")
+		}
+		id = fieldPath(str, id)
+		fields = append(fields, id.Text)
+
+		commaParen := str.next()
+		if commaParen.Type == tRpar {
+			return fields, nil
+		}
+		if commaParen.Type != tComma {
+			return nil, unexpected(commaParen, ",", ")")
+		}
+	}
+}
@@ -2,8 +2,11 @@ package dsl
 
 import (
 	"encoding/json"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestParseString_ShortString(t *testing.T) {
@@ -89,3 +92,824 @@ sink a to std.Out`
 		}
 	}
 }
+
+func TestParseString_AsyncSink(t *testing.T) {
+	script := `source as a std.In
+source as b std.In
+sink a async as aSink to std.Out
+sink b to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 4)
+
+	async := nodes[2].(*Sink)
+	assert.Equal(t, ASYNC_SINK, async.Type())
+	assert.True(t, async.Async)
+	assert.Equal(t, "aSink", async.ID)
+
+	sync := nodes[3].(*Sink)
+	assert.Equal(t, SINK, sync.Type())
+	assert.False(t, sync.Async)
+}
+
+func TestParseString_Filter(t *testing.T) {
+	script := `source as a std.In
+filter a where @level == "error" or status >= 500
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	filter, ok := nodes[1].(*Filter)
+	require.True(t, ok, "Expected to parse Filter AstNode")
+	assert.Equal(t, FILTER, filter.Type())
+	assert.Equal(t, "a", filter.Source)
+	assert.True(t, Matches(filter.Expr, entries.LogEntry{"status": "503"}))
+	assert.False(t, Matches(filter.Expr, entries.LogEntry{"status": "200"}))
+}
+
+func TestParseString_FilterErrors(t *testing.T) {
+	tests := map[string]struct {
+		script   string
+		expected error
+	}{
+		"Undefined source": {
+			script:   `filter a where has b`,
+			expected: ErrUndefinedIdentifier,
+		},
+		"Missing where": {
+			script: `source as a std.In
+filter a has b`,
+			expected: ErrUnexpectedToken,
+		},
+		"Missing expression": {
+			script: `source as a std.In
+filter a where`,
+			expected: ErrUnexpectedToken,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseString(tc.script)
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestParseString_ContextualKeywords(t *testing.T) {
+	script := `source as in std.In
+filter in where route == "/api" and from == "x"
+keep in fields(route, from, drop)
+sink in to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, FILTER, KEEP, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	filter := nodes[1].(*Filter)
+	assert.Equal(t, "in", filter.Source)
+	assert.True(t, Matches(filter.Expr, entries.LogEntry{"route": "/api", "from": "x"}))
+	assert.False(t, Matches(filter.Expr, entries.LogEntry{"route": "/health", "from": "x"}))
+	keep := nodes[2].(*Keep)
+	assert.Equal(t, []string{"route", "from", "drop"}, keep.Fields)
+}
+
+func TestParseString_Transform(t *testing.T) {
+	script := `source as a std.In
+transform a set(latency_ms = latency_s * 1000, @level = lower(@level), url = host + path)
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	tr, ok := nodes[1].(*Transform)
+	require.True(t, ok, "Expected to parse Transform AstNode")
+	assert.Equal(t, TRANSFORM, tr.Type())
+	assert.Equal(t, "a", tr.Source)
+	require.Len(t, tr.Assignments, 3)
+	assert.Equal(t, "latency_ms", tr.Assignments[0].Field)
+	assert.Equal(t, "@level", tr.Assignments[1].Field)
+	assert.Equal(t, "url", tr.Assignments[2].Field)
+
+	_, err = ParseString(`source as a std.In
+transform a set(b = 1,)`)
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Projection(t *testing.T) {
+	script := `source as a std.In
+rename a set(0=@timestamp, 1=@level)
+drop a fields(@read_timestamp, @read_line_number)
+keep a fields(@timestamp, @level, @message)
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err, "Numbered fields should be able to be renamed")
+	assert.Equal(t, []*FieldRename{{From: "0", To: "@timestamp"}, {From: "1", To: "@level"}}, nodes[1].(*Rename).Renames)
+
+	script = `source as a std.In
+rename a set(ts=@timestamp, lvl=@level)
+drop a fields(@read_timestamp, @read_line_number)
+keep a fields(@timestamp, @level, @message)
+sink a to std.Out`
+	nodes, err = ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, RENAME, DROP, KEEP, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	rename := nodes[1].(*Rename)
+	assert.Equal(t, []*FieldRename{{From: "ts", To: "@timestamp"}, {From: "lvl", To: "@level"}}, rename.Renames)
+	drop := nodes[2].(*Drop)
+	assert.Equal(t, []string{"@read_timestamp", "@read_line_number"}, drop.Fields)
+	keep := nodes[3].(*Keep)
+	assert.Equal(t, []string{"@timestamp", "@level", "@message"}, keep.Fields)
+	assert.Equal(t, "keep a fields(@timestamp, @level, @message)", keep.Text())
+
+	nodes, err = ParseString("source as a std.In\nrename a set(a=b, b=a)")
+	require.NoError(t, err, "Fields should be able to be swapped")
+	assert.Equal(t, []*FieldRename{{From: "a", To: "b"}, {From: "b", To: "a"}}, nodes[1].(*Rename).Renames)
+	_, err = ParseString("source as a std.In\nrename a set(a=b, a=c)")
+	assert.ErrorIs(t, err, ErrDuplicateRename, "A field shouldn't be renamed twice")
+	_, err = ParseString("source as a std.In\nrename a set(a=c, b=c)")
+	assert.ErrorIs(t, err, ErrDuplicateRename, "Two fields shouldn't be renamed to the same target")
+}
+
+func TestParseString_FieldPaths(t *testing.T) {
+	script := `source as a std.In
+cut a set(request.method=0, tags[0]=1)
+transform a set(http.status = int(http.status), meta.k8s\.io = "x")
+rename a set(http.status=response.code)
+drop a fields(http.headers.cookie, tags[-1])
+keep a fields(response.code, request.from)
+parse a as logfmt from http.body
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 8)
+
+	assert.Equal(t, map[string]int{"request.method": 0, "tags[0]": 1}, nodes[1].(*Cut).FieldSets)
+	tr := nodes[2].(*Transform)
+	assert.Equal(t, "http.status", tr.Assignments[0].Field)
+	assert.Equal(t, "int(http.status)", tr.Assignments[0].Expr.String())
+	assert.Equal(t, `meta.k8s\.io`, tr.Assignments[1].Field)
+	assert.Equal(t, []*FieldRename{{From: "http.status", To: "response.code"}}, nodes[3].(*Rename).Renames)
+	assert.Equal(t, []string{"http.headers.cookie", "tags[-1]"}, nodes[4].(*Drop).Fields)
+	assert.Equal(t, []string{"response.code", "request.from"}, nodes[5].(*Keep).Fields)
+	assert.Equal(t, "http.body", nodes[6].(*Parse).Field)
+
+	_, err = ParseString("source as a std.In\nkeep a fields(http. status)")
+	assert.ErrorIs(t, err, ErrUnexpectedToken, "Path segments must not be separated by whitespace")
+	_, err = ParseString("source as a std.In\nkeep a fields(tags[0)")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Parse(t *testing.T) {
+	script := `source as a std.In
+parse a as logfmt
+parse a as csv "ts,level,msg", "|" from raw
+parse a as regex "^(?P<method>[A-Z]+) (?P<path>\\S+)$" from request
+parse a as grok "%{TIMESTAMP_ISO8601:@timestamp} %{LOGLEVEL:@level} %{INT:status:int}"
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, PARSE, PARSE, PARSE, PARSE, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	logfmt := nodes[1].(*Parse)
+	assert.Equal(t, "logfmt", logfmt.Format)
+	assert.Empty(t, logfmt.Args)
+	assert.Equal(t, entries.StandardMessageField, logfmt.Field)
+
+	csv := nodes[2].(*Parse)
+	assert.Equal(t, "csv", csv.Format)
+	assert.Equal(t, []string{"ts,level,msg", "|"}, csv.Args)
+	assert.Equal(t, "raw", csv.Field)
+	assert.Equal(t, `parse a as csv "ts,level,msg", "|" from raw`, csv.Text())
+
+	regex := nodes[3].(*Parse)
+	assert.Equal(t, []string{`^(?P<method>[A-Z]+) (?P<path>\S+)$`}, regex.Args)
+	assert.Equal(t, "request", regex.Field)
+
+	grok := nodes[4].(*Parse)
+	assert.Equal(t, "grok", grok.Format)
+	assert.Equal(t, []string{"%{TIMESTAMP_ISO8601:@timestamp} %{LOGLEVEL:@level} %{INT:status:int}"}, grok.Args)
+}
+
+func TestParseString_ParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		script   string
+		expected error
+	}{
+		"Unknown format": {
+			script:   "source as a std.In\nparse a as nope",
+			expected: entries.ErrUnknownParser,
+		},
+		"Invalid args": {
+			script:   "source as a std.In\nparse a as regex \"(\"",
+			expected: entries.ErrParserArgs,
+		},
+		"Extra access log args": {
+			script:   "source as a std.In\nparse a as combined \"extra\"",
+			expected: entries.ErrParserArgs,
+		},
+		"Unknown grok pattern": {
+			script:   "source as a std.In\nparse a as grok \"%{NOPE:a}\"",
+			expected: entries.ErrParserArgs,
+		},
+		"Missing format": {
+			script:   "source as a std.In\nparse a as \"csv\"",
+			expected: ErrUnexpectedToken,
+		},
+		"Trailing comma": {
+			script:   "source as a std.In\nparse a as csv \"a,b\",",
+			expected: ErrUnexpectedToken,
+		},
+		"Undefined source": {
+			script:   "parse a as logfmt",
+			expected: ErrUndefinedIdentifier,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseString(tc.script)
+			assert.ErrorIs(t, err, tc.expected)
+		})
+	}
+}
+
+func TestParseString_NormalizeTimestamp(t *testing.T) {
+	script := `source as a std.In
+normalize a timestamp
+normalize a timestamp fields(ts, time) with "2006/01/02 15:04:05", "Jan _2 15:04:05" in "America/Chicago"
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, NORMALIZE_TIMESTAMP, NORMALIZE_TIMESTAMP, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	defaults := nodes[1].(*NormalizeTimestamp)
+	assert.Empty(t, defaults.Fields)
+	assert.Empty(t, defaults.Layouts)
+	assert.Empty(t, defaults.Zone)
+
+	full := nodes[2].(*NormalizeTimestamp)
+	assert.Equal(t, []string{"ts", "time"}, full.Fields)
+	assert.Equal(t, []string{"2006/01/02 15:04:05", "Jan _2 15:04:05"}, full.Layouts)
+	assert.Equal(t, "America/Chicago", full.Zone)
+	assert.Equal(t, `normalize a timestamp fields(ts, time) with "2006/01/02 15:04:05", "Jan _2 15:04:05" in "America/Chicago"`, full.Text())
+
+	_, err = ParseString("source as a std.In\nnormalize a timestamp in \"Not/AZone\"")
+	assert.ErrorContains(t, err, "line 2 position 26")
+	_, err = ParseString("source as a std.In\nnormalize a nothing")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_NormalizeLevel(t *testing.T) {
+	script := `source as a std.In
+normalize a level
+normalize a level fields(lvl, severity) with "pino", "syslog" set("VERBOSE"=trace, 99=fatal, custom=notice)
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, NORMALIZE_LEVEL, NORMALIZE_LEVEL, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	defaults := nodes[1].(*NormalizeLevel)
+	assert.Empty(t, defaults.Fields)
+	assert.Empty(t, defaults.Mappings)
+	assert.Empty(t, defaults.Custom)
+
+	full := nodes[2].(*NormalizeLevel)
+	assert.Equal(t, []string{"lvl", "severity"}, full.Fields)
+	assert.Equal(t, []string{"pino", "syslog"}, full.Mappings)
+	assert.Equal(t, map[string]string{"VERBOSE": "trace", "99": "fatal", "custom": "notice"}, full.Custom)
+	assert.Equal(t, `normalize a level fields(lvl, severity) with "pino", "syslog" set("VERBOSE"=trace, 99=fatal, custom=notice)`, full.Text())
+
+	_, err = ParseString("source as a std.In\nnormalize a level with \"nope\"")
+	assert.ErrorIs(t, err, ErrUnknownLevelMapping)
+	_, err = ParseString("source as a std.In\nnormalize a level set(x=loud)")
+	assert.ErrorIs(t, err, ErrUnknownLevel)
+	assert.ErrorContains(t, err, "line 2 position 25")
+}
+
+func TestParseString_Flatten(t *testing.T) {
+	script := `source as a std.In
+flatten a
+flatten a with "_" arrays
+unflatten a with "__"
+explode a request.items
+explode a items index item.index
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, FLATTEN, FLATTEN, UNFLATTEN, EXPLODE, EXPLODE, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	defaults := nodes[1].(*Flatten)
+	assert.Empty(t, defaults.Separator)
+	assert.False(t, defaults.Arrays)
+	full := nodes[2].(*Flatten)
+	assert.Equal(t, "_", full.Separator)
+	assert.True(t, full.Arrays)
+	assert.Equal(t, `flatten a with "_" arrays`, full.Text())
+	unflatten := nodes[3].(*Unflatten)
+	assert.Equal(t, "__", unflatten.Separator)
+	assert.False(t, unflatten.Arrays)
+	assert.Equal(t, "request.items", nodes[4].(*Explode).Field)
+	assert.Empty(t, nodes[4].(*Explode).IndexField)
+	explode := nodes[5].(*Explode)
+	assert.Equal(t, "items", explode.Field)
+	assert.Equal(t, "item.index", explode.IndexField)
+	assert.Equal(t, "explode a items index item.index", explode.Text())
+
+	_, err = ParseString("source as a std.In\nflatten a with \"\"")
+	assert.ErrorIs(t, err, ErrEmptySeparator)
+	_, err = ParseString("source as a std.In\nflatten a nested")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	_, err = ParseString("source as a std.In\nexplode a")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	_, err = ParseString("source as a std.In\nexplode a items index")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Redact(t *testing.T) {
+	script := `source as a std.In
+redact a
+redact a fields(user, http.client) with "email", "ipv4" match "password=(\\S+)" as partial
+redact a as hash "secret"
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, REDACT, REDACT, REDACT, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	defaults := nodes[1].(*Redact)
+	assert.Empty(t, defaults.Fields)
+	assert.Empty(t, defaults.Detectors)
+	assert.Equal(t, "full", defaults.Mode)
+	full := nodes[2].(*Redact)
+	assert.Equal(t, []string{"user", "http.client"}, full.Fields)
+	assert.Equal(t, []string{"email", "ipv4"}, full.Detectors)
+	assert.Equal(t, []string{`password=(\S+)`}, full.Patterns)
+	assert.Equal(t, "partial", full.Mode)
+	assert.Equal(t, `redact a fields(user, http.client) with "email", "ipv4" match "password=(\\S+)" as partial`, full.Text())
+	hash := nodes[3].(*Redact)
+	assert.Equal(t, "hash", hash.Mode)
+	assert.Equal(t, "secret", hash.Key)
+
+	_, err = ParseString("source as a std.In\nredact a with \"phone\"")
+	assert.ErrorIs(t, err, ErrUnknownDetector)
+	assert.ErrorContains(t, err, "line 2 position 15")
+	_, err = ParseString("source as a std.In\nredact a match \"(\"")
+	assert.ErrorIs(t, err, entries.ErrInvalidDetector)
+	_, err = ParseString("source as a std.In\nredact a as blur")
+	assert.ErrorIs(t, err, ErrUnknownMaskMode)
+	_, err = ParseString("source as a std.In\nredact a as hash")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	_, err = ParseString("source as a std.In\nredact a as hash \"\"")
+	assert.ErrorIs(t, err, entries.ErrMissingRedactKey)
+}
+
+func TestParseString_Cluster(t *testing.T) {
+	script := `source as a std.In
+cluster a
+cluster a from http.body depth 5 similarity 0.6 summary
+sink a to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, CLUSTER, CLUSTER, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	defaults := nodes[1].(*Cluster)
+	assert.Empty(t, defaults.Field)
+	assert.Zero(t, defaults.Depth)
+	assert.Zero(t, defaults.Similarity)
+	assert.False(t, defaults.Summary)
+	full := nodes[2].(*Cluster)
+	assert.Equal(t, "http.body", full.Field)
+	assert.Equal(t, 5, full.Depth)
+	assert.Equal(t, 0.6, full.Similarity)
+	assert.True(t, full.Summary)
+	assert.Equal(t, "cluster a from http.body depth 5 similarity 0.6 summary", full.Text())
+
+	_, err = ParseString("source as a std.In\ncluster a depth 2")
+	assert.ErrorIs(t, err, ErrInvalidClusterOpt)
+	assert.ErrorContains(t, err, "line 2 position 17")
+	_, err = ParseString("source as a std.In\ncluster a similarity 1.5")
+	assert.ErrorIs(t, err, ErrInvalidClusterOpt)
+	_, err = ParseString("source as a std.In\ncluster a summary depth 4")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Lists(t *testing.T) {
+	script := `source as a std.In
+source as b std.In
+source as c std.In
+merge a, b, c as all
+dupe all as x, y, z
+fanout x as p and q
+merge p and q as pq`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, SOURCE, SOURCE, MERGE, DUPE, FANOUT, MERGE}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	merge := nodes[3].(*Merge)
+	assert.Equal(t, []string{"a", "b", "c"}, merge.Sources)
+	assert.Equal(t, "all", merge.ID)
+	assert.Equal(t, "merge a, b, c as all", merge.Text())
+	dupe := nodes[4].(*Dupe)
+	assert.Equal(t, "all", dupe.Source)
+	assert.Equal(t, []string{"x", "y", "z"}, dupe.Targets)
+	assert.Equal(t, "dupe all as x, y, z", dupe.Text())
+	fanout := nodes[5].(*Fanout)
+	assert.Equal(t, []string{"p", "q"}, fanout.Targets)
+	assert.Equal(t, []string{"p", "q"}, nodes[6].(*Merge).Sources)
+
+	_, err = ParseString("source as a std.In\nmerge a as b")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	assert.ErrorContains(t, err, "line 2 position 9")
+	_, err = ParseString("source as a std.In\nsource as b std.In\nmerge a, b, a as c")
+	assert.ErrorIs(t, err, ErrAlreadyConsumed)
+	_, err = ParseString("source as a std.In\ndupe a as x, y, x")
+	assert.ErrorIs(t, err, ErrAlreadyDefined)
+	_, err = ParseString("source as a std.In\nfanout a as x,")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_MergeOrdered(t *testing.T) {
+	script := `source as a std.In
+source as b std.In
+source as c std.In
+source as d std.In
+source as e std.In
+source as f std.In
+merge a, b as ab ordered
+merge c and d as cd ordered by event.time within "1m30s"
+merge e, f as ef ordered sorted`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 9)
+
+	strict := nodes[6].(*Merge)
+	assert.True(t, strict.Ordered)
+	assert.Empty(t, strict.OrderField)
+	assert.Zero(t, strict.Tolerance)
+	assert.False(t, strict.Sorted)
+	watermark := nodes[7].(*Merge)
+	assert.Equal(t, "event.time", watermark.OrderField)
+	assert.Equal(t, 90*time.Second, watermark.Tolerance)
+	assert.Equal(t, `merge c and d as cd ordered by event.time within "1m30s"`, watermark.Text())
+	assert.True(t, nodes[8].(*Merge).Sorted)
+
+	_, err = ParseString("source as a std.In\nsource as b std.In\nmerge a, b as ab ordered within \"soon\"")
+	assert.ErrorIs(t, err, ErrInvalidDuration)
+	assert.ErrorContains(t, err, "line 3 position 33")
+	_, err = ParseString("source as a std.In\nsource as b std.In\nmerge a, b as ab ordered within 5")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Route(t *testing.T) {
+	script := `source as a std.In
+route a to errors where @level == "error", access where tagged "access" default other
+source as b std.In
+route b all to slow where duration > 1000, big where bytes > 1000000
+sink errors to std.Out`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	expectedTypes := []AstType{SOURCE, ROUTE, SOURCE, ROUTE, SINK}
+	require.Len(t, nodes, len(expectedTypes))
+	for i, n := range nodes {
+		assert.Equal(t, expectedTypes[i], n.Type())
+	}
+
+	first := nodes[1].(*Route)
+	assert.Equal(t, "a", first.Source)
+	assert.False(t, first.All)
+	require.Len(t, first.Branches, 2)
+	assert.Equal(t, "errors", first.Branches[0].ID)
+	assert.Equal(t, "access", first.Branches[1].ID)
+	assert.True(t, Matches(first.Branches[0].Expr, entries.LogEntry{"@level": "error"}))
+	assert.True(t, Matches(first.Branches[1].Expr, entries.LogEntry{"@tag": "access"}))
+	assert.Equal(t, "other", first.Default)
+	all := nodes[3].(*Route)
+	assert.True(t, all.All)
+	assert.Len(t, all.Branches, 2)
+	assert.Empty(t, all.Default)
+
+	_, err = ParseString("source as a std.In\nroute a to x where true\nroute a to y where true")
+	assert.ErrorIs(t, err, ErrAlreadyConsumed)
+	_, err = ParseString("source as a std.In\nsource as b std.In\nroute a to b where true")
+	assert.ErrorIs(t, err, ErrAlreadyDefined)
+	assert.ErrorContains(t, err, "line 3 position 12")
+	_, err = ParseString("source as a std.In\nroute a to x where true, x where false")
+	assert.ErrorIs(t, err, ErrAlreadyDefined)
+	_, err = ParseString("source as a std.In\nroute a x where true")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_FanoutStrategy(t *testing.T) {
+	tests := map[string]struct {
+		script   string
+		strategy string
+		field    string
+	}{
+		"Default": {
+			script: "fanout a as b, c",
+		},
+		"Hash": {
+			script:   "fanout a as b, c by service.name",
+			strategy: FanoutHash,
+			field:    "service.name",
+		},
+		"Round robin": {
+			script:   "fanout a as b and c roundrobin",
+			strategy: FanoutRoundRobin,
+		},
+		"Least loaded": {
+			script:   "fanout a as b, c leastloaded",
+			strategy: FanoutLeastLoaded,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			nodes, err := ParseString("source as a std.In\n" + tc.script)
+			require.NoError(t, err)
+			require.Len(t, nodes, 2)
+			fanout := nodes[1].(*Fanout)
+			assert.Equal(t, []string{"b", "c"}, fanout.Targets)
+			assert.Equal(t, tc.strategy, fanout.Strategy)
+			assert.Equal(t, tc.field, fanout.Field)
+		})
+	}
+
+	_, err := ParseString("source as a std.In\nfanout a as b, c by")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	_, err = ParseString("source as a std.In\nfanout a as b, c randomly")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
+
+func TestParseString_Parallel(t *testing.T) {
+	nodes, err := ParseString("source as a std.In\nparallel a with 4\ncut a set(x=0)")
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	par := nodes[1].(*Parallel)
+	assert.Equal(t, PARALLEL, par.Type())
+	assert.Equal(t, "a", par.Source)
+	assert.Equal(t, 4, par.Workers)
+	assert.Equal(t, "parallel a with 4", par.Text())
+
+	_, err = ParseString("source as a std.In\nparallel a with 0")
+	assert.ErrorIs(t, err, ErrInvalidWorkers)
+	_, err = ParseString("source as a std.In\nparallel a with \"4\"")
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+	_, err = ParseString("parallel a with 4")
+	assert.ErrorIs(t, err, ErrUndefinedIdentifier)
+}
+
+func TestParseString_Aggregate(t *testing.T) {
+	script := `source as a std.In
+aggregate a as per_minute count, p95(latency), avg(latency) as avg_latency, percentile(bytes, 99.9) by service, http.method every "1m"
+source as b std.In
+aggregate b as recent distinct(user) every "5m" slide "1m" on event.time late "30s"
+source as c std.In
+aggregate c as live count every "10s" on arrival`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 6)
+
+	first := nodes[1].(*Aggregate)
+	assert.Equal(t, AGGREGATE, first.Type())
+	assert.Equal(t, "a", first.Source)
+	assert.Equal(t, "per_minute", first.ID)
+	assert.Equal(t, []*AggregateValue{
+		{Func: "count"},
+		{Func: "percentile", Field: "latency", Percentile: 95},
+		{Func: "avg", Field: "latency", As: "avg_latency"},
+		{Func: "percentile", Field: "bytes", Percentile: 99.9},
+	}, first.Values)
+	assert.Equal(t, []string{"service", "http.method"}, first.GroupBy)
+	assert.Equal(t, time.Minute, first.Window)
+	assert.Zero(t, first.Slide)
+	assert.Equal(t, `aggregate a as per_minute count, p95(latency), avg(latency) as avg_latency, percentile(bytes, 99.9) by service, http.method every "1m"`, first.Text())
+
+	sliding := nodes[3].(*Aggregate)
+	assert.Equal(t, 5*time.Minute, sliding.Window)
+	assert.Equal(t, time.Minute, sliding.Slide)
+	assert.Equal(t, "event.time", sliding.TimeField)
+	assert.Equal(t, 30*time.Second, sliding.Lateness)
+	assert.False(t, sliding.Arrival)
+	assert.True(t, nodes[5].(*Aggregate).Arrival)
+
+	tests := map[string]error{
+		`aggregate a as x median(latency) every "1m"`:     ErrInvalidAggregate,
+		`aggregate a as x p101(latency) every "1m"`:       ErrInvalidAggregate,
+		`aggregate a as x pnan(latency) every "1m"`:       ErrInvalidAggregate,
+		`aggregate a as x sum every "1m"`:                 ErrUnexpectedToken,
+		`aggregate a as x percentile(latency) every "1m"`: ErrUnexpectedToken,
+		`aggregate a as x count by service`:               ErrUnexpectedToken,
+		`aggregate a as x count every "1m" slide "2m"`:    ErrInvalidDuration,
+		`aggregate a as a count every "1m"`:               ErrAlreadyDefined,
+		`aggregate b as x count every "1m"`:               ErrUndefinedIdentifier,
+	}
+	for script, expected := range tests {
+		_, err := ParseString("source as a std.In\n" + script)
+		assert.ErrorIs(t, err, expected, script)
+	}
+}
+
+func TestParseString_Dedup(t *testing.T) {
+	script := `source as a std.In
+dedup a
+dedup a fields(@message, service) within "30s" limit 100
+dedup a ignore(@timestamp, host)
+dedup a limit 5`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 5)
+
+	plain := nodes[1].(*Dedup)
+	assert.Equal(t, DEDUP, plain.Type())
+	assert.Equal(t, "a", plain.Source)
+	assert.Empty(t, plain.Fields)
+	assert.Zero(t, plain.Window)
+	assert.Zero(t, plain.Limit)
+
+	fields := nodes[2].(*Dedup)
+	assert.Equal(t, []string{"@message", "service"}, fields.Fields)
+	assert.Equal(t, 30*time.Second, fields.Window)
+	assert.Equal(t, 100, fields.Limit)
+	assert.Equal(t, `dedup a fields(@message, service) within "30s" limit 100`, fields.Text())
+
+	ignore := nodes[3].(*Dedup)
+	assert.Equal(t, []string{"@timestamp", "host"}, ignore.Ignore)
+	assert.Equal(t, `dedup a ignore(@timestamp, host)`, ignore.Text())
+	assert.Equal(t, 5, nodes[4].(*Dedup).Limit)
+
+	tests := map[string]error{
+		`dedup a limit 0`:        ErrInvalidDedupLimit,
+		`dedup a within "0s"`:    ErrInvalidDuration,
+		`dedup a within 30`:      ErrUnexpectedToken,
+		`dedup a fields()`:       ErrUnexpectedToken,
+		`dedup a limit 5 within`: ErrUnexpectedToken,
+		`dedup b`:                ErrUndefinedIdentifier,
+	}
+	for script, expected := range tests {
+		_, err := ParseString("source as a std.In\n" + script)
+		assert.ErrorIs(t, err, expected, script)
+	}
+}
+
+func TestParseString_Join(t *testing.T) {
+	script := `source as a std.In
+join a with "^\\d{4}-", "^\\["
+join a with continuation "^\\s+at " by thread idle "5s" max 200 lines max 65536 bytes`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+
+	start := nodes[1].(*Join)
+	assert.Equal(t, JOIN, start.Type())
+	assert.Equal(t, []string{`^\d{4}-`, `^\[`}, start.Patterns)
+	assert.False(t, start.Continuation)
+	assert.Empty(t, start.GroupBy)
+	assert.Zero(t, start.Idle)
+
+	cont := nodes[2].(*Join)
+	assert.Equal(t, []string{`^\s+at `}, cont.Patterns)
+	assert.True(t, cont.Continuation)
+	assert.Equal(t, "thread", cont.GroupBy)
+	assert.Equal(t, 5*time.Second, cont.Idle)
+	assert.Equal(t, 200, cont.MaxLines)
+	assert.Equal(t, 65536, cont.MaxBytes)
+
+	tests := map[string]error{
+		`join a with "^x" max 0 lines`:             ErrInvalidJoinLimit,
+		`join a with "^x" max 5`:                   ErrUnexpectedToken,
+		`join a with "^x" max 5 lines max 5 lines`: ErrUnexpectedToken,
+		`join a with "^x" idle "soon"`:             ErrInvalidDuration,
+		`join a with "^x" by`:                      ErrUnexpectedToken,
+		`join a with continuation`:                 ErrUnexpectedToken,
+	}
+	for script, expected := range tests {
+		_, err := ParseString("source as a std.In\n" + script)
+		assert.ErrorIs(t, err, expected, script)
+	}
+}
+
+func TestParseString_Correlate(t *testing.T) {
+	script := `source as a std.In
+correlate a as requests by request_id on event.time timeout "2m" max 500 end where @message == "request completed"
+source as b std.In
+correlate b as traces by trace.id`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 4)
+
+	first := nodes[1].(*Correlate)
+	assert.Equal(t, CORRELATE, first.Type())
+	assert.Equal(t, "a", first.Source)
+	assert.Equal(t, "requests", first.ID)
+	assert.Equal(t, "request_id", first.Key)
+	assert.Equal(t, "event.time", first.TimeField)
+	assert.Equal(t, 2*time.Minute, first.Timeout)
+	assert.Equal(t, 500, first.MaxSize)
+	require.NotNil(t, first.End)
+	assert.True(t, Matches(first.End, map[string]any{"@message": "request completed"}))
+
+	second := nodes[3].(*Correlate)
+	assert.Equal(t, "trace.id", second.Key)
+	assert.Zero(t, second.Timeout)
+	assert.Zero(t, second.MaxSize)
+	assert.Nil(t, second.End)
+
+	tests := map[string]error{
+		`correlate a as x`:                   ErrUnexpectedToken,
+		`correlate a as x by id max 0`:       ErrInvalidGroupSize,
+		`correlate a as x by id timeout "0"`: ErrInvalidDuration,
+		`correlate a as x by id end`:         ErrUnexpectedToken,
+		`correlate a as a by id`:             ErrAlreadyDefined,
+		`correlate b as x by id`:             ErrUndefinedIdentifier,
+	}
+	for script, expected := range tests {
+		_, err := ParseString("source as a std.In\n" + script)
+		assert.ErrorIs(t, err, expected, script)
+	}
+}
+
+func TestParseString_Pair(t *testing.T) {
+	script := `source as proxy std.In
+source as app std.In
+pair proxy, app as enriched on request_id within "30s"
+source as a std.In
+source as b std.In
+pair a and b as c on http.request_id = req within "1m" full prefix "proxy.", "app."`
+	nodes, err := ParseString(script)
+	require.NoError(t, err)
+	require.Len(t, nodes, 6)
+
+	first := nodes[2].(*Pair)
+	assert.Equal(t, PAIR, first.Type())
+	assert.Equal(t, "proxy", first.Left)
+	assert.Equal(t, "app", first.Right)
+	assert.Equal(t, "enriched", first.ID)
+	assert.Equal(t, "request_id", first.Key)
+	assert.Empty(t, first.RightKey)
+	assert.Equal(t, 30*time.Second, first.Window)
+	assert.Equal(t, PairInner, first.Mode)
+	assert.False(t, first.Prefixed)
+
+	second := nodes[5].(*Pair)
+	assert.Equal(t, "http.request_id", second.Key)
+	assert.Equal(t, "req", second.RightKey)
+	assert.Equal(t, time.Minute, second.Window)
+	assert.Equal(t, PairFull, second.Mode)
+	assert.True(t, second.Prefixed)
+	assert.Equal(t, "proxy.", second.LeftPrefix)
+	assert.Equal(t, "app.", second.RightPrefix)
+	assert.Equal(t, `pair a and b as c on http.request_id = req within "1m" full prefix "proxy.", "app."`, second.Text())
+
+	tests := map[string]error{
+		`pair a, b as c on id`:                             ErrUnexpectedToken,
+		`pair a, b as c within "1m"`:                       ErrUnexpectedToken,
+		`pair a, b as c on id within "1m" outer`:           ErrUnexpectedToken,
+		`pair a, b as c on id within "1m" prefix "x", "x"`: ErrInvalidPairPrefix,
+		`pair a, a as c on id within "1m"`:                 ErrAlreadyConsumed,
+		`pair a, b as a on id within "1m"`:                 ErrAlreadyDefined,
+		`pair a, x as c on id within "1m"`:                 ErrUndefinedIdentifier,
+	}
+	for script, expected := range tests {
+		_, err := ParseString("source as a std.In\nsource as b std.In\n" + script)
+		assert.ErrorIs(t, err, expected, script)
+	}
+}
@@ -0,0 +1,207 @@
+package dsl
+
+import (
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	operandField = "operand"
+)
+
+// operand wraps an evaluated value in an entries.LogEntry, so expression values are coerced with the same rules as LogEntry fields.
+func operand(val any) entries.LogEntry {
+	return entries.LogEntry{operandField: val}
+}
+
+// Truthy determines whether an evaluated expression value should be considered true.
+// Nil, false, zero numbers, and empty strings are not truthy. All other values are truthy.
+func Truthy(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return len(v) > 0
+	}
+	if f, ok := toNumber(val); ok {
+		return f != 0
+	}
+	return true
+}
+
+func toString(val any) (string, bool) {
+	if val == nil {
+		return "", false
+	}
+	return operand(val).AsString(operandField)
+}
+
+func toNumber(val any) (float64, bool) {
+	if val == nil {
+		return 0, false
+	}
+	e := operand(val)
+	if f, ok := e.AsFloat(operandField); ok {
+		return f, true
+	}
+	if i, ok := e.AsInt(operandField); ok {
+		return float64(i), true
+	}
+	if u, ok := e.AsUint(operandField); ok {
+		return float64(u), true
+	}
+	return 0, false
+}
+
+func toTime(val any) (time.Time, bool) {
+	if val == nil {
+		return time.Time{}, false
+	}
+	return operand(val).AsTime(operandField)
+}
+
+// compare returns -1, 0, or 1 if a is less than, equal to, or greater than b.
+// The returned bool will be false if either value is nil.
+// If either value is an entries.Level, then both are compared as levels by severity.
+func compare(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	_, aLevel := a.(entries.Level)
+	_, bLevel := b.(entries.Level)
+	if aLevel || bLevel {
+		return compareLevels(a, b)
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			switch {
+			case x.Before(y):
+				return -1, true
+			case x.After(y):
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	x, _ := toString(a)
+	y, _ := toString(b)
+	return strings.Compare(x, y), true
+}
+
+// compareLevels is like compare, but both values are interpreted with entries.ParseLevel and compared by severity.
+// The returned bool will be false if either value isn't a level.
+func compareLevels(a, b any) (int, bool) {
+	x, xok := entries.ParseLevel(a)
+	y, yok := entries.ParseLevel(b)
+	if !xok || !yok {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// isLevelOperand reports whether an operand of a comparison references entries.StandardLevelField, so it should be compared by severity.
+func isLevelOperand(expr Expr) bool {
+	field, ok := expr.(*FieldExpr)
+	return ok && field.Field == entries.StandardLevelField
+}
+
+func toInt(val any) (int64, bool) {
+	if val == nil {
+		return 0, false
+	}
+	e := operand(val)
+	if i, ok := e.AsInt(operandField); ok {
+		return i, true
+	}
+	if u, ok := e.AsUint(operandField); ok && u <= math.MaxInt64 {
+		return int64(u), true
+	}
+	return 0, false
+}
+
+// arith applies an arithmetic operator to two values.
+// Integer math is used when both operands are integers, otherwise floating point math is used.
+// A nil value is returned if the operation is not possible, such as when an operand is not numeric, or when dividing by zero.
+func arith(op string, a, b any) any {
+	if a == nil || b == nil {
+		return nil
+	}
+	if x, ok := toInt(a); ok {
+		if y, ok := toInt(b); ok {
+			switch op {
+			case "+":
+				return x + y
+			case "-":
+				return x - y
+			case "*":
+				return x * y
+			case "/":
+				if y == 0 {
+					return nil
+				}
+				if x%y == 0 {
+					return x / y
+				}
+				return float64(x) / float64(y)
+			case "%":
+				if y == 0 {
+					return nil
+				}
+				return x % y
+			}
+		}
+	}
+	x, xok := toNumber(a)
+	y, yok := toNumber(b)
+	if !xok || !yok {
+		if op == "+" {
+			xs, _ := toString(a)
+			ys, _ := toString(b)
+			return xs + ys
+		}
+		return nil
+	}
+	switch op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return nil
+		}
+		return x / y
+	case "%":
+		if y == 0 {
+			return nil
+		}
+		return math.Mod(x, y)
+	}
+	return nil
+}
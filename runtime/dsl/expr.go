@@ -0,0 +1,515 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidMatchPattern = errors.New("invalid match pattern")
+	ErrUnknownFunction     = errors.New("unknown function")
+	ErrInvalidArgCount     = errors.New("invalid argument count")
+)
+
+// Expr is a node of a parsed expression that may be evaluated against an entries.LogEntry.
+type Expr interface {
+	// Eval evaluates the expression for the given entry.
+	// A nil result means that the expression has no value for this entry, like when a referenced field doesn't exist.
+	Eval(entry entries.LogEntry) any
+	// String returns the expression in DSL form.
+	String() string
+}
+
+// ParseExpr parses a single expression, making it possible to use the expression language without a full script.
+func ParseExpr(s string) (Expr, error) {
+	p := newParser(lexString(s))
+	str := p.l.stream()
+	go func() {
+		p.l.lex()
+	}()
+	expr, err := p.parseExpr(str)
+	if err != nil {
+		consumeTokens(p.l.tokens)
+		return nil, err
+	}
+	if t := str.next(); t.Type != tEof {
+		consumeTokens(p.l.tokens)
+		return nil, unexpected(t, "end of expression")
+	}
+	return expr, nil
+}
+
+// Matches returns whether the expression evaluates to a truthy value for the given entry.
+func Matches(expr Expr, entry entries.LogEntry) bool {
+	return Truthy(expr.Eval(entry))
+}
+
+// LiteralExpr is a constant string, number, or boolean value.
+type LiteralExpr struct {
+	Value any    `json:"value"`
+	Raw   string `json:"raw"`
+}
+
+func (e *LiteralExpr) Eval(entries.LogEntry) any {
+	return e.Value
+}
+
+func (e *LiteralExpr) String() string {
+	return e.Raw
+}
+
+// FieldExpr references the value of a field in the entry, which may be a nested field path like http.status.
+type FieldExpr struct {
+	Field string `json:"field"`
+}
+
+func (e *FieldExpr) Eval(entry entries.LogEntry) any {
+	val, _ := entry.Get(e.Field)
+	return val
+}
+
+func (e *FieldExpr) String() string {
+	return e.Field
+}
+
+// HasExpr evaluates to true if the entry has the referenced field.
+type HasExpr struct {
+	Field string `json:"field"`
+}
+
+func (e *HasExpr) Eval(entry entries.LogEntry) any {
+	return entry.HasField(e.Field)
+}
+
+func (e *HasExpr) String() string {
+	return "has " + e.Field
+}
+
+// TaggedExpr evaluates to true if the entry has been tagged with Tag, as determined by entries.LogEntry.HasTag.
+type TaggedExpr struct {
+	Tag string `json:"tag"`
+}
+
+func (e *TaggedExpr) Eval(entry entries.LogEntry) any {
+	return entry.HasTag(e.Tag)
+}
+
+func (e *TaggedExpr) String() string {
+	return "tagged " + strconv.Quote(e.Tag)
+}
+
+// NotExpr negates the truthiness of its operand.
+type NotExpr struct {
+	Operand Expr `json:"operand"`
+}
+
+func (e *NotExpr) Eval(entry entries.LogEntry) any {
+	return !Truthy(e.Operand.Eval(entry))
+}
+
+func (e *NotExpr) String() string {
+	return "not " + e.Operand.String()
+}
+
+// LogicalExpr is a short-circuiting "and" or "or" of two operands.
+type LogicalExpr struct {
+	Op    string `json:"op"`
+	Left  Expr   `json:"left"`
+	Right Expr   `json:"right"`
+}
+
+func (e *LogicalExpr) Eval(entry entries.LogEntry) any {
+	left := Truthy(e.Left.Eval(entry))
+	if e.Op == "or" {
+		return left || Truthy(e.Right.Eval(entry))
+	}
+	return left && Truthy(e.Right.Eval(entry))
+}
+
+func (e *LogicalExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+// CompareExpr compares two operands.
+// Operands are compared by severity if either is the @level field or an entries.Level and both are levels, numerically if both can be coerced to a number,
+// chronologically if both can be coerced to a time, and as strings otherwise.
+type CompareExpr struct {
+	Op    string `json:"op"`
+	Left  Expr   `json:"left"`
+	Right Expr   `json:"right"`
+}
+
+func (e *CompareExpr) Eval(entry entries.LogEntry) any {
+	var (
+		left, right = e.Left.Eval(entry), e.Right.Eval(entry)
+		c           int
+		ok          bool
+	)
+	if isLevelOperand(e.Left) || isLevelOperand(e.Right) {
+		c, ok = compareLevels(left, right)
+	}
+	if !ok {
+		c, ok = compare(left, right)
+	}
+	if !ok {
+		return e.Op == "!="
+	}
+	switch e.Op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func (e *CompareExpr) String() string {
+	return fmt.Sprintf("%s %s %s", e.Left, e.Op, e.Right)
+}
+
+// MatchExpr matches the string value of its operand against a regular expression.
+type MatchExpr struct {
+	Negate  bool   `json:"negate"`
+	Operand Expr   `json:"operand"`
+	Pattern string `json:"pattern"`
+	regex   *regexp.Regexp
+}
+
+func (e *MatchExpr) Eval(entry entries.LogEntry) any {
+	s, ok := toString(e.Operand.Eval(entry))
+	if !ok {
+		return e.Negate
+	}
+	return e.regex.MatchString(s) != e.Negate
+}
+
+func (e *MatchExpr) String() string {
+	op := "=~"
+	if e.Negate {
+		op = "!~"
+	}
+	return fmt.Sprintf("%s %s %s", e.Operand, op, strconv.Quote(e.Pattern))
+}
+
+// ArithExpr is an arithmetic operation on two operands.
+// Operands are coerced to numbers, and the result will be an int64 if both operands are integers, or a float64 otherwise.
+// The "+" operator will concatenate the operands as strings if either of them is not numeric.
+type ArithExpr struct {
+	Op    string `json:"op"`
+	Left  Expr   `json:"left"`
+	Right Expr   `json:"right"`
+}
+
+func (e *ArithExpr) Eval(entry entries.LogEntry) any {
+	return arith(e.Op, e.Left.Eval(entry), e.Right.Eval(entry))
+}
+
+func (e *ArithExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Left, e.Op, e.Right)
+}
+
+// NegateExpr is the arithmetic negation of its operand.
+type NegateExpr struct {
+	Operand Expr `json:"operand"`
+}
+
+func (e *NegateExpr) Eval(entry entries.LogEntry) any {
+	return arith("*", int64(-1), e.Operand.Eval(entry))
+}
+
+func (e *NegateExpr) String() string {
+	return "-" + e.Operand.String()
+}
+
+// CallExpr calls a built-in function with the evaluated values of its arguments.
+type CallExpr struct {
+	Func string `json:"func"`
+	Args []Expr `json:"args"`
+	fn   *builtin
+}
+
+func (e *CallExpr) Eval(entry entries.LogEntry) any {
+	args := make([]any, len(e.Args))
+	for i, a := range e.Args {
+		args[i] = a.Eval(entry)
+	}
+	return e.fn.call(args)
+}
+
+func (e *CallExpr) String() string {
+	var buf strings.Builder
+	buf.WriteString(e.Func)
+	buf.WriteString("(")
+	for i, a := range e.Args {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(a.String())
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+var arithOps = map[lexType]string{
+	tPlus:    "+",
+	tMinus:   "-",
+	tStar:    "*",
+	tSlash:   "/",
+	tPercent: "%",
+}
+
+var compareOps = map[lexType]string{
+	tEqEq:  "==",
+	tNotEq: "!=",
+	tLt:    "<",
+	tLte:   "<=",
+	tGt:    ">",
+	tGte:   ">=",
+}
+
+func (p *parser) parseExpr(str *tokenStream) (Expr, error) {
+	return p.parseOr(str)
+}
+
+func (p *parser) parseOr(str *tokenStream) (Expr, error) {
+	left, err := p.parseAnd(str)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := str.next()
+		if t.Type != tOr {
+			str.pushBack(t)
+			return left, nil
+		}
+		right, err := p.parseAnd(str)
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "or", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseAnd(str *tokenStream) (Expr, error) {
+	left, err := p.parseNot(str)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := str.next()
+		if t.Type != tAnd {
+			str.pushBack(t)
+			return left, nil
+		}
+		right, err := p.parseNot(str)
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "and", Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseNot(str *tokenStream) (Expr, error) {
+	t := str.next()
+	if t.Type != tNot {
+		str.pushBack(t)
+		return p.parseComparison(str)
+	}
+	operand, err := p.parseNot(str)
+	if err != nil {
+		return nil, err
+	}
+	return &NotExpr{Operand: operand}, nil
+}
+
+func (p *parser) parseComparison(str *tokenStream) (Expr, error) {
+	left, err := p.parseAdditive(str)
+	if err != nil {
+		return nil, err
+	}
+	op := str.next()
+	switch op.Type {
+	case tEqEq, tNotEq, tLt, tLte, tGt, tGte:
+		right, err := p.parseAdditive(str)
+		if err != nil {
+			return nil, err
+		}
+		return &CompareExpr{Op: compareOps[op.Type], Left: left, Right: right}, nil
+	case tMatch, tNotMatch:
+		pattern := str.next()
+		if pattern.Type != tString {
+			return nil, unexpected(pattern, "regex string")
+		}
+		text := escapeString(pattern.Text)
+		r, err := regexp.Compile(text)
+		if err != nil {
+			return nil, semantic(pattern, fmt.Errorf("%w: unable to parse pattern %s", ErrInvalidMatchPattern, pattern.Text))
+		}
+		return &MatchExpr{Negate: op.Type == tNotMatch, Operand: left, Pattern: text, regex: r}, nil
+	default:
+		str.pushBack(op)
+		return left, nil
+	}
+}
+
+func (p *parser) parseAdditive(str *tokenStream) (Expr, error) {
+	left, err := p.parseMultiplicative(str)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := str.next()
+		switch {
+		case op.Type == tPlus, op.Type == tMinus:
+			right, err := p.parseMultiplicative(str)
+			if err != nil {
+				return nil, err
+			}
+			left = &ArithExpr{Op: arithOps[op.Type], Left: left, Right: right}
+		case (op.Type == tInt || op.Type == tNumber) && strings.HasPrefix(op.Text, "-"):
+			// The lexer reads "a -1" as an identifier followed by a negative number, so this is treated as subtraction.
+			op.Text = strings.TrimPrefix(op.Text, "-")
+			op.Pos++
+			str.pushBack(op)
+			right, err := p.parseMultiplicative(str)
+			if err != nil {
+				return nil, err
+			}
+			left = &ArithExpr{Op: "-", Left: left, Right: right}
+		default:
+			str.pushBack(op)
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) parseMultiplicative(str *tokenStream) (Expr, error) {
+	left, err := p.parseUnary(str)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := str.next()
+		switch op.Type {
+		case tStar, tSlash, tPercent:
+			right, err := p.parseUnary(str)
+			if err != nil {
+				return nil, err
+			}
+			left = &ArithExpr{Op: arithOps[op.Type], Left: left, Right: right}
+		default:
+			str.pushBack(op)
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) parseUnary(str *tokenStream) (Expr, error) {
+	t := str.next()
+	if t.Type != tMinus {
+		str.pushBack(t)
+		return p.parseOperand(str)
+	}
+	operand, err := p.parseUnary(str)
+	if err != nil {
+		return nil, err
+	}
+	return &NegateExpr{Operand: operand}, nil
+}
+
+func (p *parser) parseCall(str *tokenStream, name token) (*CallExpr, error) {
+	fn, ok := builtins[name.Text]
+	if !ok {
+		return nil, semantic(name, fmt.Errorf("%w '%s'", ErrUnknownFunction, name.Text))
+	}
+	call := &CallExpr{Func: name.Text, fn: fn}
+	lp := str.next()
+	if lp.Type != tLpar {
+		return nil, unexpected(lp, "(")
+	}
+	for {
+		t := str.next()
+		if t.Type == tRpar {
+			break
+		}
+		if len(call.Args) > 0 {
+			if t.Type != tComma {
+				return nil, unexpected(t, ",", ")")
+			}
+		} else {
+			str.pushBack(t)
+		}
+		arg, err := p.parseExpr(str)
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+	}
+	if err := fn.checkArgs(len(call.Args)); err != nil {
+		return nil, semantic(name, fmt.Errorf("%w for '%s': %v", ErrInvalidArgCount, name.Text, err))
+	}
+	return call, nil
+}
+
+func (p *parser) parseOperand(str *tokenStream) (Expr, error) {
+	t := str.next()
+	switch t.Type {
+	case tString:
+		return &LiteralExpr{Value: escapeString(t.Text), Raw: t.Text}, nil
+	case tInt:
+		i, err := strconv.ParseInt(t.Text, 10, 64)
+		if err != nil {
+			return nil, semantic(t, errors.New("invalid int"))
+		}
+		return &LiteralExpr{Value: i, Raw: t.Text}, nil
+	case tNumber:
+		f, err := strconv.ParseFloat(t.Text, 64)
+		if err != nil {
+			return nil, semantic(t, errors.New("invalid float"))
+		}
+		return &LiteralExpr{Value: f, Raw: t.Text}, nil
+	case tTrue, tFalse:
+		return &LiteralExpr{Value: t.Type == tTrue, Raw: t.Text}, nil
+	case tIdentifier:
+		if next := str.peek(); next.Type == tLpar {
+			return p.parseCall(str, t)
+		}
+		return &FieldExpr{Field: fieldPath(str, t).Text}, nil
+	case tHas:
+		field := str.next()
+		if field.Type != tIdentifier {
+			return nil, unexpected(field, "field identifier")
+		}
+		return &HasExpr{Field: fieldPath(str, field).Text}, nil
+	case tTagged:
+		tag := str.next()
+		if tag.Type != tString {
+			return nil, unexpected(tag, "tag string")
+		}
+		return &TaggedExpr{Tag: escapeString(tag.Text)}, nil
+	case tLpar:
+		expr, err := p.parseExpr(str)
+		if err != nil {
+			return nil, err
+		}
+		rp := str.next()
+		if rp.Type != tRpar {
+			return nil, unexpected(rp, ")")
+		}
+		return expr, nil
+	default:
+		return nil, unexpected(t, "string", "number", "field identifier", "function call", "has", "tagged", "not", "-", "(")
+	}
+}
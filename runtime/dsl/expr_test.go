@@ -0,0 +1,188 @@
+package dsl
+
+import (
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseExpr_Eval(t *testing.T) {
+	entry := entries.LogEntry{
+		entries.StandardMessageField:   "connection refused",
+		entries.StandardLevelField:     "ERROR",
+		entries.StandardTimestampField: "2023-04-01T12:00:00Z",
+		entries.StandardTagField:       "prod.api",
+		"status":                       float64(503),
+		"count":                        "12",
+		"ok":                           false,
+		"category":                     "ERR",
+	}
+	tests := map[string]struct {
+		expr     string
+		expected bool
+	}{
+		"String equality":          {expr: `@level == "ERROR"`, expected: true},
+		"String inequality":        {expr: `@level != "ERROR"`, expected: false},
+		"Numeric comparison":       {expr: `status >= 500`, expected: true},
+		"Numeric string coercion":  {expr: `count > 9`, expected: true},
+		"Float literal":            {expr: `status < 503.5`, expected: true},
+		"Time comparison":          {expr: `@timestamp > "2023-03-31T23:59:59-05:00"`, expected: true},
+		"Time comparison false":    {expr: `@timestamp < "2023-04-01T06:00:00-05:00"`, expected: false},
+		"Regex match":              {expr: `@message =~ "^conn.*refused$"`, expected: true},
+		"Regex not match":          {expr: `@message !~ "refused"`, expected: false},
+		"Has field":                {expr: `has status`, expected: true},
+		"Has missing field":        {expr: `has missing`, expected: false},
+		"Tagged":                   {expr: `tagged "API"`, expected: true},
+		"And":                      {expr: `has status and status == 503`, expected: true},
+		"Or":                       {expr: `has missing or tagged "prod"`, expected: true},
+		"Not":                      {expr: `not has missing`, expected: true},
+		"Bang":                     {expr: `!ok`, expected: true},
+		"Boolean literal":          {expr: `ok == false`, expected: true},
+		"Precedence":               {expr: `has missing and has status or tagged "api"`, expected: true},
+		"Parentheses":              {expr: `has missing and (has status or tagged "api")`, expected: false},
+		"Missing field comparison": {expr: `missing == "a"`, expected: false},
+		"Missing field inequality": {expr: `missing != "a"`, expected: true},
+		"No spaces":                {expr: `status==503`, expected: true},
+		"Level comparison":         {expr: `level(@level) >= "warn"`, expected: true},
+		"Level equality":           {expr: `level(@level) == "err"`, expected: true},
+		"Unknown level":            {expr: `level(@message) < "fatal"`, expected: false},
+		"Level field severity":     {expr: `@level >= "warn"`, expected: true},
+		"Level field below":        {expr: `@level < "fatal"`, expected: true},
+		"Level name on the left":   {expr: `"debug" < @level`, expected: true},
+		"Level name on a field":    {expr: `category == "error"`, expected: false},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			expr, err := ParseExpr(tc.expr)
+			require.NoError(t, err)
+			t.Log("Parsed:", expr.String())
+			assert.Equal(t, tc.expected, Matches(expr, entry))
+		})
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	tests := map[string]struct {
+		expr     string
+		expected error
+		contains string
+	}{
+		"Missing operand": {
+			expr:     `status ==`,
+			expected: ErrUnexpectedToken,
+			contains: "line 1 position 10",
+		},
+		"Invalid regex": {
+			expr:     `@message =~ "("`,
+			expected: ErrInvalidMatchPattern,
+			contains: "line 1 position 13",
+		},
+		"Unbalanced parentheses": {
+			expr:     `(has a`,
+			expected: ErrUnexpectedToken,
+		},
+		"Trailing tokens": {
+			expr:     `has a has b`,
+			expected: ErrUnexpectedToken,
+			contains: "line 1 position 7",
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseExpr(tc.expr)
+			assert.ErrorIs(t, err, tc.expected)
+			if len(tc.contains) > 0 {
+				assert.ErrorContains(t, err, tc.contains)
+			}
+		})
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	assert.False(t, Truthy(nil))
+	assert.False(t, Truthy(""))
+	assert.False(t, Truthy(false))
+	assert.False(t, Truthy(float64(0)))
+	assert.False(t, Truthy(int64(0)))
+	assert.True(t, Truthy("a"))
+	assert.True(t, Truthy(true))
+	assert.True(t, Truthy(1))
+	assert.True(t, Truthy(map[string]any{}))
+}
+
+func TestParseExpr_Values(t *testing.T) {
+	entry := entries.LogEntry{
+		entries.StandardLevelField: "WARN",
+		"latency_s":                float64(1.25),
+		"count":                    "12",
+		"host":                     "example.com",
+		"path":                     "/index.html",
+		"ts":                       "2023/04/01 12:30:00",
+		"http":                     map[string]any{"status": float64(503), "from": "proxy"},
+		"tags":                     []any{"first", "last"},
+		"k8s.io":                   map[string]any{"app": "api"},
+	}
+	tests := map[string]struct {
+		expr     string
+		expected any
+	}{
+		"Multiply":            {expr: `latency_s * 1000`, expected: float64(1250)},
+		"Integer math":        {expr: `count * 2 + 1`, expected: int64(25)},
+		"Precedence":          {expr: `(count + 2) * 2`, expected: int64(28)},
+		"Subtract no spaces":  {expr: `count-2`, expected: int64(10)},
+		"Subtract negative":   {expr: `count -2`, expected: int64(10)},
+		"Negate":              {expr: `-count`, expected: int64(-12)},
+		"Integer division":    {expr: `count / 4`, expected: int64(3)},
+		"Float division":      {expr: `count / 8`, expected: 1.5},
+		"Divide by zero":      {expr: `count / 0`, expected: nil},
+		"Missing field":       {expr: `missing * 2`, expected: nil},
+		"String concat":       {expr: `host + path`, expected: "example.com/index.html"},
+		"Lower":               {expr: `lower(@level)`, expected: "warn"},
+		"Nested calls":        {expr: `upper(concat(host, ":", 8080))`, expected: "EXAMPLE.COM:8080"},
+		"Substr":              {expr: `substr(host, 0, 7)`, expected: "example"},
+		"Split":               {expr: `split(host, ".", -1)`, expected: "com"},
+		"Regex extract":       {expr: `regex_extract(path, "/(\\w+)\\.html", 1)`, expected: "index"},
+		"Round":               {expr: `round(latency_s, 1)`, expected: 1.3},
+		"Int":                 {expr: `int(latency_s)`, expected: int64(1)},
+		"Max":                 {expr: `max(1, count, latency_s)`, expected: "12"},
+		"Coalesce":            {expr: `coalesce(missing, host)`, expected: "example.com"},
+		"If":                  {expr: `if(latency_s > 1, "slow", "fast")`, expected: "slow"},
+		"Parse and format":    {expr: `format_time(parse_time(ts, "2006/01/02 15:04:05"))`, expected: "2023-04-01T12:30:00Z"},
+		"Unix":                {expr: `unix(parse_time(ts, "2006/01/02 15:04:05"))`, expected: int64(1680352200)},
+		"From unix ms":        {expr: `format_time(from_unix_ms(1680352200000))`, expected: "2023-04-01T12:30:00Z"},
+		"Comparison of calls": {expr: `len(host) == 11`, expected: true},
+		"Nested field":        {expr: `http.status + 1`, expected: float64(504)},
+		"Keyword segment":     {expr: `http.from`, expected: "proxy"},
+		"Array index":         {expr: `tags[-1]`, expected: "last"},
+		"Escaped dot":         {expr: `k8s\.io.app`, expected: "api"},
+		"Has nested field":    {expr: `has tags[1] and not has http.method`, expected: true},
+		"Nested comparison":   {expr: `http.status>=500`, expected: true},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			expr, err := ParseExpr(tc.expr)
+			require.NoError(t, err)
+			t.Log("Parsed:", expr.String())
+			assert.Equal(t, tc.expected, expr.Eval(entry))
+		})
+	}
+}
+
+func TestParseExpr_CallErrors(t *testing.T) {
+	_, err := ParseExpr(`nope(a)`)
+	assert.ErrorIs(t, err, ErrUnknownFunction)
+	assert.ErrorContains(t, err, "line 1 position 1")
+
+	_, err = ParseExpr(`lower(a, b)`)
+	assert.ErrorIs(t, err, ErrInvalidArgCount)
+
+	_, err = ParseExpr(`lower(a b)`)
+	assert.ErrorIs(t, err, ErrUnexpectedToken)
+}
@@ -0,0 +1,366 @@
+package dsl
+
+import (
+	"fmt"
+	"github.com/saylorsolutions/nomlog/pkg/entries"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type builtin struct {
+	minArgs int
+	maxArgs int // maxArgs is -1 for variadic functions.
+	usage   string
+	fn      func(args []any) any
+}
+
+func (b *builtin) checkArgs(n int) error {
+	switch {
+	case n < b.minArgs:
+		return fmt.Errorf("expected at least %d, got %d", b.minArgs, n)
+	case b.maxArgs >= 0 && n > b.maxArgs:
+		return fmt.Errorf("expected at most %d, got %d", b.maxArgs, n)
+	}
+	return nil
+}
+
+func (b *builtin) call(args []any) any {
+	return b.fn(args)
+}
+
+var builtins = map[string]*builtin{
+	// Strings
+	"lower": {1, 1, "lower(STRING) returns the string in lower case", strFunc(strings.ToLower)},
+	"upper": {1, 1, "upper(STRING) returns the string in upper case", strFunc(strings.ToUpper)},
+	"trim":  {1, 1, "trim(STRING) removes leading and trailing whitespace", strFunc(strings.TrimSpace)},
+	"len": {1, 1, "len(STRING) returns the number of characters in the string", func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		return int64(len([]rune(s)))
+	}},
+	"concat": {1, -1, "concat(VALUE, ...) joins values together as a string, skipping any without a value", func(args []any) any {
+		var buf strings.Builder
+		for _, a := range args {
+			s, _ := toString(a)
+			buf.WriteString(s)
+		}
+		return buf.String()
+	}},
+	"replace": {3, 3, "replace(STRING, OLD, NEW) replaces all instances of OLD with NEW", func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		old, _ := toString(args[1])
+		_new, _ := toString(args[2])
+		return strings.ReplaceAll(s, old, _new)
+	}},
+	"substr": {2, 3, "substr(STRING, START [, LENGTH]) returns part of a string, starting at the zero-based index START", func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		runes := []rune(s)
+		start, ok := toInt(args[1])
+		if !ok {
+			return nil
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > int64(len(runes)) {
+			return ""
+		}
+		end := int64(len(runes))
+		if len(args) > 2 {
+			length, ok := toInt(args[2])
+			if !ok || length < 0 {
+				return nil
+			}
+			if start+length < end {
+				end = start + length
+			}
+		}
+		return string(runes[start:end])
+	}},
+	"contains":    {2, 2, "contains(STRING, SUBSTRING) returns whether the string contains the substring", strPredicate(strings.Contains)},
+	"starts_with": {2, 2, "starts_with(STRING, PREFIX) returns whether the string starts with the prefix", strPredicate(strings.HasPrefix)},
+	"ends_with":   {2, 2, "ends_with(STRING, SUFFIX) returns whether the string ends with the suffix", strPredicate(strings.HasSuffix)},
+	"split": {3, 3, "split(STRING, SEPARATOR, INDEX) splits the string and returns the part at INDEX, which may be negative to count from the end", func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		sep, _ := toString(args[1])
+		idx, ok := toInt(args[2])
+		if !ok {
+			return nil
+		}
+		parts := strings.Split(s, sep)
+		if idx < 0 {
+			idx += int64(len(parts))
+		}
+		if idx < 0 || idx >= int64(len(parts)) {
+			return nil
+		}
+		return parts[idx]
+	}},
+	"regex_extract": {2, 3, "regex_extract(STRING, REGEX [, GROUP]) returns the first match of REGEX, or the numbered capture GROUP", func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		pattern, _ := toString(args[1])
+		r, err := cachedRegex(pattern)
+		if err != nil {
+			return nil
+		}
+		group := int64(0)
+		if len(args) > 2 {
+			group, ok = toInt(args[2])
+			if !ok {
+				return nil
+			}
+		}
+		match := r.FindStringSubmatch(s)
+		if match == nil || group < 0 || group >= int64(len(match)) {
+			return nil
+		}
+		return match[group]
+	}},
+
+	// Numbers
+	"int": {1, 1, "int(VALUE) converts the value to an integer, truncating any fractional part", func(args []any) any {
+		if i, ok := toInt(args[0]); ok {
+			return i
+		}
+		if f, ok := toNumber(args[0]); ok {
+			return int64(f)
+		}
+		return nil
+	}},
+	"float": {1, 1, "float(VALUE) converts the value to a floating point number", func(args []any) any {
+		if f, ok := toNumber(args[0]); ok {
+			return f
+		}
+		return nil
+	}},
+	"round": {1, 2, "round(NUMBER [, PLACES]) rounds the number to the given number of decimal places, or to an integer", func(args []any) any {
+		f, ok := toNumber(args[0])
+		if !ok {
+			return nil
+		}
+		if len(args) == 1 {
+			return int64(math.Round(f))
+		}
+		places, ok := toInt(args[1])
+		if !ok {
+			return nil
+		}
+		scale := math.Pow(10, float64(places))
+		return math.Round(f*scale) / scale
+	}},
+	"floor": {1, 1, "floor(NUMBER) rounds the number down to an integer", numFunc(math.Floor)},
+	"ceil":  {1, 1, "ceil(NUMBER) rounds the number up to an integer", numFunc(math.Ceil)},
+	"abs": {1, 1, "abs(NUMBER) returns the absolute value of the number", func(args []any) any {
+		if i, ok := toInt(args[0]); ok {
+			if i < 0 {
+				return -i
+			}
+			return i
+		}
+		if f, ok := toNumber(args[0]); ok {
+			return math.Abs(f)
+		}
+		return nil
+	}},
+	"min": {1, -1, "min(NUMBER, ...) returns the smallest number", func(args []any) any {
+		return extreme(args, -1)
+	}},
+	"max": {1, -1, "max(NUMBER, ...) returns the largest number", func(args []any) any {
+		return extreme(args, 1)
+	}},
+
+	// Times
+	"now": {0, 0, "now() returns the current time in UTC", func([]any) any {
+		return time.Now().UTC()
+	}},
+	"parse_time": {1, -1, "parse_time(STRING [, LAYOUT, ...]) parses a time with Go reference layouts, defaulting to RFC 3339", func(args []any) any {
+		if len(args) == 1 {
+			if t, ok := toTime(args[0]); ok {
+				return t
+			}
+			return nil
+		}
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		for _, l := range args[1:] {
+			layout, _ := toString(l)
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.UTC()
+			}
+		}
+		return nil
+	}},
+	"format_time": {1, 2, "format_time(TIME [, LAYOUT]) formats a time with a Go reference layout, defaulting to RFC 3339", func(args []any) any {
+		t, ok := toTime(args[0])
+		if !ok {
+			return nil
+		}
+		layout := time.RFC3339
+		if len(args) > 1 {
+			layout, _ = toString(args[1])
+		}
+		return t.Format(layout)
+	}},
+	"unix": {1, 1, "unix(TIME) returns the time as seconds since the Unix epoch", func(args []any) any {
+		if t, ok := toTime(args[0]); ok {
+			return t.Unix()
+		}
+		return nil
+	}},
+	"unix_ms": {1, 1, "unix_ms(TIME) returns the time as milliseconds since the Unix epoch", func(args []any) any {
+		if t, ok := toTime(args[0]); ok {
+			return t.UnixNano() / int64(time.Millisecond)
+		}
+		return nil
+	}},
+	"from_unix": {1, 1, "from_unix(NUMBER) returns the time represented by seconds since the Unix epoch", func(args []any) any {
+		if f, ok := toNumber(args[0]); ok {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC()
+		}
+		return nil
+	}},
+	"from_unix_ms": {1, 1, "from_unix_ms(NUMBER) returns the time represented by milliseconds since the Unix epoch", func(args []any) any {
+		if i, ok := toInt(args[0]); ok {
+			return time.Unix(0, i*int64(time.Millisecond)).UTC()
+		}
+		if f, ok := toNumber(args[0]); ok {
+			return time.Unix(0, int64(f*float64(time.Millisecond))).UTC()
+		}
+		return nil
+	}},
+
+	// Other
+	"level": {1, 1, "level(VALUE) interprets the value as a log level, which compares by severity with other levels and level names", func(args []any) any {
+		if l, ok := entries.ParseLevel(args[0]); ok {
+			return l
+		}
+		return nil
+	}},
+	"string": {1, 1, "string(VALUE) converts the value to a string", func(args []any) any {
+		if s, ok := toString(args[0]); ok {
+			return s
+		}
+		return nil
+	}},
+	"coalesce": {1, -1, "coalesce(VALUE, ...) returns the first value that is present", func(args []any) any {
+		for _, a := range args {
+			if a != nil {
+				return a
+			}
+		}
+		return nil
+	}},
+	"if": {3, 3, "if(CONDITION, THEN, ELSE) returns THEN if CONDITION is truthy, otherwise ELSE", func(args []any) any {
+		if Truthy(args[0]) {
+			return args[1]
+		}
+		return args[2]
+	}},
+}
+
+// FunctionDocs returns usage information for all built-in expression functions, sorted by name.
+func FunctionDocs() []string {
+	docs := make([]string, 0, len(builtins))
+	for _, b := range builtins {
+		docs = append(docs, b.usage)
+	}
+	sort.Strings(docs)
+	return docs
+}
+
+func strFunc(fn func(string) string) func([]any) any {
+	return func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		return fn(s)
+	}
+}
+
+func strPredicate(fn func(string, string) bool) func([]any) any {
+	return func(args []any) any {
+		s, ok := toString(args[0])
+		if !ok {
+			return nil
+		}
+		other, _ := toString(args[1])
+		return fn(s, other)
+	}
+}
+
+func numFunc(fn func(float64) float64) func([]any) any {
+	return func(args []any) any {
+		if i, ok := toInt(args[0]); ok {
+			return i
+		}
+		f, ok := toNumber(args[0])
+		if !ok {
+			return nil
+		}
+		return int64(fn(f))
+	}
+}
+
+func extreme(args []any, sign int) any {
+	var found any
+	for _, a := range args {
+		if a == nil {
+			continue
+		}
+		if _, ok := toNumber(a); !ok {
+			continue
+		}
+		if found == nil {
+			found = a
+			continue
+		}
+		if c, _ := compare(a, found); c == sign {
+			found = a
+		}
+	}
+	return found
+}
+
+var (
+	regexCache   = map[string]*regexp.Regexp{}
+	regexCacheMu sync.RWMutex
+)
+
+func cachedRegex(pattern string) (*regexp.Regexp, error) {
+	regexCacheMu.RLock()
+	r, ok := regexCache[pattern]
+	regexCacheMu.RUnlock()
+	if ok {
+		return r, nil
+	}
+	r, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCacheMu.Lock()
+	regexCache[pattern] = r
+	regexCacheMu.Unlock()
+	return r, nil
+}
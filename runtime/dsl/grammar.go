@@ -9,17 +9,30 @@ Certain transformations and all sinks will consume a source. This means that the
 The general flow of a script is to setup one or more sources, perform any necessary transformations, and output the streams to one or more sinks.
 The same thing can be accomplished with Go code, but the DSL syntax is a little more approachable.
 
+Fields in nested objects and arrays may be referenced with a path anywhere a field identifier is expected, like http.status or tags[0].name.
+Array indexes may be negative to count from the end, and path segments must not be separated by whitespace.
+A field with a dot in its name is matched as-is first, but a backslash may be used to escape a dot that's part of a key, like labels.k8s\.io.
+
 
 [DSL Syntax]
 Source identifies a log source and exposes it in the runtime.
   source as IDENTIFIER CLASS [ARG [, ARG]]
 
-Merge will combine two sources with a new identifier. The combined sources will be marked as consumed.
-  merge NEW_IDENTIFIER and IDENTIFIER as IDENTIFIER
+Merge will combine two or more sources with a new identifier. The combined sources will be marked as consumed.
+Sources may be separated with commas or "and".
+  merge IDENTIFIER and IDENTIFIER as NEW_IDENTIFIER
+  merge IDENTIFIER, IDENTIFIER [, IDENTIFIER] as NEW_IDENTIFIER
+Adding "ordered" merges log events by time instead of in the order they're read. Time is read from @timestamp unless another field is given.
+By default each source is assumed to already be in order, like most log files.
+Adding "within" reorders events that arrive up to a duration out of order, which is better for live sources like file.Tail.
+Adding "sorted" reads all sources to the end and sorts every event, which only works for finite sources.
+Events without a time are passed through as they're read.
+  merge IDENTIFIER, IDENTIFIER as NEW_IDENTIFIER ordered [by FIELD] [within DURATION_STRING | sorted]
 
-Dupe will duplicate a source into two new sources. This is useful to output the same log events in two different ways.
-The input source will be marked as consumed.
+Dupe will duplicate a source into two or more new sources. This is useful to output the same log events in different ways.
+Each new source receives its own copy of the log events. The input source will be marked as consumed.
   dupe SRC_IDENTIFIER as NEW_IDENTIFIER and NEW_IDENTIFIER
+  dupe SRC_IDENTIFIER as NEW_IDENTIFIER, NEW_IDENTIFIER [, NEW_IDENTIFIER]
 
 Append will forward all source stream to a target stream, consuming the source.
   append SRC_IDENTIFIER to TARGET_IDENTIFIER
@@ -28,15 +41,157 @@ Cut will will perform an eager field split using a specified delimiter, or the d
 Sequential delimiters in the log message will all be consumed at once.
   cut [with STRING] IDENTIFIER set(FIELD_IDENTIFIER=FIELD_NUM [, FIELD_IDENTIFIER=FIELD_NUM])
 
-Fanout will spread the log events in one stream into two new streams, consuming the source.
-  fanout IDENTIFIER as IDENTIFIER and IDENTIFIER
+Fanout will spread the log events in one stream into two or more new streams, consuming the source.
+  fanout IDENTIFIER as NEW_IDENTIFIER and NEW_IDENTIFIER
+  fanout IDENTIFIER as NEW_IDENTIFIER, NEW_IDENTIFIER [, NEW_IDENTIFIER]
+Each event goes to whichever new stream is ready first, unless a partitioning strategy is given.
+Adding "by" sends events with the same value in a field to the same stream, which is useful when each stream is handled by a worker that needs all events for a key.
+Adding "roundrobin" sends events to each stream in turn, and "leastloaded" sends events to the stream with the fewest events waiting to be read.
+With a strategy, every new stream should be consumed and all but the last sink should be async, like with route.
+  fanout IDENTIFIER as NEW_IDENTIFIER, NEW_IDENTIFIER [by FIELD | roundrobin | leastloaded]
 
 Tag allows easily attaching string metadata to a stream. The stream will not be consumed.
   tag IDENTIFIER with STRING
 
 Join is useful for combining multi-line, unstructured log output.
 Multiple comma-separated regex patterns may be used to specify what makes up a start line.
-  join IDENTIFIER with REGEX_STRING [, REGEX_STRING]
+With "continuation", the patterns specify what makes up the lines after it instead, like indented stack frames, and every other line starts a new message.
+Lines are joined separately for each value of a field given with "by", like a thread ID, so interleaved output from multiple threads isn't joined together.
+A message is normally emitted when the next one starts. With "idle", it's emitted once no lines have been added to it for the duration, so the last stack trace from a quiet file isn't held.
+Messages may be limited with "max INT lines" and "max INT bytes", and a line that would exceed a limit starts a new message.
+  join IDENTIFIER with [continuation] REGEX_STRING [, REGEX_STRING] [by FIELD] [idle DURATION_STRING] [max INT lines] [max INT bytes]
+
+Filter drops log entries from a stream unless they match an expression. The stream will not be consumed.
+  filter IDENTIFIER where EXPR
+
+Expressions reference fields by identifier, and may use string, number, and boolean (true/false) literals.
+  - Comparisons: ==, !=, <, <=, >, >=
+    Values are compared as numbers if both sides are numeric, as times if both sides are RFC 3339 timestamps, and as strings otherwise.
+    Values from the level function are compared by severity, so 'level(@level) >= "warn"' matches warnings and anything more severe.
+  - Regex match: FIELD =~ REGEX_STRING, FIELD !~ REGEX_STRING
+  - Field presence: has FIELD
+  - Tag presence: tagged STRING
+  - Logic: EXPR and EXPR, EXPR or EXPR, not EXPR, (EXPR)
+  - Arithmetic: +, -, *, /, %
+    The + operator will join values as strings if either side is not numeric.
+  - Function calls: FUNCTION(EXPR [, EXPR])
+    (Available functions are listed in [Expression Functions])
+Expressions with no value for a given entry - like a reference to a missing field - will not set a field in transform, and will not match in filter or route.
+
+Route sends log events to new streams by expression, consuming the source. Each event goes to the first matching branch, or to every matching branch with "all".
+Events that match no branch go to the default stream if one is given, and are dropped otherwise.
+Every new stream should be consumed, and all but the last sink should be async, because a stream that isn't being read holds up the others.
+  route IDENTIFIER [all] to NEW_IDENTIFIER where EXPR [, NEW_IDENTIFIER where EXPR] [default NEW_IDENTIFIER]
+
+Transform sets fields to the result of an expression, creating them if necessary. The stream will not be consumed.
+Assignments are made in order, so later assignments may use fields set by earlier ones.
+  transform IDENTIFIER set(FIELD_IDENTIFIER=EXPR [, FIELD_IDENTIFIER=EXPR])
+
+Rename moves field values to new field names. The stream will not be consumed.
+Numbered fields - like those produced by cut without a field mapping - may be renamed by number.
+A field nested in another renamed field is moved out of it first, so set(http=x, http.status=y) leaves the rest of the http object in x.
+  rename IDENTIFIER set(FIELD_IDENTIFIER=FIELD_IDENTIFIER [, FIELD_IDENTIFIER=FIELD_IDENTIFIER])
+
+Drop removes the listed fields from each log entry, while keep removes all fields except those listed. The stream will not be consumed.
+  drop IDENTIFIER fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])
+  keep IDENTIFIER fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])
+
+Parse interprets a field - @message by default - with a named parser, and merges the parsed fields into the log entry. The stream will not be consumed.
+Entries that can't be parsed are passed through with the reason in the @parse_error field.
+  parse IDENTIFIER as PARSER [STRING [, STRING]] [from FIELD_IDENTIFIER]
+Available parsers:
+  - logfmt: parses lines like 'level=info msg="hello world"'. Keys without a value are set to true.
+  - kv [PAIR_SEP [, VALUE_SEP [, QUOTES]]]: parses key/value pairs with custom separators, defaulting to " ", "=", and '"'.
+  - csv [HEADER [, DELIM]]: parses delimiter separated values, naming fields with a CSV HEADER string like "ts,level,msg".
+    If no header is given, then the first line of the stream is used as the header.
+  - regex REGEX_STRING: sets a field for each named capture group, like (?P<name>\w+).
+  - grok GROK_STRING [, PATTERN_FILE]: matches Grok patterns, like "%{IP:client} %{INT:status:int}".
+    Captures may be typed with :int or :float, and may populate standard fields, like %{LOGLEVEL:@level}.
+    Pattern files define additional patterns as "NAME PATTERN", one per line.
+  - clf, combined: parses web server access logs in the Common or Combined Log Format.
+  - nginx LOG_FORMAT: parses access logs described by an nginx log_format string, like "$remote_addr [$time_local] \"$request\" $status".
+    Variables are set as fields of the same name, with timestamps set as @timestamp, and the HTTP status mapped to @level.
+  - syslog [TIME_ZONE]: parses RFC 5424 and RFC 3164 syslog messages, mapping the severity to @level and the app name to @module.
+    RFC 3164 timestamps are assumed to be in UTC unless a time zone name like "America/Chicago" is given.
+  - stacktrace [LANGUAGE [, LANGUAGE]]: parses Java exceptions, Go panics, and Python tracebacks in a joined message, optionally limited to "java", "go", or "python".
+    Sets exception_type, exception_message, caused_by, goroutine_id, and frames - with the function, file, and line of each frame - along with a stack_fingerprint to group identical crashes.
+
+Normalize timestamp detects the time in the first candidate field that has one, and sets @timestamp to that time as an RFC 3339 string in UTC. The stream will not be consumed.
+Candidate fields default to @timestamp, timestamp, time, ts, @t, datetime, and date.
+Common formats are detected automatically, including Unix epoch seconds, milliseconds, microseconds, and nanoseconds.
+Additional Go reference layouts may be given with "with", and times without a time zone are assumed to be in UTC unless a zone is given with "in".
+Times without a year - like BSD syslog timestamps - are assumed to be in the current year, unless that would put them more than a month in the future.
+  normalize IDENTIFIER timestamp [fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])] [with LAYOUT_STRING [, LAYOUT_STRING]] [in TIME_ZONE_STRING]
+
+Normalize level detects the level in the first candidate field that has one, and sets @level to a canonical level name. The stream will not be consumed.
+Canonical levels from least to most severe are trace, debug, info, notice, warn, error, fatal, alert, and emergency.
+Candidate fields default to @level, level, lvl, severity, levelname, loglevel, and log_level.
+Level names from common loggers are recognized, like WARNING, ERR, CRITICAL, SEVERE, and dpanic.
+Numeric levels are ambiguous, so named mappings may be given with "with" to interpret them: pino, python, zap, slog, log4j, syslog, and common.
+Otherwise, numbers are interpreted as syslog (0-7), pino (10-60), or log4j (100-600) levels.
+Custom raw values may be mapped to canonical levels with "set", which are checked before any other mapping.
+  normalize IDENTIFIER level [fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])] [with MAPPING_STRING [, MAPPING_STRING]] [set(RAW_LEVEL=LEVEL [, RAW_LEVEL=LEVEL])]
+
+Flatten replaces nested objects with top level fields, joining the keys at each level with a separator, which defaults to ".". Keys that contain the separator are escaped with a backslash, like "k8s\.io.name". The stream will not be consumed.
+This is useful for sinks that need flat records, like a SQLite table. Arrays are left as-is unless "arrays" is given, in which case each element is keyed by its index.
+Unflatten reverses this, rebuilding nested objects from the separated keys. With "arrays", objects with keys from 0 to N-1 are rebuilt as arrays.
+  flatten IDENTIFIER [with SEPARATOR_STRING] [arrays]
+  unflatten IDENTIFIER [with SEPARATOR_STRING] [arrays]
+
+Explode emits a log entry for each element of an array field, with the field set to that element. The stream will not be consumed.
+Entries where the field is missing, empty, or not an array are passed through unchanged. The element's index may be written to another field with "index".
+  explode IDENTIFIER FIELD_IDENTIFIER [index FIELD_IDENTIFIER]
+
+Redact finds sensitive values in string fields and masks them. The stream will not be consumed.
+All string values are checked - including those in nested objects and arrays - unless fields are given.
+Built-in detectors may be selected with "with": email, ipv4, ipv6, jwt, credit_card (Luhn checked), aws_key, and bearer. All of them are used if no detectors or patterns are given.
+Custom regex patterns may be given with "match". If a pattern has a capture group, only the group is redacted, like "password=(\\S+)".
+Values are replaced with a placeholder like [REDACTED:email] by default. A partial mask preserves the format while hiding most of the value, like j***@example.com.
+A hash replaces values with a keyed HMAC, so the same value is always replaced the same way and may still be joined or counted.
+  redact IDENTIFIER [fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])] [with DETECTOR_STRING [, DETECTOR_STRING]] [match REGEX_STRING [, REGEX_STRING]] [as full|partial|hash KEY_STRING]
+
+Cluster groups similar messages into templates as they're seen, using the Drain algorithm. The stream will not be consumed.
+Each entry is annotated with @template_id, @template - where tokens that vary are replaced with <*> - and @template_params with the varying tokens.
+Messages are read from @message unless a field is given with "from". The depth of the parse tree defaults to 4, and the similarity needed to join a template defaults to 0.4.
+With "summary", each template will be logged with its count when the stream ends. Run 'nomlog patterns FILE' for a quick summary of a file.
+  cluster IDENTIFIER [from FIELD_IDENTIFIER] [depth INT] [similarity NUMBER] [summary]
+
+Aggregate computes values over windows of time, and emits an event with the results for each group in each window once it has closed.
+This consumes the source, and the results are available from a new stream. Windows with no events aren't emitted.
+Available functions are count, sum(FIELD), min(FIELD), max(FIELD), avg(FIELD), distinct(FIELD), and percentiles like p95(FIELD) or percentile(FIELD, 99.9).
+Results are written to fields named like count, sum_bytes, or p95_latency, unless a field is given with "as". Count with a field only counts events that have the field.
+Results are computed separately for each distinct combination of the "by" fields, which are included in each result.
+Windows are tumbling by default, and "slide" makes them overlap with a new window starting every duration. Windows are aligned to whole units of time, so one minute windows start on the minute.
+Time is read from @timestamp unless another field is given with "on", and events without a time are ignored. With "on arrival", windows are based on when events are read instead.
+By default a window is emitted once an event after its end is read. Adding "late" waits that much longer for events that arrive out of order.
+Each result has the window's bounds in @window_start and @window_end, with the start in @timestamp.
+  aggregate IDENTIFIER as NEW_IDENTIFIER FUNCTION [as FIELD] [, FUNCTION [as FIELD]] [by FIELD [, FIELD]] every DURATION_STRING [slide DURATION_STRING] [on FIELD | on arrival] [late DURATION_STRING]
+
+Parallel sets the number of workers used for later operations on a stream that handle each event on its own, which is useful for expensive parsing or redaction. The stream will not be consumed.
+Events stay in the order they were read. This applies to cut, filter, transform, rename, drop, keep, parse, normalize, flatten, unflatten, explode, and redact.
+Operations that depend on earlier events - like join, cluster, and parse csv without a header - always run on one worker. A worker count of 1 turns parallelism off again.
+  parallel IDENTIFIER with INT
+
+Dedup suppresses repeats of an event within a window of time, like a crash loop logging the same lines over and over. The stream will not be consumed.
+The first occurrence is passed through, and the last repeat is emitted as a summary with @repeat_count, @first_seen, and @last_seen when the window ends.
+Whole events are compared except for @read_timestamp and @read_line_number, unless only some fields are compared with "fields". More fields may be left out of the comparison with "ignore".
+The window defaults to one minute. With "limit", a summary is emitted once that many repeats have been suppressed, even if the window hasn't ended.
+  dedup IDENTIFIER [fields(FIELD_IDENTIFIER [, FIELD_IDENTIFIER]) | ignore(FIELD_IDENTIFIER [, FIELD_IDENTIFIER])] [within DURATION_STRING] [limit INT]
+
+Correlate groups events that share a key - like a request or trace ID - into one event, to reconstruct a transaction or session.
+This consumes the source, and the results are available from a new stream. Events without the key are passed through unchanged.
+Each result has the key, the events in @events ordered by time, @event_count, @first_timestamp and @last_timestamp, @duration_ms between them, and the most severe @level.
+Time is read from @timestamp unless another field is given with "on". A group is closed when an event matches the "end" expression, when no events have been read for it within the timeout, when it reaches the "max" number of events, or when the stream ends.
+The timeout defaults to 30 seconds. The reason a group was closed is given in @closed_by as end, timeout, max_size, or input_end.
+  correlate IDENTIFIER as NEW_IDENTIFIER by FIELD [on FIELD] [timeout DURATION_STRING] [max INT] [end where EXPRESSION]
+
+Pair joins events from two sources that have the same value in a key field, like enriching proxy access logs with the application log line for each request.
+This consumes both sources, and the results are available from a new stream. A different key field may be given for the right source with "=".
+Events wait for a match from the other source for the "within" duration after they're read, and are paired with every event from the other source with the same key in that time.
+By default only paired events are emitted. With "left", events from the left source that weren't paired are emitted on their own, and "full" does the same for both sources.
+Fields that are in both events are kept as-is from the left source, and nested in a "right" object from the right source, like right.@message.
+Other prefixes may be given with "prefix", where an empty string keeps the field names from that source and a prefix like "app_" doesn't nest them.
+  pair IDENTIFIER, IDENTIFIER as NEW_IDENTIFIER on FIELD [= FIELD] within DURATION_STRING [inner|left|full] [prefix STRING, STRING]
 
 Sink writes log entries to a plugin provided output sink. This will consume the specified stream.
   sink IDENTIFIER [async as IDENTIFIER] to CLASS [ARG [, ARG]]
@@ -2,6 +2,7 @@ package dsl
 
 import (
 	"io"
+	"strings"
 	"unicode"
 )
 
@@ -9,6 +10,10 @@ var (
 	lexBufferSize = 256
 )
 
+const (
+	wordBreaks = `().,"=!<>~+-*/%`
+)
+
 type lexBuf struct {
 	startPtr int
 	readPtr  int
@@ -85,7 +90,9 @@ func (b *lexBuf) unread() {
 }
 
 func (b *lexBuf) reset() {
-	b.readPtr = b.startPtr
+	for b.readPtr != b.startPtr {
+		b.unread()
+	}
 }
 
 func (b *lexBuf) discard() {
@@ -135,11 +142,7 @@ func (b *lexBuf) readUntilWhitespaceOrBreak() error {
 		switch {
 		case unicode.IsSpace(c):
 			fallthrough
-		case c == '(':
-			fallthrough
-		case c == '.':
-			fallthrough
-		case c == ')':
+		case strings.ContainsRune(wordBreaks, c):
 			b.unread()
 			return nil
 		}
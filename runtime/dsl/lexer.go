@@ -40,6 +40,41 @@ const (
 	tFanout
 	tTag
 	tJoin
+	tFilter
+	tWhere
+	tOr
+	tNot
+	tHas
+	tTagged
+	tTrue
+	tFalse
+	tEqEq
+	tNotEq
+	tLt
+	tLte
+	tGt
+	tGte
+	tMatch
+	tNotMatch
+	tTransform
+	tPlus
+	tMinus
+	tStar
+	tSlash
+	tPercent
+	tRename
+	tParse
+	tNormalize
+	tFlatten
+	tUnflatten
+	tExplode
+	tRedact
+	tCluster
+	tParallel
+	tAggregate
+	tDedup
+	tCorrelate
+	tPair
 )
 
 const (
@@ -115,6 +150,14 @@ func (l *lexer) isDigit(r rune) bool {
 	return false
 }
 
+func (l *lexer) nextIsDigit() bool {
+	r, err := l.peek()
+	if err != nil {
+		return false
+	}
+	return l.isDigit(r)
+}
+
 func (l *lexer) stream() *tokenStream {
 	return newTokenStream(l.tokens)
 }
@@ -144,6 +187,8 @@ func (l *lexer) lex() {
 				l.handleLexErr(err)
 				return
 			}
+		case c == '-' && !l.nextIsDigit():
+			l.postToken(tMinus)
 		case c == '-' || l.isDigit(c):
 			l.unread()
 			if err := l.readNumber(); err != nil {
@@ -155,7 +200,43 @@ func (l *lexer) lex() {
 		case c == ')':
 			l.postToken(tRpar)
 		case c == '=':
-			l.postToken(tEq)
+			switch {
+			case l.acceptOne("="):
+				l.postToken(tEqEq)
+			case l.acceptOne("~"):
+				l.postToken(tMatch)
+			default:
+				l.postToken(tEq)
+			}
+		case c == '!':
+			switch {
+			case l.acceptOne("="):
+				l.postToken(tNotEq)
+			case l.acceptOne("~"):
+				l.postToken(tNotMatch)
+			default:
+				l.postToken(tNot)
+			}
+		case c == '+':
+			l.postToken(tPlus)
+		case c == '*':
+			l.postToken(tStar)
+		case c == '/':
+			l.postToken(tSlash)
+		case c == '%':
+			l.postToken(tPercent)
+		case c == '<':
+			if l.acceptOne("=") {
+				l.postToken(tLte)
+				continue
+			}
+			l.postToken(tLt)
+		case c == '>':
+			if l.acceptOne("=") {
+				l.postToken(tGte)
+				continue
+			}
+			l.postToken(tGt)
 		case c == ',':
 			l.postToken(tComma)
 		case c == '.':
@@ -252,6 +333,50 @@ func (l *lexer) readKeywords() error {
 		l.postToken(tTag)
 	case "join":
 		l.postToken(tJoin)
+	case "filter":
+		l.postToken(tFilter)
+	case "where":
+		l.postToken(tWhere)
+	case "or":
+		l.postToken(tOr)
+	case "not":
+		l.postToken(tNot)
+	case "has":
+		l.postToken(tHas)
+	case "tagged":
+		l.postToken(tTagged)
+	case "true":
+		l.postToken(tTrue)
+	case "false":
+		l.postToken(tFalse)
+	case "transform":
+		l.postToken(tTransform)
+	case "rename":
+		l.postToken(tRename)
+	case "parse":
+		l.postToken(tParse)
+	case "normalize":
+		l.postToken(tNormalize)
+	case "flatten":
+		l.postToken(tFlatten)
+	case "unflatten":
+		l.postToken(tUnflatten)
+	case "explode":
+		l.postToken(tExplode)
+	case "redact":
+		l.postToken(tRedact)
+	case "cluster":
+		l.postToken(tCluster)
+	case "parallel":
+		l.postToken(tParallel)
+	case "aggregate":
+		l.postToken(tAggregate)
+	case "dedup":
+		l.postToken(tDedup)
+	case "correlate":
+		l.postToken(tCorrelate)
+	case "pair":
+		l.postToken(tPair)
 	default:
 		l.reset()
 		if !l.readIdentifier() {
@@ -261,11 +386,47 @@ func (l *lexer) readKeywords() error {
 	return nil
 }
 
+// readIdentifier reads an identifier, which may include array indexes like "tags[0]", and backslash escapes like "k8s\.io" for field paths.
 func (l *lexer) readIdentifier() bool {
 	if !l.acceptOne(idStart) {
 		return false
 	}
-	l.accept(idRemainder)
+	for {
+		l.accept(idRemainder)
+		if l.acceptOne("\\") {
+			if _, err := l.read(); err != nil {
+				break
+			}
+			continue
+		}
+		if !l.acceptIndex() {
+			break
+		}
+	}
 	l.postToken(tIdentifier)
 	return true
 }
+
+// acceptIndex accepts an array index like "[0]" or "[-1]", or nothing if the input doesn't contain a complete index.
+func (l *lexer) acceptIndex() bool {
+	if !l.acceptOne("[") {
+		return false
+	}
+	read := 1
+	if l.acceptOne("-") {
+		read++
+	}
+	digits := 0
+	for l.nextIsDigit() {
+		_, _ = l.read()
+		digits++
+	}
+	read += digits
+	if digits > 0 && l.acceptOne("]") {
+		return true
+	}
+	for i := 0; i < read; i++ {
+		l.unread()
+	}
+	return false
+}
@@ -61,6 +61,22 @@ func TestLexIdentifierWithLeadingAt(t *testing.T) {
 	}
 }
 
+func TestLexIdentifierFieldPath(t *testing.T) {
+	l := lexString(`tags[0] items[-1][2] k8s\.io a.b`)
+	go l.lex()
+	tokens := consume(l.tokens)
+	assert.NoError(t, l.err)
+
+	expected := []lexType{tIdentifier, tIdentifier, tIdentifier, tIdentifier, tDot, tIdentifier, tEof}
+	require.Len(t, tokens, len(expected))
+	for i, tok := range tokens {
+		assert.Equalf(t, expected[i], tok.Type, "token %d mismatch: %s", i, tok.Text)
+	}
+	assert.Equal(t, "tags[0]", tokens[0].Text)
+	assert.Equal(t, "items[-1][2]", tokens[1].Text)
+	assert.Equal(t, `k8s\.io`, tokens[2].Text)
+}
+
 //go:embed test
 var script []byte
 
@@ -55,6 +55,7 @@ type Runtime struct {
 	sources   []iterator.Iterator
 	consumed  []bool
 	sourceIDs map[string]int
+	workers   map[string]int
 	wg        sync.WaitGroup
 	state     runtimeState
 	dryRun    bool
@@ -66,6 +67,7 @@ func NewRuntime(log hclog.Logger, plugins ...plugin.Plugin) *Runtime {
 		registry:  plugin.NewRegistration(),
 		plugins:   plugins,
 		sourceIDs: map[string]int{},
+		workers:   map[string]int{},
 	}
 }
 
@@ -111,6 +113,18 @@ func (r *Runtime) Stop() (rerr error) {
 	r.cancel()
 	log.Debug("Waiting for operations to cease")
 	r.wg.Wait()
+	log.Debug("Closing unconsumed sources")
+	for i, iter := range r.sources {
+		if r.consumed[i] || iter == nil {
+			continue
+		}
+		if err := iter.Close(); err != nil {
+			log.Error("Error closing source", "error", err)
+			if rerr == nil {
+				rerr = err
+			}
+		}
+	}
 	log.Debug("Shutting down plugins")
 	for _, p := range r.plugins {
 		log := log.With("plugin-id", p.ID())
@@ -217,26 +231,29 @@ func (r *Runtime) Execute(asts ...dsl.AstNode) error {
 				return err
 			}
 		case *dsl.Merge:
-			if err := r.validateExistingSourceID(ast.SourceA); err != nil {
-				log.Error("Invalid source", "error", err)
-				return err
-			}
-			if err := r.validateExistingSourceID(ast.SourceB); err != nil {
-				log.Error("Invalid source", "error", err)
-				return err
+			for _, src := range ast.Sources {
+				if err := r.validateExistingSourceID(src); err != nil {
+					log.Error("Invalid source", "error", err)
+					return err
+				}
 			}
 			if err := r.validateNewSourceID(ast.ID); err != nil {
 				log.Error("Invalid identifier", "error", err)
 				return err
 			}
-			r.markConsumed(ast.SourceA, ast.SourceB)
-			a := r.getSource(ast.SourceA)
-			b := r.getSource(ast.SourceB)
+			r.markConsumed(ast.Sources...)
+			srcs := make([]iterator.Iterator, len(ast.Sources))
+			for i, src := range ast.Sources {
+				srcs[i] = r.getSource(src)
+			}
 			var merged iterator.Iterator
-			if r.dryRun {
-				log.Info("Dry run merge", "source-a", ast.SourceA, "source-b", ast.SourceB, "output", ast.ID)
-			} else {
-				merged = iterator.Merge(a, b)
+			switch {
+			case r.dryRun:
+				log.Info("Dry run merge", "sources", ast.Sources, "output", ast.ID, "ordered", ast.Ordered)
+			case ast.Ordered:
+				merged = iterator.OrderedMerge(srcs, mergeOrderOpts(ast)...)
+			default:
+				merged = iterator.MergeAll(srcs...)
 			}
 			r.addSource(ast.ID, merged)
 		case *dsl.Dupe:
@@ -244,26 +261,23 @@ func (r *Runtime) Execute(asts ...dsl.AstNode) error {
 				log.Error("Invalid source", "error", err)
 				return err
 			}
-			if err := r.validateNewSourceID(ast.TargetA); err != nil {
-				log.Error("Invalid identifier", "error", err)
-				return err
-			}
-			if err := r.validateNewSourceID(ast.TargetB); err != nil {
-				log.Error("Invalid identifier", "error", err)
-				return err
+			for _, target := range ast.Targets {
+				if err := r.validateNewSourceID(target); err != nil {
+					log.Error("Invalid identifier", "error", err)
+					return err
+				}
 			}
 			src := r.getSource(ast.Source)
 			r.markConsumed(ast.Source)
-			var (
-				a, b iterator.Iterator
-			)
+			branches := make([]iterator.Iterator, len(ast.Targets))
 			if r.dryRun {
-				log.Info("Dry run dupe", "source", ast.Source, "output-a", ast.TargetA, "output-b", ast.TargetB)
+				log.Info("Dry run dupe", "source", ast.Source, "outputs", ast.Targets)
 			} else {
-				a, b = iterator.Dupe(src)
+				branches = iterator.DupeN(src, len(ast.Targets))
+			}
+			for i, target := range ast.Targets {
+				r.addSource(target, branches[i])
 			}
-			r.addSource(ast.TargetA, a)
-			r.addSource(ast.TargetB, b)
 		case *dsl.Append:
 			if err := r.validateExistingSourceID(ast.Source); err != nil {
 				log.Error("Invalid source", "error", err)
@@ -286,7 +300,6 @@ func (r *Runtime) Execute(asts ...dsl.AstNode) error {
 				log.Error("Invalid source", "error", err)
 				return err
 			}
-			src := r.getSource(ast.Source)
 			if r.dryRun {
 				log.Info("Dry run cut", "source", ast.Source, "mappings", ast.FieldSets, "delimiter", ast.Delimiter)
 				continue
@@ -296,36 +309,34 @@ func (r *Runtime) Execute(asts ...dsl.AstNode) error {
 				spec.Map(f, i)
 			}
 
-			cut := iterator.Cutter(src,
-				entries.CutDelim(ast.Delimiter),
-				entries.CutCollector(spec.Collector()),
-			)
-			r.replaceSource(ast.Source, cut)
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Cutter(src,
+					entries.CutDelim(ast.Delimiter),
+					entries.CutCollector(spec.Collector()),
+				)
+			})
 		case *dsl.Fanout:
 			if err := r.validateExistingSourceID(ast.Source); err != nil {
 				log.Error("Invalid source", "error", err)
 				return err
 			}
-			if err := r.validateNewSourceID(ast.TargetA); err != nil {
-				log.Error("Invalid identifier", "error", err)
-				return err
-			}
-			if err := r.validateNewSourceID(ast.TargetB); err != nil {
-				log.Error("Invalid identifier", "error", err)
-				return err
+			for _, target := range ast.Targets {
+				if err := r.validateNewSourceID(target); err != nil {
+					log.Error("Invalid identifier", "error", err)
+					return err
+				}
 			}
 			src := r.getSource(ast.Source)
 			r.markConsumed(ast.Source)
-			var (
-				a, b iterator.Iterator
-			)
+			branches := make([]iterator.Iterator, len(ast.Targets))
 			if r.dryRun {
-				log.Info("Dry run fanout", "source", ast.Source, "output-a", ast.TargetA, "output-b", ast.TargetB)
+				log.Info("Dry run fanout", "source", ast.Source, "outputs", ast.Targets, "strategy", ast.Strategy, "field", ast.Field)
 			} else {
-				a, b = iterator.Fanout(src)
+				branches = iterator.FanoutN(src, len(ast.Targets), fanoutOpts(ast)...)
+			}
+			for i, target := range ast.Targets {
+				r.addSource(target, branches[i])
 			}
-			r.addSource(ast.TargetA, a)
-			r.addSource(ast.TargetB, b)
 		case *dsl.Tag:
 			if err := r.validateExistingSourceID(ast.Source); err != nil {
 				log.Error("Invalid source", "error", err)
@@ -343,12 +354,378 @@ func (r *Runtime) Execute(asts ...dsl.AstNode) error {
 				return err
 			}
 			if r.dryRun {
-				log.Info("Dry run join", "source", ast.Source, "patterns", ast.Patterns)
+				log.Info("Dry run join", "source", ast.Source, "patterns", ast.Patterns, "continuation", ast.Continuation, "groupBy", ast.GroupBy, "idle", ast.Idle)
 				continue
 			}
 			src := r.getSource(ast.Source)
-			src = iterator.Joiner(src, ast.Patterns...)
+			src = iterator.JoinerWith(src, joinOpts(ast)...)
 			r.replaceSource(ast.Source, src)
+		case *dsl.Filter:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run filter", "source", ast.Source, "expr", ast.Expr.String())
+				continue
+			}
+			expr := ast.Expr
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Filter(src, func(entry entries.LogEntry, _ int, _ error) bool {
+					return dsl.Matches(expr, entry)
+				})
+			})
+		case *dsl.Route:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			ids := make([]string, 0, len(ast.Branches)+1)
+			for _, b := range ast.Branches {
+				ids = append(ids, b.ID)
+			}
+			if len(ast.Default) > 0 {
+				ids = append(ids, ast.Default)
+			}
+			for _, id := range ids {
+				if err := r.validateNewSourceID(id); err != nil {
+					log.Error("Invalid identifier", "error", err)
+					return err
+				}
+			}
+			src := r.getSource(ast.Source)
+			r.markConsumed(ast.Source)
+			if r.dryRun {
+				log.Info("Dry run route", "source", ast.Source, "outputs", ids, "all", ast.All)
+				for _, id := range ids {
+					r.addSource(id, nil)
+				}
+				continue
+			}
+			outputs, err := iterator.Router(src, routes(ast), routerOpts(ast)...)
+			if err != nil {
+				log.Error("Failed to create router", "error", err)
+				return err
+			}
+			for _, id := range ids {
+				r.addSource(id, outputs[id])
+			}
+		case *dsl.Transform:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				assignments := make([]string, len(ast.Assignments))
+				for i, a := range ast.Assignments {
+					assignments[i] = a.Field + " = " + a.Expr.String()
+				}
+				log.Info("Dry run transform", "source", ast.Source, "assignments", assignments)
+				continue
+			}
+			// Each assignment is its own Transformer, so it sees the results of the assignments before it.
+			specs := make([]entries.TransformSpec, len(ast.Assignments))
+			for i, a := range ast.Assignments {
+				specs[i] = entries.NewTransformSpec().Compute(entries.SubjectField(a.Field), a.Expr.Eval)
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				for _, spec := range specs {
+					src = iterator.Transformer(src, spec)
+				}
+				return src
+			})
+		case *dsl.Rename:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run rename", "source", ast.Source, "renames", ast.Renames)
+				continue
+			}
+			spec := entries.NewReassignSpec()
+			for _, rn := range ast.Renames {
+				spec.Move(entries.SourceField(rn.From), entries.TargetField(rn.To))
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Reassigner(src, spec)
+			})
+		case *dsl.Drop:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run drop", "source", ast.Source, "fields", ast.Fields)
+				continue
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Dropper(src, ast.Fields...)
+			})
+		case *dsl.Keep:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run keep", "source", ast.Source, "fields", ast.Fields)
+				continue
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Keeper(src, ast.Fields...)
+			})
+		case *dsl.Flatten:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run flatten", "source", ast.Source, "separator", ast.Separator, "arrays", ast.Arrays)
+				continue
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Flattener(src, flattenOpts(ast.Separator, ast.Arrays)...)
+			})
+		case *dsl.Unflatten:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run unflatten", "source", ast.Source, "separator", ast.Separator, "arrays", ast.Arrays)
+				continue
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Unflattener(src, flattenOpts(ast.Separator, ast.Arrays)...)
+			})
+		case *dsl.Explode:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run explode", "source", ast.Source, "field", ast.Field, "index", ast.IndexField)
+				continue
+			}
+			var opts []entries.ExplodeOpt
+			if len(ast.IndexField) > 0 {
+				opts = append(opts, entries.ExplodeIndex(ast.IndexField))
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Exploder(src, ast.Field, opts...)
+			})
+		case *dsl.Redact:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run redact", "source", ast.Source, "fields", ast.Fields, "detectors", ast.Detectors, "patterns", ast.Patterns, "mode", ast.Mode)
+				continue
+			}
+			opts, err := redactOpts(ast)
+			if err != nil {
+				log.Error("Failed to create redaction options", "error", err)
+				return err
+			}
+			redactor, err := entries.NewRedactor(opts...)
+			if err != nil {
+				log.Error("Failed to create redactor", "error", err)
+				return err
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Redactor(src, redactor)
+			})
+		case *dsl.Cluster:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run cluster", "source", ast.Source, "field", ast.Field, "depth", ast.Depth, "similarity", ast.Similarity, "summary", ast.Summary)
+				continue
+			}
+			src := r.getSource(ast.Source)
+			src = iterator.Templates(src, iterator.NewTemplateMiner(r.templateOpts(ast)...))
+			r.replaceSource(ast.Source, src)
+		case *dsl.Parse:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run parse", "source", ast.Source, "format", ast.Format, "args", ast.Args, "field", ast.Field)
+				continue
+			}
+			parser, err := entries.NewParser(ast.Format, ast.Args...)
+			if err != nil {
+				log.Error("Failed to create parser", "error", err)
+				return err
+			}
+			parse := func(src iterator.Iterator) iterator.Iterator {
+				return iterator.Parser(src, parser, entries.ParseField(ast.Field))
+			}
+			if ast.Format == "csv" && len(ast.Args) == 0 {
+				// The CSV header is read from the first line, so entries must be parsed in order on one goroutine.
+				r.replaceSource(ast.Source, parse(r.getSource(ast.Source)))
+				continue
+			}
+			r.stage(ast.Source, parse)
+		case *dsl.NormalizeTimestamp:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run normalize timestamp", "source", ast.Source, "fields", ast.Fields, "layouts", ast.Layouts, "zone", ast.Zone)
+				continue
+			}
+			var opts []entries.TimestampOpt
+			if len(ast.Fields) > 0 {
+				opts = append(opts, entries.TimestampFields(ast.Fields...))
+			}
+			if len(ast.Layouts) > 0 {
+				opts = append(opts, entries.TimestampLayouts(ast.Layouts...))
+			}
+			if len(ast.Zone) > 0 {
+				loc, err := time.LoadLocation(ast.Zone)
+				if err != nil {
+					log.Error("Invalid time zone", "error", err)
+					return err
+				}
+				opts = append(opts, entries.TimestampLocation(loc))
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.TimestampNormalizer(src, opts...)
+			})
+		case *dsl.NormalizeLevel:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run normalize level", "source", ast.Source, "fields", ast.Fields, "mappings", ast.Mappings, "custom", ast.Custom)
+				continue
+			}
+			var opts []entries.LevelOpt
+			if len(ast.Fields) > 0 {
+				opts = append(opts, entries.LevelFields(ast.Fields...))
+			}
+			if len(ast.Custom) > 0 {
+				custom := entries.LevelMapping{}
+				for raw, name := range ast.Custom {
+					level, ok := entries.ParseLevelName(name)
+					if !ok {
+						err := fmt.Errorf("%w '%s'", dsl.ErrUnknownLevel, name)
+						log.Error("Invalid level", "error", err)
+						return err
+					}
+					custom[raw] = level
+				}
+				opts = append(opts, entries.LevelMappings(custom))
+			}
+			for _, name := range ast.Mappings {
+				mapping, ok := entries.NamedLevelMapping(name)
+				if !ok {
+					err := fmt.Errorf("%w '%s'", dsl.ErrUnknownLevelMapping, name)
+					log.Error("Invalid level mapping", "error", err)
+					return err
+				}
+				opts = append(opts, entries.LevelMappings(mapping))
+			}
+			r.stage(ast.Source, func(src iterator.Iterator) iterator.Iterator {
+				return iterator.LevelNormalizer(src, opts...)
+			})
+		case *dsl.Parallel:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run parallel", "source", ast.Source, "workers", ast.Workers)
+				continue
+			}
+			r.workers[ast.Source] = ast.Workers
+		case *dsl.Aggregate:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if err := r.validateNewSourceID(ast.ID); err != nil {
+				log.Error("Invalid identifier", "error", err)
+				return err
+			}
+			r.markConsumed(ast.Source)
+			if r.dryRun {
+				log.Info("Dry run aggregate", "source", ast.Source, "id", ast.ID, "groupBy", ast.GroupBy, "window", ast.Window, "slide", ast.Slide)
+				r.addSource(ast.ID, nil)
+				continue
+			}
+			aggs, err := aggregates(ast)
+			if err != nil {
+				log.Error("Invalid aggregate", "error", err)
+				return err
+			}
+			agg, err := iterator.Aggregator(r.getSource(ast.Source), aggs, aggregateOpts(ast)...)
+			if err != nil {
+				log.Error("Failed to create aggregator", "error", err)
+				return err
+			}
+			r.addSource(ast.ID, agg)
+		case *dsl.Dedup:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if r.dryRun {
+				log.Info("Dry run dedup", "source", ast.Source, "fields", ast.Fields, "window", ast.Window, "limit", ast.Limit)
+				continue
+			}
+			r.replaceSource(ast.Source, iterator.Deduplicator(r.getSource(ast.Source), dedupOpts(ast)...))
+		case *dsl.Correlate:
+			if err := r.validateExistingSourceID(ast.Source); err != nil {
+				log.Error("Invalid source", "error", err)
+				return err
+			}
+			if err := r.validateNewSourceID(ast.ID); err != nil {
+				log.Error("Invalid identifier", "error", err)
+				return err
+			}
+			r.markConsumed(ast.Source)
+			if r.dryRun {
+				log.Info("Dry run correlate", "source", ast.Source, "id", ast.ID, "key", ast.Key, "timeout", ast.Timeout, "maxSize", ast.MaxSize)
+				r.addSource(ast.ID, nil)
+				continue
+			}
+			r.addSource(ast.ID, iterator.Correlator(r.getSource(ast.Source), ast.Key, correlateOpts(ast)...))
+		case *dsl.Pair:
+			for _, src := range []string{ast.Left, ast.Right} {
+				if err := r.validateExistingSourceID(src); err != nil {
+					log.Error("Invalid source", "error", err)
+					return err
+				}
+			}
+			if err := r.validateNewSourceID(ast.ID); err != nil {
+				log.Error("Invalid identifier", "error", err)
+				return err
+			}
+			r.markConsumed(ast.Left, ast.Right)
+			if r.dryRun {
+				log.Info("Dry run pair", "left", ast.Left, "right", ast.Right, "id", ast.ID, "key", ast.Key, "window", ast.Window, "mode", ast.Mode)
+				r.addSource(ast.ID, nil)
+				continue
+			}
+			opts, err := pairOpts(ast)
+			if err != nil {
+				log.Error("Invalid pair", "error", err)
+				return err
+			}
+			paired, err := iterator.Pair(r.getSource(ast.Left), r.getSource(ast.Right), ast.Key, opts...)
+			if err != nil {
+				log.Error("Failed to create pair", "error", err)
+				return err
+			}
+			r.addSource(ast.ID, paired)
 		case *dsl.Eol:
 		default:
 			err := fmt.Errorf("likely bug, unhandled AST [%d] at line %d: %s", ast.Type(), ast.Line(), ast.Text())
@@ -423,6 +800,17 @@ func (r *Runtime) replaceSource(id string, iter iterator.Iterator) {
 	r.sources[r.sourceIDs[id]] = iter
 }
 
+// stage applies a stateless operation to a source, running it on a pool of workers if one was set for the source with the parallel statement.
+func (r *Runtime) stage(id string, stage iterator.Stage) {
+	src := r.getSource(id)
+	if workers := r.workers[id]; workers > 1 {
+		src = iterator.Parallel(src, workers, stage)
+	} else {
+		src = stage(src)
+	}
+	r.replaceSource(id, src)
+}
+
 func (r *Runtime) markConsumed(ids ...string) {
 	for _, id := range ids {
 		i := r.sourceIDs[id]
@@ -441,3 +829,230 @@ func (r *Runtime) DryRun(ast ...dsl.AstNode) error {
 func emptyID(id string) bool {
 	return len(strings.TrimSpace(id)) == 0
 }
+
+func flattenOpts(separator string, arrays bool) []entries.FlattenOpt {
+	var opts []entries.FlattenOpt
+	if len(separator) > 0 {
+		opts = append(opts, entries.FlattenSeparator(separator))
+	}
+	if arrays {
+		opts = append(opts, entries.FlattenArrays())
+	}
+	return opts
+}
+
+func redactOpts(ast *dsl.Redact) ([]entries.RedactOpt, error) {
+	var detectors []*entries.Detector
+	for _, name := range ast.Detectors {
+		d, ok := entries.NamedDetector(name)
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", dsl.ErrUnknownDetector, name)
+		}
+		detectors = append(detectors, d)
+	}
+	for _, pattern := range ast.Patterns {
+		d, err := entries.NewRegexDetector("", pattern)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, d)
+	}
+	mode, ok := entries.ParseMaskMode(ast.Mode)
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", dsl.ErrUnknownMaskMode, ast.Mode)
+	}
+	opts := []entries.RedactOpt{
+		entries.RedactDetectors(detectors...),
+		entries.RedactMask(mode),
+		entries.RedactFields(ast.Fields...),
+	}
+	if len(ast.Key) > 0 {
+		opts = append(opts, entries.RedactKey([]byte(ast.Key)))
+	}
+	return opts, nil
+}
+
+func (r *Runtime) templateOpts(ast *dsl.Cluster) []iterator.TemplateOpt {
+	var opts []iterator.TemplateOpt
+	if len(ast.Field) > 0 {
+		opts = append(opts, iterator.TemplateFrom(ast.Field))
+	}
+	if ast.Depth > 0 {
+		opts = append(opts, iterator.TemplateDepth(ast.Depth))
+	}
+	if ast.Similarity > 0 {
+		opts = append(opts, iterator.TemplateSimilarity(ast.Similarity))
+	}
+	if ast.Summary {
+		source := ast.Source
+		opts = append(opts, iterator.TemplateSummary(func(templates []iterator.Template) {
+			r.log.Info("Template summary", "source", source, "templates", len(templates))
+			for _, t := range templates {
+				r.log.Info("Template", "source", source, "id", t.ID, "count", t.Count, "template", t.Template)
+			}
+		}))
+	}
+	return opts
+}
+
+func mergeOrderOpts(ast *dsl.Merge) []iterator.OrderedOpt {
+	var opts []iterator.OrderedOpt
+	if len(ast.OrderField) > 0 {
+		opts = append(opts, iterator.OrderField(ast.OrderField))
+	}
+	switch {
+	case ast.Tolerance > 0:
+		opts = append(opts, iterator.OrderTolerance(ast.Tolerance))
+	case ast.Sorted:
+		opts = append(opts, iterator.OrderSorted())
+	}
+	return opts
+}
+
+func routes(ast *dsl.Route) []iterator.Route {
+	routes := make([]iterator.Route, len(ast.Branches))
+	for i, b := range ast.Branches {
+		expr := b.Expr
+		routes[i] = iterator.Route{
+			Name: b.ID,
+			Match: func(entry entries.LogEntry) bool {
+				return dsl.Matches(expr, entry)
+			},
+		}
+	}
+	return routes
+}
+
+func routerOpts(ast *dsl.Route) []iterator.RouterOpt {
+	var opts []iterator.RouterOpt
+	if ast.All {
+		opts = append(opts, iterator.RouteAllMatches())
+	}
+	if len(ast.Default) > 0 {
+		opts = append(opts, iterator.RouteDefault(ast.Default))
+	}
+	return opts
+}
+
+func fanoutOpts(ast *dsl.Fanout) []iterator.FanoutOpt {
+	switch ast.Strategy {
+	case dsl.FanoutHash:
+		return []iterator.FanoutOpt{iterator.FanoutHash(ast.Field)}
+	case dsl.FanoutRoundRobin:
+		return []iterator.FanoutOpt{iterator.FanoutRoundRobin()}
+	case dsl.FanoutLeastLoaded:
+		return []iterator.FanoutOpt{iterator.FanoutLeastLoaded()}
+	}
+	return nil
+}
+
+func aggregates(ast *dsl.Aggregate) ([]iterator.Aggregate, error) {
+	aggs := make([]iterator.Aggregate, len(ast.Values))
+	for i, v := range ast.Values {
+		fn, ok := iterator.ParseAggregateFunc(v.Func)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown function '%s'", dsl.ErrInvalidAggregate, v.Func)
+		}
+		aggs[i] = iterator.Aggregate{
+			Func:       fn,
+			Field:      v.Field,
+			Percentile: v.Percentile,
+			As:         v.As,
+		}
+	}
+	return aggs, nil
+}
+
+func aggregateOpts(ast *dsl.Aggregate) []iterator.AggregateOpt {
+	opts := []iterator.AggregateOpt{iterator.AggregateWindow(ast.Window)}
+	if ast.Slide > 0 {
+		opts = append(opts, iterator.AggregateSliding(ast.Window, ast.Slide))
+	}
+	if len(ast.GroupBy) > 0 {
+		opts = append(opts, iterator.AggregateGroupBy(ast.GroupBy...))
+	}
+	switch {
+	case ast.Arrival:
+		opts = append(opts, iterator.AggregateArrivalTime())
+	case len(ast.TimeField) > 0:
+		opts = append(opts, iterator.AggregateTimeField(ast.TimeField))
+	}
+	if ast.Lateness > 0 {
+		opts = append(opts, iterator.AggregateLateness(ast.Lateness))
+	}
+	return opts
+}
+
+func joinOpts(ast *dsl.Join) []iterator.JoinOpt {
+	var opts []iterator.JoinOpt
+	if ast.Continuation {
+		opts = append(opts, iterator.JoinContinuation(ast.Patterns...))
+	} else {
+		opts = append(opts, iterator.JoinStart(ast.Patterns...))
+	}
+	if len(ast.GroupBy) > 0 {
+		opts = append(opts, iterator.JoinGroupBy(ast.GroupBy))
+	}
+	if ast.Idle > 0 {
+		opts = append(opts, iterator.JoinIdleTimeout(ast.Idle))
+	}
+	if ast.MaxLines > 0 {
+		opts = append(opts, iterator.JoinMaxLines(ast.MaxLines))
+	}
+	if ast.MaxBytes > 0 {
+		opts = append(opts, iterator.JoinMaxBytes(ast.MaxBytes))
+	}
+	return opts
+}
+
+func dedupOpts(ast *dsl.Dedup) []iterator.DedupOpt {
+	var opts []iterator.DedupOpt
+	if len(ast.Fields) > 0 {
+		opts = append(opts, iterator.DedupFields(ast.Fields...))
+	}
+	if len(ast.Ignore) > 0 {
+		opts = append(opts, iterator.DedupIgnore(ast.Ignore...))
+	}
+	if ast.Window > 0 {
+		opts = append(opts, iterator.DedupWindow(ast.Window))
+	}
+	if ast.Limit > 0 {
+		opts = append(opts, iterator.DedupLimit(ast.Limit))
+	}
+	return opts
+}
+
+func correlateOpts(ast *dsl.Correlate) []iterator.CorrelateOpt {
+	var opts []iterator.CorrelateOpt
+	if len(ast.TimeField) > 0 {
+		opts = append(opts, iterator.CorrelateTimeField(ast.TimeField))
+	}
+	if ast.Timeout > 0 {
+		opts = append(opts, iterator.CorrelateTimeout(ast.Timeout))
+	}
+	if ast.MaxSize > 0 {
+		opts = append(opts, iterator.CorrelateMaxSize(ast.MaxSize))
+	}
+	if ast.End != nil {
+		end := ast.End
+		opts = append(opts, iterator.CorrelateEnd(func(entry entries.LogEntry) bool {
+			return dsl.Matches(end, entry)
+		}))
+	}
+	return opts
+}
+
+func pairOpts(ast *dsl.Pair) ([]iterator.PairOpt, error) {
+	mode, ok := iterator.ParsePairMode(ast.Mode)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown pair mode '%s'", iterator.ErrInvalidPair, ast.Mode)
+	}
+	opts := []iterator.PairOpt{iterator.PairWindow(ast.Window), iterator.PairWith(mode)}
+	if len(ast.RightKey) > 0 {
+		opts = append(opts, iterator.PairRightKey(ast.RightKey))
+	}
+	if ast.Prefixed {
+		opts = append(opts, iterator.PairPrefix(ast.LeftPrefix, ast.RightPrefix))
+	}
+	return opts, nil
+}
@@ -2,12 +2,16 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/hashicorp/go-hclog"
 	"github.com/saylorsolutions/nomlog/plugin/file"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -84,3 +88,602 @@ sink src to file.File "` + output + `"
 	assert.True(t, len(data) > 0, "Data length should be greater than 0")
 	t.Log(string(data))
 }
+
+func TestFilter(t *testing.T) {
+	r, dir := _testRuntime(t)
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "data.json"
+filter src where has b or a == "nope"
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"b":"b"`)
+}
+
+func TestTransform(t *testing.T) {
+	r, dir := _testRuntime(t)
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "data.json"
+filter src where has a
+transform src set(upper_a = upper(a), doubled = upper_a + upper_a, missing = nope)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"doubled":"AA"`)
+	assert.Contains(t, string(data), `"upper_a":"A"`)
+	assert.NotContains(t, string(data), `"missing"`)
+}
+
+func TestProjection(t *testing.T) {
+	r, dir := _testRuntime(t)
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "data.json"
+filter src where has a
+rename src set(a=renamed)
+drop src fields(@read_timestamp)
+keep src fields(renamed, @read_line_number)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"renamed":"a","@read_line_number":0}`, string(data))
+}
+
+func TestParse(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("level=info msg=started\nnot structured\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+parse src as logfmt
+keep src fields(level, msg, @parse_error)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"level":"info","msg":"started"}`, lines[0])
+	assert.JSONEq(t, `{"@parse_error":"no key/value pairs found"}`, lines[1])
+}
+
+func TestNormalizeTimestamp(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"ts":1680350400123}`+"\n"+`{"time":"2023/04/01 07:00:00"}`+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+normalize src timestamp fields(ts, time) in "America/Chicago"
+keep src fields(@timestamp)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"@timestamp":"2023-04-01T12:00:00.123Z"}`, lines[0])
+	assert.JSONEq(t, `{"@timestamp":"2023-04-01T12:00:00Z"}`, lines[1])
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"lvl":30}`+"\n"+`{"lvl":50}`+"\n"+`{"lvl":"LOUD"}`+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+normalize src level fields(lvl) with "pino" set(LOUD=fatal)
+filter src where @level >= "warn"
+keep src fields(@level)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"@level":"error"}`, lines[0])
+	assert.JSONEq(t, `{"@level":"fatal"}`, lines[1])
+}
+
+func TestFieldPaths(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"http":{"status":500,"path":"/a"},"tags":["x","y"]}`+"\n"+`{"http":{"status":200,"path":"/b"}}`+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+filter src where http.status >= 500
+transform src set(http.failed = true, first_tag = tags[0])
+rename src set(http.path=request.path)
+keep src fields(http, request.path, first_tag)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"http":{"status":500,"failed":true},"request":{"path":"/a"},"first_tag":"x"}`, string(data))
+}
+
+func TestFlattenExplode(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"id":1,"items":[{"sku":"a","qty":1},{"sku":"b","qty":2}]}`+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+explode src items index n
+flatten src with "_"
+keep src fields(id, n, items_sku, items_qty)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"id":1,"n":0,"items_sku":"a","items_qty":1}`, lines[0])
+	assert.JSONEq(t, `{"id":1,"n":1,"items_sku":"b","items_qty":2}`, lines[1])
+}
+
+func TestRedact(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"user":"jo@example.com","client":"10.0.0.1","msg":"login password=hunter2 from jo@example.com"}`+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+redact src fields(msg) match "password=(\\S+)"
+redact src with "email", "ipv4" as partial
+keep src fields(user, client, msg)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	assert.JSONEq(t, `{"user":"j*@example.com","client":"10.0.*.*","msg":"login password=[REDACTED:regex] from j*@example.com"}`, lines[0])
+}
+
+func TestCluster(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"msg":"request 1 took 5ms"}
+{"msg":"request 2 took 9ms"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+cluster src from msg summary
+keep src fields(@template_id, @template, @template_params)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"@template_id":1,"@template":"request 1 took 5ms","@template_params":[]}`, lines[0])
+	assert.JSONEq(t, `{"@template_id":1,"@template":"request <*> took <*>","@template_params":["2","9ms"]}`, lines[1])
+}
+
+func TestMergeDupeLists(t *testing.T) {
+	r, dir := _testRuntime(t)
+	var inputs []string
+	for _, name := range []string{"a", "b", "c"} {
+		input := filepath.Join(dir, name+".json")
+		require.NoError(t, os.WriteFile(input, []byte(`{"src":"`+name+`"}`+"\n"), 0600))
+		inputs = append(inputs, input)
+	}
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as a file.File "` + inputs[0] + `"
+source as b file.File "` + inputs[1] + `"
+source as c file.File "` + inputs[2] + `"
+merge a, b, c as all
+dupe all as x, y, z
+tag x with "x"
+tag y with "y"
+tag z with "z"
+merge x, y, z as out
+keep out fields(src, @tag)
+sink out to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 9)
+	counts := map[string]int{}
+	for _, line := range lines {
+		counts[line]++
+	}
+	for _, src := range []string{"a", "b", "c"} {
+		for _, tag := range []string{"x", "y", "z"} {
+			assert.Equal(t, 1, counts[`{"@tag":"`+tag+`","src":"`+src+`"}`], "Expected one entry from %s tagged %s", src, tag)
+		}
+	}
+}
+
+func TestOrderedMerge(t *testing.T) {
+	r, dir := _testRuntime(t)
+	app := filepath.Join(dir, "app.json")
+	require.NoError(t, os.WriteFile(app, []byte(`{"src":"app","ts":"2023-01-01T00:00:01Z"}
+{"src":"app","ts":"2023-01-01T00:00:04Z"}
+`), 0600))
+	proxy := filepath.Join(dir, "proxy.json")
+	require.NoError(t, os.WriteFile(proxy, []byte(`{"src":"proxy","ts":"2023-01-01T00:00:03Z"}
+{"src":"proxy","ts":"2023-01-01T00:00:02Z"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as app file.File "` + app + `"
+source as proxy file.File "` + proxy + `"
+merge app, proxy as all ordered by ts sorted
+keep all fields(src, ts)
+sink all to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"src":"app","ts":"2023-01-01T00:00:01Z"}`, lines[0])
+	assert.JSONEq(t, `{"src":"proxy","ts":"2023-01-01T00:00:02Z"}`, lines[1])
+	assert.JSONEq(t, `{"src":"proxy","ts":"2023-01-01T00:00:03Z"}`, lines[2])
+	assert.JSONEq(t, `{"src":"app","ts":"2023-01-01T00:00:04Z"}`, lines[3])
+}
+
+func TestAsyncSink(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	var lines []string
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf(`{"seq":%d}`, i))
+	}
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+sink src async as srcSink to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	_waitForLines(t, 500, output)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 500, "The async sink should write every entry")
+}
+
+func TestRoute(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"@message":"started","@level":"info"}
+{"@message":"disk full","@level":"error"}
+{"@message":"GET /","@tag":"access"}
+{"@message":"denied","@level":"error","@tag":"access"}
+`), 0600))
+	errorsOut := filepath.Join(dir, "errors.json")
+	accessOut := filepath.Join(dir, "access.json")
+	otherOut := filepath.Join(dir, "other.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+route src to errs where @level == "error", access where tagged "access" default other
+keep errs fields(@message)
+keep access fields(@message)
+keep other fields(@message)
+sink errs async as errSink to file.File "` + errorsOut + `"
+sink access async as accessSink to file.File "` + accessOut + `"
+sink other to file.File "` + otherOut + `"
+`)
+	assert.NoError(t, err)
+	_waitForLines(t, 4, errorsOut, accessOut, otherOut)
+	require.NoError(t, r.Stop())
+
+	for path, expected := range map[string][]string{
+		errorsOut: {`{"@message":"disk full"}`, `{"@message":"denied"}`},
+		accessOut: {`{"@message":"GET /"}`},
+		otherOut:  {`{"@message":"started"}`},
+	} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, len(expected), path)
+		for i, line := range lines {
+			assert.JSONEq(t, expected[i], line)
+		}
+	}
+}
+
+func TestFanoutHash(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf(`{"service":"svc-%d"}`, i%5))
+	}
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+fanout src as a, b by service
+sink a async as aSink to file.File "` + first + `"
+sink b to file.File "` + second + `"
+`)
+	assert.NoError(t, err)
+	_waitForLines(t, 50, first, second)
+	require.NoError(t, r.Stop())
+
+	owner := map[string]string{}
+	total := 0
+	for _, path := range []string{first, second} {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if len(line) == 0 {
+				continue
+			}
+			var entry map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			svc := entry["service"].(string)
+			if prev, ok := owner[svc]; ok {
+				assert.Equal(t, prev, path, "Entries for %s should all be in the same output", svc)
+			}
+			owner[svc] = path
+			total++
+		}
+	}
+	assert.Equal(t, 50, total)
+	assert.Len(t, owner, 5)
+}
+
+func TestParallel(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.txt")
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	require.NoError(t, os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+parallel src with 4
+cut src set(seq=1)
+transform src set(next = seq + 1)
+keep src fields(seq, next)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	result := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, result, 100)
+	for i, line := range result {
+		assert.JSONEq(t, fmt.Sprintf(`{"seq":"%d","next":%d}`, i, i+1), line, "Entries should stay in order")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"@timestamp":"2023-01-01T00:00:10Z","service":"api","@level":"error","latency":10}
+{"@timestamp":"2023-01-01T00:00:20Z","service":"api","@level":"error","latency":30}
+{"@timestamp":"2023-01-01T00:00:30Z","service":"db","@level":"error","latency":5}
+{"@timestamp":"2023-01-01T00:01:10Z","service":"api","@level":"error","latency":7}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+filter src where @level == "error"
+aggregate src as per_minute count as errors, max(latency) by service every "1m"
+sink per_minute to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []string{
+		`{"@timestamp":"2023-01-01T00:00:00Z","@window_start":"2023-01-01T00:00:00Z","@window_end":"2023-01-01T00:01:00Z","service":"api","errors":2,"max_latency":30}`,
+		`{"@timestamp":"2023-01-01T00:00:00Z","@window_start":"2023-01-01T00:00:00Z","@window_end":"2023-01-01T00:01:00Z","service":"db","errors":1,"max_latency":5}`,
+		`{"@timestamp":"2023-01-01T00:01:00Z","@window_start":"2023-01-01T00:01:00Z","@window_end":"2023-01-01T00:02:00Z","service":"api","errors":1,"max_latency":7}`,
+	}
+	require.Len(t, lines, len(expected))
+	for i, line := range lines {
+		assert.JSONEq(t, expected[i], line)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"@message":"connection refused","attempt":1}
+{"@message":"connection refused","attempt":2}
+{"@message":"connection refused","attempt":3}
+{"@message":"shutting down"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+dedup src fields(@message)
+drop src fields(@first_seen, @last_seen, @read_timestamp, @read_line_number)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []string{
+		`{"@message":"connection refused","attempt":1}`,
+		`{"@message":"shutting down"}`,
+		`{"@message":"connection refused","attempt":3,"@repeat_count":2}`,
+	}
+	require.Len(t, lines, len(expected))
+	for i, line := range lines {
+		assert.JSONEq(t, expected[i], line)
+	}
+}
+
+func TestJoinByThread(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"@message":"java.lang.NullPointerException","thread":"worker-1"}
+{"@message":"java.io.IOException","thread":"worker-2"}
+{"@message":"  at com.example.A","thread":"worker-1"}
+{"@message":"  at com.example.B","thread":"worker-2"}
+{"@message":"  at com.example.C","thread":"worker-1"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+join src with continuation "^\\s+at " by thread max 2 lines
+keep src fields(@message, thread)
+sink src to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []string{
+		`{"@message":"java.lang.NullPointerException\n  at com.example.A","thread":"worker-1"}`,
+		`{"@message":"java.io.IOException\n  at com.example.B","thread":"worker-2"}`,
+		`{"@message":"  at com.example.C","thread":"worker-1"}`,
+	}
+	require.Len(t, lines, len(expected))
+	for i, line := range lines {
+		assert.JSONEq(t, expected[i], line)
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	r, dir := _testRuntime(t)
+	input := filepath.Join(dir, "input.json")
+	require.NoError(t, os.WriteFile(input, []byte(`{"@timestamp":"2023-01-01T00:00:00Z","request_id":"r1","@level":"info","@message":"request started"}
+{"@timestamp":"2023-01-01T00:00:00.250Z","request_id":"r2","@level":"info","@message":"request started"}
+{"@timestamp":"2023-01-01T00:00:01Z","request_id":"r1","@level":"error","@message":"query failed"}
+{"@timestamp":"2023-01-01T00:00:01.500Z","request_id":"r1","@level":"info","@message":"request completed"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as src file.File "` + input + `"
+correlate src as requests by request_id end where @message == "request completed"
+drop requests fields(@events)
+sink requests to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []string{
+		`{"request_id":"r1","@timestamp":"2023-01-01T00:00:00Z","@first_timestamp":"2023-01-01T00:00:00Z","@last_timestamp":"2023-01-01T00:00:01.5Z","@duration_ms":1500,"@level":"error","@event_count":3,"@closed_by":"end"}`,
+		`{"request_id":"r2","@timestamp":"2023-01-01T00:00:00.25Z","@first_timestamp":"2023-01-01T00:00:00.25Z","@last_timestamp":"2023-01-01T00:00:00.25Z","@duration_ms":0,"@level":"info","@event_count":1,"@closed_by":"input_end"}`,
+	}
+	require.Len(t, lines, len(expected))
+	for i, line := range lines {
+		assert.JSONEq(t, expected[i], line)
+	}
+}
+
+func TestPair(t *testing.T) {
+	r, dir := _testRuntime(t)
+	proxy := filepath.Join(dir, "proxy.json")
+	require.NoError(t, os.WriteFile(proxy, []byte(`{"request_id":"r1","@message":"GET /orders 500"}
+{"request_id":"r2","@message":"GET /health 200"}
+`), 0600))
+	app := filepath.Join(dir, "app.json")
+	require.NoError(t, os.WriteFile(app, []byte(`{"request_id":"r1","@message":"order lookup failed","user":"alice"}
+`), 0600))
+	output := filepath.Join(dir, "output.json")
+	err := r.ExecuteString(`
+source as proxy file.File "` + proxy + `"
+source as app file.File "` + app + `"
+pair proxy, app as enriched on request_id within "1m" left
+drop enriched fields(@read_timestamp, @read_line_number, right.@read_timestamp, right.@read_line_number)
+sink enriched to file.File "` + output + `"
+`)
+	assert.NoError(t, err)
+	require.NoError(t, r.Stop())
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	sort.Strings(lines)
+	assert.JSONEq(t, `{"request_id":"r2","@message":"GET /health 200"}`, lines[0])
+	assert.JSONEq(t, `{"request_id":"r1","@message":"GET /orders 500","right":{"@message":"order lookup failed"},"user":"alice"}`, lines[1])
+}
+
+// _testRuntime starts a Runtime with the file plugin, and creates a temp dir for test files.
+// The Runtime is stopped, and the dir removed, when the test ends.
+func _testRuntime(t *testing.T) (*Runtime, string) {
+	t.Helper()
+	r := NewRuntime(hclog.Default(), file.Plugin())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	require.NoError(t, r.Start(ctx))
+
+	dir, err := os.MkdirTemp("", t.Name()+"-*")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	t.Cleanup(func() {
+		_ = r.Stop()
+	})
+	return r, dir
+}
+
+// _waitForLines waits for the files at paths to have n lines between them, since async sinks may still be writing when ExecuteString returns.
+func _waitForLines(t *testing.T, n int, paths ...string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		count := 0
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			count += strings.Count(string(data), "\n")
+		}
+		return count >= n
+	}, 4*time.Second, 10*time.Millisecond, "Sinks should write %d lines", n)
+}